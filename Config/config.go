@@ -12,6 +12,7 @@ import (
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/utils/logging"
 	"github.com/ava-labs/hypersdk/config"
+	"github.com/ava-labs/hypersdk/crypto"
 	"github.com/ava-labs/hypersdk/trace"
 	"github.com/ava-labs/hypersdk/vm"
 
@@ -22,6 +23,11 @@ import (
 
 var _ vm.Config = (*Config)(nil)
 
+const (
+	defaultBookSnapshotInterval = 1_000
+	defaultBookHistoryRetention = 100_000
+)
+
 type Config struct {
 	*config.Config
 
@@ -46,8 +52,30 @@ type Config struct {
 	// State Sync
 	StateSyncServerDelay time.Duration `json:"stateSyncServerDelay"` // for testing
 
+	// DevKey is the hex-encoded private key the order endpoints sign
+	// transactions with when a request carries none. Only honored in
+	// TestMode.
+	DevKey string `json:"devKey"`
+
+	// OperatorKey is the hex-encoded private key of the operator named in
+	// genesis. A node holding it settles the orders the matching engine
+	// closed in every block it accepts.
+	OperatorKey string `json:"operatorKey"`
+
+	// StreamAllowedOrigins lists the browser origins (e.g.
+	// "https://app.example.com") besides the node's own that may open the
+	// WebSocket stream, "*" allowing any. Clients that send no Origin, which
+	// are not browsers, may always connect.
+	StreamAllowedOrigins []string `json:"streamAllowedOrigins"`
+
+	// Order Book History
+	BookSnapshotInterval uint64 `json:"bookSnapshotInterval"` // Blocks between stored books, 0 disables history
+	BookHistoryRetention uint64 `json:"bookHistoryRetention"` // Blocks of history kept, 0 keeps everything
+
 	nodeID             ids.NodeID
 	parsedExemptPayers [][]byte
+	parsedDevKey       *crypto.PrivateKey
+	parsedOperatorKey  *crypto.PrivateKey
 }
 
 func New(nodeID ids.NodeID, b []byte) (*Config, error) {
@@ -69,6 +97,23 @@ func New(nodeID ids.NodeID, b []byte) (*Config, error) {
 		}
 		c.parsedExemptPayers[i] = p[:]
 	}
+
+	// The dev key lets unsigned requests through, so never use it outside
+	// of tests
+	if c.TestMode && len(c.DevKey) > 0 {
+		k, err := crypto.HexToKey(c.DevKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse dev key: %w", err)
+		}
+		c.parsedDevKey = &k
+	}
+	if len(c.OperatorKey) > 0 {
+		k, err := crypto.HexToKey(c.OperatorKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse operator key: %w", err)
+		}
+		c.parsedOperatorKey = &k
+	}
 	return c, nil
 }
 
@@ -78,6 +123,8 @@ func (c *Config) setDefault() {
 	c.MempoolSize = c.Config.GetMempoolSize()
 	c.MempoolPayerSize = c.Config.GetMempoolPayerSize()
 	c.StateSyncServerDelay = c.Config.GetStateSyncServerDelay()
+	c.BookSnapshotInterval = defaultBookSnapshotInterval
+	c.BookHistoryRetention = defaultBookHistoryRetention
 }
 
 func (c *Config) GetLogLevel() logging.Level       { return c.LogLevel }
@@ -97,4 +144,25 @@ func (c *Config) GetTraceConfig() *trace.Config {
 		Version:         version.Version.String(),
 	}
 }
-func (c *Config) GetStateSyncServerDelay() time.Duration { return c.StateSyncServerDelay }
\ No newline at end of file
+func (c *Config) GetStateSyncServerDelay() time.Duration { return c.StateSyncServerDelay }
+func (c *Config) GetBookSnapshotInterval() uint64        { return c.BookSnapshotInterval }
+func (c *Config) GetBookHistoryRetention() uint64        { return c.BookHistoryRetention }
+func (c *Config) GetStreamAllowedOrigins() []string      { return c.StreamAllowedOrigins }
+
+// GetDevKey returns the key to sign transactions with on behalf of unsigned
+// requests, or false if there is none (always the case outside of TestMode)
+func (c *Config) GetDevKey() (crypto.PrivateKey, bool) {
+	if c.parsedDevKey == nil {
+		return crypto.EmptyPrivateKey, false
+	}
+	return *c.parsedDevKey, true
+}
+
+// GetOperatorKey returns the key to sign settlements with, or false if the
+// node does not settle
+func (c *Config) GetOperatorKey() (crypto.PrivateKey, bool) {
+	if c.parsedOperatorKey == nil {
+		return crypto.EmptyPrivateKey, false
+	}
+	return *c.parsedOperatorKey, true
+}
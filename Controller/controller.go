@@ -1,4 +1,3 @@
-
 /*
 Package controller provides the implementation of a Controller that manages
 the interactions between the virtual machine (VM), storage, and genesis
@@ -11,6 +10,7 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	ametrics "github.com/ava-labs/avalanchego/api/metrics"
@@ -26,11 +26,13 @@ import (
 	"go.uber.org/zap"
 
 	"CLOB/actions"
+	"CLOB/auth"
 	"CLOB/config"
 	"CLOB/consts"
 	"CLOB/genesis"
 	"CLOB/storage"
 	"CLOB/version"
+	engine "CLOB/vm"
 )
 
 // Ensure Controller implements the vm.Controller interface
@@ -41,13 +43,17 @@ var _ vm.Controller = (*Controller)(nil)
 // configurations, handling accepted and rejected blocks, and managing
 // state transitions.
 type Controller struct {
-	inner        *vm.VM          // The underlying VM instance
-	snowCtx      *snow.Context    // Context for snow operations
-	genesis      *genesis.Genesis // Genesis configuration
-	config       *config.Config    // Configuration settings
-	stateManager *StateManager     // Manages the state of the chain
-	metrics      *Metrics          // Metrics for tracking performance
-	metaDB       database.Database  // Database for metadata storage
+	inner        *vm.VM                   // The underlying VM instance
+	snowCtx      *snow.Context            // Context for snow operations
+	genesis      *genesis.Genesis         // Genesis configuration
+	config       *config.Config           // Configuration settings
+	stateManager *StateManager            // Manages the state of the chain
+	metrics      *Metrics                 // Metrics for tracking performance
+	metaDB       database.Database        // Database for metadata storage
+	indexer      *Indexer                 // Indexes orders and fills into metaDB
+	vm           *engine.MatchingEngineVM // Matching engine holding the order book
+	streamer     *Streamer                // Streams accepted book events to WebSocket clients
+	stats        *StatsTracker            // Rolling statistics of every market
 }
 
 // New creates a new instance of the VM with the Controller. It initializes
@@ -69,16 +75,16 @@ func (c *Controller) Initialize(
 	upgradeBytes []byte,
 	configBytes []byte,
 ) (
-	vm.Config,          // Configuration for the VM
-	vm.Genesis,        // Genesis information
-	builder.Builder,    // Builder for block creation
-	gossiper.Gossiper,  // Gossiper for block propagation
-	database.Database,  // Database for block storage
-	database.Database,  // Database for state storage
-	vm.Handlers,       // Handlers for API endpoints
+	vm.Config, // Configuration for the VM
+	vm.Genesis, // Genesis information
+	builder.Builder, // Builder for block creation
+	gossiper.Gossiper, // Gossiper for block propagation
+	database.Database, // Database for block storage
+	database.Database, // Database for state storage
+	vm.Handlers, // Handlers for API endpoints
 	chain.ActionRegistry, // Registry for actions
-	chain.AuthRegistry,    // Registry for authentication
-	error,             // Error if initialization fails
+	chain.AuthRegistry, // Registry for authentication
+	error, // Error if initialization fails
 ) {
 	// Set the inner VM and snow context
 	c.inner = inner
@@ -110,6 +116,23 @@ func (c *Controller) Initialize(
 	}
 	snowCtx.Log.Info("loaded genesis", zap.Any("genesis", c.genesis))
 
+	// Apply scheduled rule changes from the upgrade file
+	if err := c.genesis.ApplyUpgrades(upgradeBytes); err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf(
+			"unable to read upgrades: %w",
+			err,
+		)
+	}
+	snowCtx.Log.Info("loaded upgrades", zap.Any("upgrades", c.genesis.Upgrades))
+
+	// Order transactions look the assets of their market up to declare the
+	// balances they check and reserve
+	markets, err := c.genesis.GetMarkets()
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+	storage.RegisterMarkets(markets)
+
 	// Initialize databases
 	blockPath, err := utils.InitSubDirectory(snowCtx.ChainDataDir, "block")
 	if err != nil {
@@ -138,6 +161,39 @@ func (c *Controller) Initialize(
 	if err != nil {
 		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
+	c.indexer = NewIndexer(c.metaDB)
+
+	// Initialize the matching engine at the last accepted block. Blocks are
+	// executed against it as they are accepted.
+	c.vm, err = engine.NewMatchingEngineVMFromGenesis(c.genesis)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+	c.vm.SetParallelism(c.config.GetParallelism())
+	lastAccepted := inner.LastAcceptedBlock()
+	if err := c.loadEngine(context.Background(), lastAccepted); err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf(
+			"unable to restore the matching engine: %w",
+			err,
+		)
+	}
+	c.metrics.observeBooks(c.vm.Snapshots())
+
+	if err := c.indexer.BackfillCandles(context.Background()); err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf(
+			"unable to backfill candles: %w",
+			err,
+		)
+	}
+	if c.config.GetBookSnapshotInterval() > 0 {
+		if err := c.indexer.InitHistory(context.Background(), c.vm.Snapshots()); err != nil {
+			return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+		}
+	}
+	c.stats, err = NewStatsTracker(context.Background(), c.metaDB, c.vm.Snapshots(), lastAccepted.Hght, lastAccepted.GetTimestamp())
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
 
 	// Initialize handlers
 	apis := map[string]*common.HTTPHandler{}
@@ -146,6 +202,8 @@ func (c *Controller) Initialize(
 		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 	apis[vm.Endpoint] = endpoint
+	c.streamer = NewStreamer(snowCtx.Log, snowCtx.ChainID, c.vm.Snapshots(), c.config.GetStreamAllowedOrigins())
+	apis[StreamEndpoint] = &common.HTTPHandler{LockOptions: common.NoLock, Handler: c.streamer}
 
 	// Create builder and gossiper
 	var (
@@ -178,14 +236,98 @@ func (c *Controller) StateManager() chain.StateManager {
 	return c.stateManager
 }
 
-// Accepted processes accepted blocks and stores transaction results. It
-// iterates through the transactions in the block, storing their results
-// in the metadata database and updating metrics based on the transaction
-// actions.
+// blockInfo identifies a block for the matching engine
+func blockInfo(blk *chain.StatelessBlock) engine.BlockInfo {
+	return engine.BlockInfo{
+		ID:        blk.ID(),
+		Parent:    blk.Prnt,
+		Height:    blk.Hght,
+		Timestamp: blk.GetTimestamp(),
+	}
+}
+
+// blockTxs extracts the engine actions of the successful order transactions
+// of a block, in block order
+func blockTxs(blk *chain.StatelessBlock) []engine.BlockTx {
+	results := blk.Results()
+	txs := make([]engine.BlockTx, 0, len(blk.Txs))
+	for i, tx := range blk.Txs {
+		if !results[i].Success {
+			continue
+		}
+		orderTx, ok := tx.Action.(actions.OrderTx)
+		if !ok {
+			continue
+		}
+		txs = append(txs, engine.BlockTx{
+			TxID:   tx.ID(),
+			Action: orderTx.EngineAction(auth.GetActor(tx.Auth), tx.ID(), blk.GetTimestamp()),
+		})
+	}
+	return txs
+}
+
+// Accepted processes accepted blocks and stores transaction results.
+// hypersdk exposes no hook into block verification, so the order actions of
+// a block are executed by the matching engine here, against the accepted
+// books. Everything derived from the block (transaction results, orders,
+// fills and the engine state) is written to the metadata database in one
+// batch before the engine commits the block, so a failed write leaves the
+// engine at the previous block and the two never disagree. The operator
+// node then settles the orders the block closed into chain state.
 func (c *Controller) Accepted(ctx context.Context, blk *chain.StatelessBlock) error {
+	if _, err := c.vm.VerifyBlock(blockInfo(blk), blockTxs(blk)); err != nil {
+		return err
+	}
+	view, err := c.vm.AcceptBlock(blk.ID(), func(view *engine.BlockView, books engine.Snapshots) error {
+		return c.storeBlock(ctx, blk, view, books)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.observeTxs(blk)
+	c.metrics.observeBlock(view)
+	c.stats.Update(view, c.vm.Snapshots())
+	c.settle(ctx, view)
+
+	// Only stream what is durably indexed, so clients can always catch up
+	// from the RPC endpoints
+	c.streamer.Publish(view)
+	return nil
+}
+
+// storeBlock writes the transaction results of an accepted block, the
+// orders and fills of its [view] and the engine state after it to the
+// metadata database. [books] are the snapshots of every book after the
+// block.
+func (c *Controller) storeBlock(
+	ctx context.Context,
+	blk *chain.StatelessBlock,
+	view *engine.BlockView,
+	books engine.Snapshots,
+) error {
 	batch := c.metaDB.NewBatch()
 	defer batch.Reset()
 
+	if err := c.indexer.IndexBlock(ctx, batch, blk, view); err != nil {
+		return err
+	}
+	if err := c.indexer.StoreEngineState(ctx, batch, view, view.State()); err != nil {
+		return err
+	}
+	err := c.indexer.StoreHistory(
+		ctx,
+		batch,
+		books,
+		view.Height,
+		c.config.GetBookSnapshotInterval(),
+		c.config.GetBookHistoryRetention(),
+	)
+	if err != nil {
+		return err
+	}
+
 	results := blk.Results()
 	for i, tx := range blk.Txs {
 		result := results[i]
@@ -200,30 +342,48 @@ func (c *Controller) Accepted(ctx context.Context, blk *chain.StatelessBlock) er
 		if err != nil {
 			return err
 		}
+	}
+	return batch.Write()
+}
+
+// observeTxs updates the transaction metrics with the txs of an accepted
+// block
+func (c *Controller) observeTxs(blk *chain.StatelessBlock) {
+	results := blk.Results()
+	for i, tx := range blk.Txs {
+		result := results[i]
 		if result.Success {
 			switch tx.Action.(type) {
-			case *actions.AddOrderAction:
+			case *actions.AddOrder:
 				c.metrics.addOrder.Inc()
-			case *actions.CancelOrderAction:
+			case *actions.CancelOrder:
 				c.metrics.cancelOrder.Inc()
-			case *actions.MatchOrderAction:
+			case *actions.MatchOrder:
 				c.metrics.matchOrder.Inc()
 			}
+		} else if orderTx, ok := tx.Action.(actions.OrderTx); ok {
+			// Orders of txs that failed on chain never reach the engine
+			action := orderTx.EngineAction(auth.GetActor(tx.Auth), tx.ID(), blk.GetTimestamp())
+			for _, refused := range refusedOrders(action, errors.New(string(result.Output))) {
+				c.metrics.reject(refused.err)
+			}
 		}
 	}
-	return batch.Write()
 }
 
-// Rejected handles rejected blocks. This implementation is a no-op,
-// meaning it does not perform any actions when a block is rejected.
-func (*Controller) Rejected(context.Context, *chain.StatelessBlock) error {
+// Rejected handles rejected blocks. Blocks only reach the matching engine
+// once accepted, so a rejected block never touched the order books and
+// there is nothing to discard.
+func (c *Controller) Rejected(context.Context, *chain.StatelessBlock) error {
 	return nil
 }
 
-// Shutdown gracefully shuts down the controller. This implementation
-// does not close any databases provided during initialization, as the
-// VM is responsible for closing them.
-func (*Controller) Shutdown(context.Context) error {
+// Shutdown gracefully shuts down the controller. It disconnects stream
+// clients but does not close any databases provided during initialization,
+// as the VM is responsible for closing them.
+func (c *Controller) Shutdown(context.Context) error {
+	c.streamer.Close()
+
 	// Do not close any databases provided during initialization. The VM will
 	// close any databases you're provided.
 	return nil
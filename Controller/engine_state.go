@@ -0,0 +1,104 @@
+// controller/engine_state.go
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/hypersdk/chain"
+
+	"CLOB/storage"
+	engine "CLOB/vm"
+)
+
+var (
+	ErrEngineStateMissing = errors.New("no matching engine state is stored for the last accepted block")
+	ErrEngineStateStale   = errors.New("stored matching engine state is not at the last accepted block")
+)
+
+// loadEngine brings the matching engine to [lastAccepted]. Past genesis the
+// accepted books and timers are restored from metaDB, so a restart resumes
+// exactly where the node stopped; a node without them refuses to start
+// rather than serve the genesis books as the tip.
+func (c *Controller) loadEngine(ctx context.Context, lastAccepted *chain.StatelessBlock) error {
+	state, found, err := storage.GetEngineState(ctx, c.metaDB)
+	if err != nil {
+		return err
+	}
+	switch {
+	case found && state.BlockID == lastAccepted.ID():
+		markets, err := c.genesis.GetMarkets()
+		if err != nil {
+			return err
+		}
+		books, err := storage.LoadOrderBooks(ctx, c.metaDB, markets, state)
+		if err != nil {
+			return err
+		}
+		c.vm.Restore(books, storage.LoadCancelTimers(state), state.BlockID, state.Height, state.Timestamp)
+		return nil
+	case found:
+		return fmt.Errorf(
+			"%w: stored at %s (height %d), last accepted %s (height %d)",
+			ErrEngineStateStale,
+			state.BlockID,
+			state.Height,
+			lastAccepted.ID(),
+			lastAccepted.Hght,
+		)
+	case lastAccepted.Hght != 0:
+		return fmt.Errorf("%w: %s (height %d)", ErrEngineStateMissing, lastAccepted.ID(), lastAccepted.Hght)
+	}
+
+	// A new chain starts from the genesis books, persisted so that the next
+	// restart restores them like those of any other block
+	c.vm.SetLastAccepted(lastAccepted.ID(), lastAccepted.Hght)
+	return c.indexer.InitEngineState(ctx, c.vm.Books, c.vm.State())
+}
+
+// StoreEngineState writes the resting orders changed by the block of [view]
+// and the engine [state] after it to [batch]
+func (i *Indexer) StoreEngineState(
+	ctx context.Context,
+	batch database.Batch,
+	view *engine.BlockView,
+	state *storage.EngineState,
+) error {
+	for _, update := range view.PreEvents.Updates {
+		if err := storage.StoreRestingUpdate(ctx, batch, &update); err != nil {
+			return err
+		}
+	}
+	for _, events := range view.Events {
+		for _, update := range events.Updates {
+			if err := storage.StoreRestingUpdate(ctx, batch, &update); err != nil {
+				return err
+			}
+		}
+	}
+	return storage.StoreEngineState(ctx, batch, state)
+}
+
+// InitEngineState writes every resting order of [books] and the engine
+// [state] they are at
+func (i *Indexer) InitEngineState(
+	ctx context.Context,
+	books map[string]*storage.OrderBook,
+	state *storage.EngineState,
+) error {
+	batch := i.db.NewBatch()
+	defer batch.Reset()
+
+	for _, book := range books {
+		if err := storage.StoreOrderBook(ctx, batch, book); err != nil {
+			return err
+		}
+	}
+	if err := storage.StoreEngineState(ctx, batch, state); err != nil {
+		return err
+	}
+	return batch.Write()
+}
@@ -4,24 +4,37 @@ package controller
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"net/http"
 
+	"CLOB/actions"
+	"CLOB/auth"
 	"CLOB/genesis"
 	"CLOB/storage"
 	"CLOB/utils"
 	"CLOB/vm"
+	engine "CLOB/vm"
 
+	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/hypersdk/chain"
-	"github.com/ava-labs/hypersdk/codec"
-	"github.com/ava-labs/hypersdk/ids"
+	"github.com/ava-labs/hypersdk/crypto"
 )
 
 // Define controller-specific errors
 var (
-	ErrOrderNotFound    = errors.New("order not found")
-	ErrOrderAlreadyExists = errors.New("order already exists")
-	ErrInvalidOrder     = errors.New("invalid order")
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrOrderAlreadyExists  = errors.New("order already exists")
+	ErrInvalidOrder        = errors.New("invalid order")
 	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrBatchNotFound       = errors.New("batch not found")
+	ErrCancelAllNotFound   = errors.New("cancel-all not found")
+	ErrDeadlinePassed      = errors.New("cancel-after deadline has already passed")
+	ErrInvalidDepth        = errors.New("invalid depth request")
+	ErrInvalidCursor       = errors.New("invalid cursor")
+	ErrChecksumNotFound    = errors.New("checksum not found")
+	ErrHeightNotAccepted   = errors.New("height is not accepted yet")
+	ErrMarketRequired      = errors.New("market is required with a client order ID")
 )
 
 // Handler manages HTTP requests related to the Order Book Matching Engine
@@ -42,127 +55,445 @@ func (h *Handler) Genesis(_ *http.Request, _ *struct{}, reply *GenesisReply) err
 	return nil
 }
 
-// AddOrderArgs represents the request payload for adding a new order
+// AddOrderArgs represents the request payload for adding a new order. Tx
+// carries the AddOrder transaction signed by the client; without it the
+// order is described by the other fields and signed with the dev key, which
+// is only available in TestMode.
 type AddOrderArgs struct {
-	OrderID   string  `json:"order_id"`
-	Side      string  `json:"side"`       // "buy" or "sell"
-	Price     float64 `json:"price"`      // 0 for market orders
-	Quantity  float64 `json:"quantity"`
-	OrderType string  `json:"order_type"` // "limit" or "market"
+	Tx []byte `json:"tx,omitempty"`
+
+	ClientOrderID string  `json:"client_order_id,omitempty"`
+	Market        string  `json:"market"`
+	Side          string  `json:"side"`  // "buy" or "sell"
+	Price         float64 `json:"price"` // Worst price of a market order, 0 for none on a sell
+	Quantity      float64 `json:"quantity"`
+	OrderType     string  `json:"order_type"` // "limit" or "market"
 }
 
-// AddOrderReply represents the response after adding a new order
+// AddOrderReply represents the response after submitting a new order
 type AddOrderReply struct {
 	Success bool   `json:"success"`
 	Message string `json:"message,omitempty"`
+	TxID    ids.ID `json:"tx_id"`
+	OrderID string `json:"order_id,omitempty"` // Canonical ID the order will have
+
+	// Reject is set if the order failed a pre-trade check and was not
+	// submitted
+	Reject *actions.RejectError `json:"reject,omitempty"`
 }
 
-// AddOrder handles the addition of a new order to the order book
+// AddOrder handles the submission of a new order to the mempool. The order
+// reaches the book once a block including its transaction is accepted.
 func (h *Handler) AddOrder(req *http.Request, args *AddOrderArgs, reply *AddOrderReply) error {
 	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.AddOrder")
 	defer span.End()
 
-	// Validate order type
-	if args.OrderType != "limit" && args.OrderType != "market" {
+	tx, err := h.orderTx(ctx, args.Tx, func() (chain.Action, error) {
+		// Validate order type
+		if args.OrderType != "limit" && args.OrderType != "market" {
+			reply.Message = "invalid order type"
+			return nil, ErrInvalidOrder
+		}
+
+		// Validate order side
+		if args.Side != "buy" && args.Side != "sell" {
+			reply.Message = "invalid order side"
+			return nil, ErrInvalidOrder
+		}
+
+		return &actions.AddOrder{
+			ClientOrderID: args.ClientOrderID,
+			Market:        args.Market,
+			Side:          args.Side,
+			Price:         args.Price,
+			Quantity:      args.Quantity,
+			OrderType:     args.OrderType,
+		}, nil
+	})
+	if err != nil {
 		reply.Success = false
-		reply.Message = "invalid order type"
-		return ErrInvalidOrder
+		return err
 	}
-
-	// Validate order side
-	if args.Side != "buy" && args.Side != "sell" {
+	addOrder, ok := tx.Action.(*actions.AddOrder)
+	if !ok {
 		reply.Success = false
-		reply.Message = "invalid order side"
-		return ErrInvalidOrder
+		return ErrUnexpectedAction
 	}
 
-	// Check for duplicate order ID
-	exists, err := storage.OrderExists(ctx, h.c.metaDB, args.OrderID)
+	// Refused orders are a normal reply so the reason reaches the client
+	rerr, err := h.preTrade(ctx, auth.GetActor(tx.Auth), addOrder)
 	if err != nil {
+		reply.Success = false
 		return err
 	}
-	if exists {
+	if rerr != nil {
 		reply.Success = false
-		reply.Message = "order ID already exists"
-		return ErrOrderAlreadyExists
+		reply.Message = rerr.Error()
+		reply.Reject = rerr
+		return nil
 	}
 
-	// Create Order struct
-	order := &storage.Order{
-		ID:        args.OrderID,
-		Side:      storage.Side(args.Side),
-		Price:     args.Price,
-		Quantity:  args.Quantity,
-		Timestamp: h.c.inner.Clock().Now(),
-		OrderType: storage.OrderType(args.OrderType),
+	txID, err := h.submitTx(ctx, tx)
+	if err != nil {
+		reply.Success = false
+		reply.Message = err.Error()
+		return err
 	}
 
-	// Execute AddOrder action
-	addOrderAction := &actions.AddOrderAction{Order: order}
-	if err := h.c.vm.ExecuteAction(addOrderAction); err != nil {
+	reply.Success = true
+	reply.Message = "order submitted successfully"
+	reply.TxID = txID
+	reply.OrderID = storage.NewOrderID(txID, 0)
+	return nil
+}
+
+// BatchOrdersArgs represents the request payload for submitting many order
+// instructions on one market at once. Like AddOrderArgs, Tx carries the
+// BatchOrders transaction signed by the client, and without it the dev key
+// signs one in TestMode.
+type BatchOrdersArgs struct {
+	Tx []byte `json:"tx,omitempty"`
+
+	Market       string                     `json:"market"`
+	AllOrNothing bool                       `json:"all_or_nothing"`
+	Instructions []actions.BatchInstruction `json:"instructions"`
+}
+
+// BatchOrdersReply represents the response after submitting a batch. The
+// outcome of every instruction is returned by GetBatchResults once the
+// transaction is accepted.
+type BatchOrdersReply struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	TxID    ids.ID `json:"tx_id"`
+}
+
+// BatchOrders handles the submission of many order instructions on one
+// market to the mempool. They are executed together, and checked against
+// the ownership of the orders they touch, once a block including the
+// transaction is accepted.
+func (h *Handler) BatchOrders(req *http.Request, args *BatchOrdersArgs, reply *BatchOrdersReply) error {
+	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.BatchOrders")
+	defer span.End()
+
+	tx, err := h.orderTx(ctx, args.Tx, func() (chain.Action, error) {
+		return &actions.BatchOrders{
+			Market:       args.Market,
+			AllOrNothing: args.AllOrNothing,
+			Instructions: args.Instructions,
+		}, nil
+	})
+	if err != nil {
+		reply.Success = false
+		return err
+	}
+	if _, ok := tx.Action.(*actions.BatchOrders); !ok {
+		reply.Success = false
+		return ErrUnexpectedAction
+	}
+
+	txID, err := h.submitTx(ctx, tx)
+	if err != nil {
 		reply.Success = false
 		reply.Message = err.Error()
 		return err
 	}
 
 	reply.Success = true
-	reply.Message = "order added successfully"
+	reply.Message = "batch submitted successfully"
+	reply.TxID = txID
+	return nil
+}
+
+// GetBatchResultsArgs represents the request payload for retrieving the
+// outcome of a batch transaction
+type GetBatchResultsArgs struct {
+	TxID ids.ID `json:"tx_id"`
+}
+
+// GetBatchResultsReply represents the response containing the outcome of
+// every instruction of a batch transaction
+type GetBatchResultsReply struct {
+	Results []*storage.InstructionResult `json:"results"`
+}
+
+// GetBatchResults handles retrieving the outcome of an accepted batch
+// transaction
+func (h *Handler) GetBatchResults(req *http.Request, args *GetBatchResultsArgs, reply *GetBatchResultsReply) error {
+	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetBatchResults")
+	defer span.End()
+
+	results, found, err := storage.GetBatchResults(ctx, h.c.metaDB, args.TxID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrBatchNotFound
+	}
+	reply.Results = results
 	return nil
 }
 
-// CancelOrderArgs represents the request payload for canceling an order
+// CancelOrderArgs represents the request payload for canceling an order,
+// identified by either its ID or its client order ID. Like AddOrderArgs, Tx
+// carries the CancelOrder transaction signed by the client, and without it
+// the dev key signs one in TestMode.
 type CancelOrderArgs struct {
-	OrderID string `json:"order_id"`
+	Tx []byte `json:"tx,omitempty"`
+
+	OrderID       string `json:"order_id,omitempty"`
+	ClientOrderID string `json:"client_order_id,omitempty"`
+	Market        string `json:"market"`
 }
 
-// CancelOrderReply represents the response after canceling an order
+// CancelOrderReply represents the response after submitting a cancellation
 type CancelOrderReply struct {
 	Success bool   `json:"success"`
 	Message string `json:"message,omitempty"`
+	TxID    ids.ID `json:"tx_id"`
 }
 
-// CancelOrder handles the cancellation of an existing order
+// CancelOrder handles the submission of the cancellation of an existing
+// order to the mempool
 func (h *Handler) CancelOrder(req *http.Request, args *CancelOrderArgs, reply *CancelOrderReply) error {
 	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.CancelOrder")
 	defer span.End()
 
-	// Check if the order exists
-	exists, err := storage.OrderExists(ctx, h.c.metaDB, args.OrderID)
+	tx, err := h.orderTx(ctx, args.Tx, func() (chain.Action, error) {
+		if (args.OrderID == "") == (args.ClientOrderID == "") {
+			reply.Message = "exactly one of order ID and client order ID must be set"
+			return nil, ErrInvalidOrder
+		}
+		return &actions.CancelOrder{
+			OrderID:       args.OrderID,
+			ClientOrderID: args.ClientOrderID,
+			Market:        args.Market,
+		}, nil
+	})
 	if err != nil {
+		reply.Success = false
 		return err
 	}
-	if !exists {
+	if _, ok := tx.Action.(*actions.CancelOrder); !ok {
 		reply.Success = false
-		reply.Message = "order not found"
-		return ErrOrderNotFound
+		return ErrUnexpectedAction
+	}
+
+	txID, err := h.submitTx(ctx, tx)
+	if err != nil {
+		reply.Success = false
+		reply.Message = err.Error()
+		return err
+	}
+
+	reply.Success = true
+	reply.Message = "cancellation submitted successfully"
+	reply.TxID = txID
+	return nil
+}
+
+// CancelAllArgs represents the request payload for canceling every resting
+// order of the sender. Like CancelOrderArgs, Tx carries the CancelAll
+// transaction signed by the client, and without it the dev key signs one in
+// TestMode.
+type CancelAllArgs struct {
+	Tx []byte `json:"tx,omitempty"`
+
+	Market string `json:"market,omitempty"` // Every market if empty
+	Side   string `json:"side,omitempty"`   // Both sides if empty
+}
+
+// CancelAllReply represents the response after submitting a cancel-all. The
+// cancelled orders are returned by GetCancelled once the transaction is
+// accepted.
+type CancelAllReply struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	TxID    ids.ID `json:"tx_id"`
+}
+
+// CancelAll handles the submission of the cancellation of every resting
+// order of the sender to the mempool
+func (h *Handler) CancelAll(req *http.Request, args *CancelAllArgs, reply *CancelAllReply) error {
+	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.CancelAll")
+	defer span.End()
+
+	tx, err := h.orderTx(ctx, args.Tx, func() (chain.Action, error) {
+		if args.Side != "" && args.Side != string(storage.Buy) && args.Side != string(storage.Sell) {
+			reply.Message = "invalid order side"
+			return nil, ErrInvalidOrder
+		}
+		return &actions.CancelAll{Market: args.Market, Side: args.Side}, nil
+	})
+	if err != nil {
+		reply.Success = false
+		return err
+	}
+	if _, ok := tx.Action.(*actions.CancelAll); !ok {
+		reply.Success = false
+		return ErrUnexpectedAction
+	}
+
+	txID, err := h.submitTx(ctx, tx)
+	if err != nil {
+		reply.Success = false
+		reply.Message = err.Error()
+		return err
+	}
+
+	reply.Success = true
+	reply.Message = "cancel-all submitted successfully"
+	reply.TxID = txID
+	return nil
+}
+
+// GetCancelledArgs represents the request payload for retrieving the orders
+// cancelled by a cancel-all transaction
+type GetCancelledArgs struct {
+	TxID ids.ID `json:"tx_id"`
+}
+
+// GetCancelledReply represents the response containing the cancelled orders
+type GetCancelledReply struct {
+	OrderIDs []string `json:"order_ids"`
+}
+
+// GetCancelled handles retrieving the orders cancelled by an accepted
+// cancel-all transaction
+func (h *Handler) GetCancelled(req *http.Request, args *GetCancelledArgs, reply *GetCancelledReply) error {
+	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetCancelled")
+	defer span.End()
+
+	orderIDs, found, err := storage.GetCancelled(ctx, h.c.metaDB, args.TxID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrCancelAllNotFound
 	}
+	reply.OrderIDs = orderIDs
+	return nil
+}
+
+// SetCancelAfterArgs represents the request payload for setting the
+// dead-man's switch of the sender. Leaving both deadlines unset disarms it.
+// Like CancelOrderArgs, Tx carries the SetCancelAfter transaction signed by
+// the client, and without it the dev key signs one in TestMode.
+type SetCancelAfterArgs struct {
+	Tx []byte `json:"tx,omitempty"`
 
-	// Execute CancelOrder action
-	cancelOrderAction := &actions.CancelOrderAction{OrderID: args.OrderID}
-	if err := h.c.vm.ExecuteAction(cancelOrderAction); err != nil {
+	Height    uint64 `json:"height,omitempty"`    // Expire orders at this block height
+	Timestamp int64  `json:"timestamp,omitempty"` // Expire orders at this block time
+}
+
+// SetCancelAfterReply represents the response after submitting a change of
+// the dead-man's switch. The accepted timer is returned by GetCancelAfter.
+type SetCancelAfterReply struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	TxID    ids.ID `json:"tx_id"`
+}
+
+// CancelAfterReply represents the response containing the dead-man's switch
+// of an address
+type CancelAfterReply struct {
+	Armed     bool   `json:"armed"`
+	Height    uint64 `json:"height,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+}
+
+// SetCancelAfter handles the submission of arming, renewing or disarming the
+// dead-man's switch of the sender to the mempool. The deadline is checked
+// against the timestamp of the block that includes the transaction.
+func (h *Handler) SetCancelAfter(req *http.Request, args *SetCancelAfterArgs, reply *SetCancelAfterReply) error {
+	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.SetCancelAfter")
+	defer span.End()
+
+	tx, err := h.orderTx(ctx, args.Tx, func() (chain.Action, error) {
+		return &actions.SetCancelAfter{Height: args.Height, Timestamp: args.Timestamp}, nil
+	})
+	if err != nil {
+		reply.Success = false
+		return err
+	}
+	setCancelAfter, ok := tx.Action.(*actions.SetCancelAfter)
+	if !ok {
+		reply.Success = false
+		return ErrUnexpectedAction
+	}
+
+	// A deadline the chain clock already passed can only fail on chain
+	if setCancelAfter.Timestamp != 0 && setCancelAfter.Timestamp <= h.c.inner.Clock().Now().Unix() {
+		reply.Success = false
+		return ErrDeadlinePassed
+	}
+
+	txID, err := h.submitTx(ctx, tx)
+	if err != nil {
 		reply.Success = false
 		reply.Message = err.Error()
 		return err
 	}
 
 	reply.Success = true
-	reply.Message = "order canceled successfully"
+	reply.Message = "cancel-after submitted successfully"
+	reply.TxID = txID
+	return nil
+}
+
+// GetCancelAfterArgs represents the request payload for retrieving the
+// dead-man's switch of an address
+type GetCancelAfterArgs struct {
+	Address string `json:"address"`
+}
+
+// GetCancelAfter handles retrieving the accepted dead-man's switch of an
+// address
+func (h *Handler) GetCancelAfter(req *http.Request, args *GetCancelAfterArgs, reply *CancelAfterReply) error {
+	_, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetCancelAfter")
+	defer span.End()
+
+	owner, err := utils.ParseAddress(args.Address)
+	if err != nil {
+		return err
+	}
+	timer, armed := h.c.vm.CancelTimer(owner)
+	reply.Armed, reply.Height, reply.Timestamp = armed, timer.Height, timer.Timestamp
 	return nil
 }
 
-// GetOrderArgs represents the request payload for retrieving an order
+// GetOrderArgs represents the request payload for retrieving an order, by
+// its ID or by the client order ID its owner gave it. Client order IDs are
+// only unique per owner within a market, so the same ID may be in use in
+// several markets at once: a lookup by client order ID needs its market.
 type GetOrderArgs struct {
-	OrderID string `json:"order_id"`
+	OrderID       string `json:"order_id,omitempty"`
+	ClientOrderID string `json:"client_order_id,omitempty"`
+	Address       string `json:"address,omitempty"` // Owner, required with ClientOrderID
+	Market        string `json:"market,omitempty"`  // Required with ClientOrderID, searches every market if empty otherwise
 }
 
 // GetOrderReply represents the response containing order details
 type GetOrderReply struct {
-	OrderID   string  `json:"order_id"`
+	OrderID       string `json:"order_id"`
+	ClientOrderID string `json:"client_order_id,omitempty"`
+
+	Market    string  `json:"market"`
 	Side      string  `json:"side"`
 	Price     float64 `json:"price"`
 	Quantity  float64 `json:"quantity"`
 	OrderType string  `json:"order_type"`
 	Timestamp int64   `json:"timestamp"`
+	Height    uint64  `json:"height"` // Height the order was last read or updated at
+
+	// Lifecycle of the order once its block is accepted: open,
+	// partially_filled, filled, cancelled, expired or rejected, and why it
+	// last changed. Submitted orders are not found until then.
+	Status           string  `json:"status"`
+	Reason           string  `json:"reason"`
+	OriginalQuantity float64 `json:"original_quantity"`
+	FilledQuantity   float64 `json:"filled_quantity"`
+	AvgFillPrice     float64 `json:"avg_fill_price"` // 0 if never filled
 }
 
 // GetOrder handles retrieving details of a specific order
@@ -170,48 +501,575 @@ func (h *Handler) GetOrder(req *http.Request, args *GetOrderArgs, reply *GetOrde
 	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetOrder")
 	defer span.End()
 
-	order, err := storage.GetOrder(ctx, h.c.metaDB, args.OrderID)
-	if err != nil {
-		return err
+	var owner crypto.PublicKey
+	switch {
+	case args.OrderID != "" && args.ClientOrderID != "":
+		return ErrInvalidOrder
+	case args.ClientOrderID != "":
+		if args.Market == "" {
+			return ErrMarketRequired
+		}
+		pk, err := utils.ParseAddress(args.Address)
+		if err != nil {
+			return err
+		}
+		owner = pk
+	case args.OrderID == "":
+		return ErrInvalidOrder
 	}
-	if order == nil {
-		return ErrOrderNotFound
+
+	// Read resting orders from the snapshots of the last accepted block,
+	// which are never modified by block execution
+	snapshots := h.c.vm.Snapshots()
+	for market, s := range snapshots {
+		if args.Market != "" && market != args.Market {
+			continue
+		}
+		var (
+			order storage.Order
+			found bool
+		)
+		if args.OrderID != "" {
+			order, found = s.GetOrder(args.OrderID)
+		} else {
+			order, found = s.GetOrderByClientID(owner, args.ClientOrderID)
+		}
+		if !found {
+			continue
+		}
+
+		reply.OrderID = order.ID
+		reply.ClientOrderID = order.ClientOrderID
+		reply.Market = order.Market
+		reply.Side = string(order.Side)
+		reply.Price = order.Price
+		reply.Quantity = order.Quantity
+		reply.OrderType = string(order.OrderType)
+		reply.Timestamp = order.Timestamp.Unix()
+		reply.Height = s.Height
+		reply.Status = string(order.RestingStatus())
+		reply.Reason = storage.ReasonPlaced
+		if order.FilledQuantity > 0 {
+			reply.Reason = storage.ReasonMatched
+		}
+		reply.OriginalQuantity = order.OriginalQuantity
+		reply.FilledQuantity = order.FilledQuantity
+		reply.AvgFillPrice = order.AvgFillPrice()
+		return nil
 	}
 
-	reply.OrderID = order.ID
-	reply.Side = string(order.Side)
-	reply.Price = order.Price
-	reply.Quantity = order.Quantity
-	reply.OrderType = string(order.OrderType)
-	reply.Timestamp = order.Timestamp.Unix()
+	// Orders that left the book are only in the index
+	var record *storage.OrderRecord
+	if args.OrderID != "" {
+		r, err := storage.GetOrder(ctx, h.c.metaDB, args.OrderID)
+		if err != nil && !errors.Is(err, storage.ErrOrderNotFound) {
+			return err
+		}
+		if err == nil && (args.Market == "" || r.Market == args.Market) {
+			record = r
+		}
+	} else {
+		r, err := storage.GetOrderByClientID(ctx, h.c.metaDB, owner, args.Market, args.ClientOrderID)
+		if err != nil && !errors.Is(err, storage.ErrOrderNotFound) {
+			return err
+		}
+		if err == nil {
+			record = r
+		}
+	}
+	if record == nil {
+		return ErrOrderNotFound
+	}
 
+	reply.OrderID = record.ID
+	reply.ClientOrderID = record.ClientOrderID
+	reply.Market = record.Market
+	reply.Side = string(record.Side)
+	reply.Price = record.Price
+	reply.Quantity = record.Quantity
+	reply.OrderType = string(record.OrderType)
+	reply.Timestamp = record.Timestamp
+	reply.Height = record.Height
+	reply.Status = string(record.Status)
+	reply.Reason = record.Reason
+	reply.OriginalQuantity = record.OriginalQuantity
+	reply.FilledQuantity = record.FilledQuantity
+	reply.AvgFillPrice = record.AvgFillPrice
 	return nil
 }
 
 // GetOrderBookArgs represents the request payload for retrieving the order book
-type GetOrderBookArgs struct{}
+type GetOrderBookArgs struct {
+	Market string `json:"market"`
+}
 
 // GetOrderBookReply represents the response containing the current state of the order book
 type GetOrderBookReply struct {
-	Bids []storage.Order `json:"bids"`
-	Asks []storage.Order `json:"asks"`
+	Bids   []storage.Order `json:"bids"`   // Best price first, then by time priority
+	Asks   []storage.Order `json:"asks"`   // Best price first, then by time priority
+	Height uint64          `json:"height"` // Height of the accepted block the book reflects
 }
 
 // GetOrderBook handles retrieving the current state of the order book
 func (h *Handler) GetOrderBook(req *http.Request, args *GetOrderBookArgs, reply *GetOrderBookReply) error {
-	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetOrderBook")
+	_, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetOrderBook")
 	defer span.End()
 
-	bids, asks, err := storage.GetOrderBookState(ctx, h.c.metaDB)
+	snapshot, err := h.c.vm.Snapshot(args.Market)
 	if err != nil {
 		return err
 	}
+	reply.Bids = flattenLevels(snapshot.Bids)
+	reply.Asks = flattenLevels(snapshot.Asks)
+	reply.Height = snapshot.Height
+	return nil
+}
 
-	reply.Bids = bids
-	reply.Asks = asks
+// Depth limits
+const (
+	DefaultDepthLevels = 20
+	MaxDepthLevels     = 500
+)
+
+// GetDepthArgs represents the request payload for retrieving the aggregated
+// depth of a market
+type GetDepthArgs struct {
+	Market string  `json:"market"`
+	Levels int     `json:"levels,omitempty"` // Levels per side, DefaultDepthLevels if 0
+	Bucket float64 `json:"bucket,omitempty"` // Price bucket size, 0 for exact prices
+}
+
+// GetDepthReply represents the response containing the aggregated depth of a
+// market
+type GetDepthReply struct {
+	Bids      []storage.DepthLevel `json:"bids"` // Best (highest) price first
+	Asks      []storage.DepthLevel `json:"asks"` // Best (lowest) price first
+	Sequence  uint64               `json:"sequence"`
+	Checksum  uint32               `json:"checksum"` // Of the unbucketed top of the book, see storage.Checksum
+	Height    uint64               `json:"height"`
+	Timestamp int64                `json:"timestamp"`
+}
+
+// GetDepth handles retrieving the top levels of each side of a market,
+// aggregated by price or price bucket
+func (h *Handler) GetDepth(req *http.Request, args *GetDepthArgs, reply *GetDepthReply) error {
+	_, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetDepth")
+	defer span.End()
+
+	levels := args.Levels
+	if levels == 0 {
+		levels = DefaultDepthLevels
+	}
+	if levels < 0 || levels > MaxDepthLevels {
+		return ErrInvalidDepth
+	}
+	if args.Bucket < 0 || math.IsNaN(args.Bucket) || math.IsInf(args.Bucket, 0) {
+		return ErrInvalidDepth
+	}
+
+	snapshot, err := h.c.vm.Snapshot(args.Market)
+	if err != nil {
+		return err
+	}
+	reply.Bids, reply.Asks = snapshot.Depth(levels, args.Bucket)
+	reply.Sequence = snapshot.Sequence
+	reply.Checksum = snapshot.Checksum
+	reply.Height = snapshot.Height
+	reply.Timestamp = snapshot.Timestamp
 	return nil
 }
 
+// Trade history page sizes
+const (
+	DefaultTradesLimit = 100
+	MaxTradesLimit     = 1000
+)
+
+// TradeRange selects trades by block timestamp if FromTime or ToTime is set,
+// and by block height otherwise. Bounds are inclusive and an unset upper
+// bound is open. Cursor is empty for the first page and the NextCursor of the
+// previous reply, for the same range, for the others.
+type TradeRange struct {
+	FromTime   int64  `json:"from_time,omitempty"`
+	ToTime     int64  `json:"to_time,omitempty"`
+	FromHeight uint64 `json:"from_height,omitempty"`
+	ToHeight   uint64 `json:"to_height,omitempty"`
+	Cursor     string `json:"cursor,omitempty"`
+	Limit      int    `json:"limit,omitempty"` // DefaultTradesLimit if 0
+}
+
+// fillRange returns the index range and page size [r] selects
+func (r *TradeRange) fillRange() (storage.FillRange, int, error) {
+	limit := r.Limit
+	if limit == 0 {
+		limit = DefaultTradesLimit
+	}
+	if limit < 0 || limit > MaxTradesLimit {
+		return storage.FillRange{}, 0, ErrInvalidCursor
+	}
+
+	fr := storage.FillRange{
+		ByTime:     r.FromTime != 0 || r.ToTime != 0,
+		FromTime:   r.FromTime,
+		ToTime:     r.ToTime,
+		FromHeight: r.FromHeight,
+		ToHeight:   r.ToHeight,
+	}
+	if fr.ToTime == 0 {
+		fr.ToTime = math.MaxInt64
+	}
+	if fr.ToHeight == 0 {
+		fr.ToHeight = math.MaxUint64
+	}
+	if r.Cursor != "" {
+		var pos storage.FillPos
+		n, err := fmt.Sscanf(r.Cursor, "%d:%d:%d", &pos.Timestamp, &pos.Height, &pos.Index)
+		if err != nil || n != 3 {
+			return storage.FillRange{}, 0, ErrInvalidCursor
+		}
+		fr.Start = &pos
+	}
+	return fr, limit, nil
+}
+
+// tradeCursor returns the cursor of the page starting at [next]
+func tradeCursor(next *storage.FillPos) string {
+	if next == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d:%d", next.Timestamp, next.Height, next.Index)
+}
+
+// GetTradesArgs represents the request payload for listing the trades of a
+// market
+type GetTradesArgs struct {
+	Market string `json:"market"`
+	TradeRange
+}
+
+// GetTradesReply represents a page of the trades of a market, oldest first
+type GetTradesReply struct {
+	Trades     []Trade `json:"trades"`
+	NextCursor string  `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
+// GetTrades handles listing the past trades of a market from the fill index
+func (h *Handler) GetTrades(req *http.Request, args *GetTradesArgs, reply *GetTradesReply) error {
+	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetTrades")
+	defer span.End()
+
+	r, limit, err := args.fillRange()
+	if err != nil {
+		return err
+	}
+	fills, next, err := storage.GetMarketFillsPage(ctx, h.c.metaDB, args.Market, r, limit)
+	if err != nil {
+		return err
+	}
+	reply.Trades = make([]Trade, len(fills))
+	for i, fill := range fills {
+		reply.Trades[i] = publicTrade(fill)
+	}
+	reply.NextCursor = tradeCursor(next)
+	return nil
+}
+
+// GetAccountTradesArgs represents the request payload for listing the
+// trades of an address
+type GetAccountTradesArgs struct {
+	Address string `json:"address"`
+	TradeRange
+}
+
+// GetAccountTradesReply represents a page of the fills an address took part
+// in, as taker or maker, across markets and oldest first
+type GetAccountTradesReply struct {
+	Fills      []*storage.Fill `json:"fills"`
+	NextCursor string          `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
+// GetAccountTrades handles listing the past fills of an address from the
+// fill index
+func (h *Handler) GetAccountTrades(req *http.Request, args *GetAccountTradesArgs, reply *GetAccountTradesReply) error {
+	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetAccountTrades")
+	defer span.End()
+
+	owner, err := utils.ParseAddress(args.Address)
+	if err != nil {
+		return err
+	}
+	r, limit, err := args.fillRange()
+	if err != nil {
+		return err
+	}
+	fills, next, err := storage.GetAccountFillsPage(ctx, h.c.metaDB, owner, r, limit)
+	if err != nil {
+		return err
+	}
+	reply.Fills = fills
+	reply.NextCursor = tradeCursor(next)
+	return nil
+}
+
+// MaxCandles is the most candles returned by one GetCandles call
+const MaxCandles = 1500
+
+// GetCandlesArgs represents the request payload for retrieving the candles
+// of a market starting in [From, To]. An unset To is open.
+type GetCandlesArgs struct {
+	Market     string `json:"market"`
+	Resolution string `json:"resolution"` // "1m", "5m", "1h" or "1d"
+	From       int64  `json:"from"`
+	To         int64  `json:"to,omitempty"`
+}
+
+// GetCandlesReply represents the candles of a market, oldest first. Periods
+// without trades have no candle, and at most MaxCandles are returned: ask
+// again from after the last one for more.
+type GetCandlesReply struct {
+	Candles []*storage.Candle `json:"candles"`
+}
+
+// GetCandles handles retrieving the OHLCV candles of a market
+func (h *Handler) GetCandles(req *http.Request, args *GetCandlesArgs, reply *GetCandlesReply) error {
+	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetCandles")
+	defer span.End()
+
+	r, err := storage.ParseResolution(args.Resolution)
+	if err != nil {
+		return err
+	}
+	to := args.To
+	if to == 0 {
+		to = math.MaxInt64
+	}
+	reply.Candles, err = storage.GetCandles(ctx, h.c.metaDB, args.Market, r, args.From, to, MaxCandles)
+	return err
+}
+
+// GetMarketStatsArgs represents the request payload for retrieving the
+// statistics of a market
+type GetMarketStatsArgs struct {
+	Market string `json:"market"`
+}
+
+// GetMarketStats handles retrieving the rolling 24h statistics and top of
+// book of a market
+func (h *Handler) GetMarketStats(req *http.Request, args *GetMarketStatsArgs, reply *MarketStats) error {
+	_, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetMarketStats")
+	defer span.End()
+
+	stats, ok := h.c.stats.Get(args.Market)
+	if !ok {
+		return fmt.Errorf("%w: %s", storage.ErrUnknownMarket, args.Market)
+	}
+	*reply = stats
+	return nil
+}
+
+// GetAllMarketStatsReply represents the statistics of every market
+type GetAllMarketStatsReply struct {
+	Markets []MarketStats `json:"markets"` // Sorted by market
+}
+
+// GetAllMarketStats handles retrieving the statistics of every market at
+// once, e.g. for ticker screens
+func (h *Handler) GetAllMarketStats(req *http.Request, _ *struct{}, reply *GetAllMarketStatsReply) error {
+	_, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetAllMarketStats")
+	defer span.End()
+
+	reply.Markets = h.c.stats.All()
+	return nil
+}
+
+// GetOrderBookAtArgs represents the request payload for rebuilding the book
+// of a market as of a past height
+type GetOrderBookAtArgs struct {
+	Market string `json:"market"`
+	Height uint64 `json:"height"`
+}
+
+// GetOrderBookAtReply represents the book of a market as of a past height.
+// Checksum is computed from the rebuilt book; Verified reports whether it
+// matches the checksum indexed when the block was accepted.
+type GetOrderBookAtReply struct {
+	*storage.HistoricalBook
+	Checksum uint32 `json:"checksum"`
+	Verified bool   `json:"verified"`
+}
+
+// GetOrderBookAt handles rebuilding the book of a market as of an accepted
+// height, from the nearest stored book and the order events since, e.g. to
+// investigate incidents or settle disputes
+func (h *Handler) GetOrderBookAt(req *http.Request, args *GetOrderBookAtArgs, reply *GetOrderBookAtReply) error {
+	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetOrderBookAt")
+	defer span.End()
+
+	if _, err := h.c.vm.Snapshot(args.Market); err != nil {
+		return err
+	}
+	if args.Height > h.c.inner.LastAcceptedBlock().Hght {
+		return ErrHeightNotAccepted
+	}
+	book, err := storage.RebuildOrderBook(ctx, h.c.metaDB, args.Market, args.Height)
+	if err != nil {
+		return err
+	}
+	reply.HistoricalBook = book
+	reply.Checksum = storage.Checksum(book.Depth())
+
+	stored, found, err := storage.GetChecksum(ctx, h.c.metaDB, args.Market, args.Height)
+	if err != nil {
+		return err
+	}
+	reply.Verified = found && stored.Sequence == book.Sequence && stored.Checksum == reply.Checksum
+	return nil
+}
+
+// GetChecksumArgs represents the request payload for retrieving the book
+// checksum of a market at a height
+type GetChecksumArgs struct {
+	Market string `json:"market"`
+	Height uint64 `json:"height"`
+}
+
+// GetChecksum handles retrieving the book checksum of a market as of an
+// accepted height, for auditors to check their copy of the book against
+func (h *Handler) GetChecksum(req *http.Request, args *GetChecksumArgs, reply *storage.BookChecksum) error {
+	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetChecksum")
+	defer span.End()
+
+	checksum, found, err := storage.GetChecksum(ctx, h.c.metaDB, args.Market, args.Height)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrChecksumNotFound
+	}
+	*reply = *checksum
+	return nil
+}
+
+// L3 page sizes
+const (
+	DefaultL3Limit = 100
+	MaxL3Limit     = 1000
+)
+
+// GetL3Args represents the request payload for listing the resting orders
+// of a market. Cursor is empty for the first page and the NextCursor of the
+// previous reply for the others. A cursor reads the book at the height of the
+// first page, which the engine only keeps for the last
+// engine.SnapshotHistory (128) accepted heights: a listing must be finished
+// before then, or restarted without a cursor.
+type GetL3Args struct {
+	Market string `json:"market"`
+	Cursor string `json:"cursor,omitempty"`
+	Limit  int    `json:"limit,omitempty"` // DefaultL3Limit if 0
+}
+
+// L3Order represents a resting order and its place in the queue
+type L3Order struct {
+	OrderID   string  `json:"order_id"`
+	Owner     string  `json:"owner"`
+	Side      string  `json:"side"`
+	Price     float64 `json:"price"`
+	Quantity  float64 `json:"quantity"` // Remaining quantity
+	Timestamp int64   `json:"timestamp"`
+	Level     int     `json:"level"`    // Index of the price level on its side, best first
+	Position  int     `json:"position"` // Orders ahead of it at its price level
+}
+
+// GetL3Reply represents a page of the resting orders of a market. Every page
+// of a listing reads the book at the same Height, so NextCursor fails with
+// a snapshot expired error once the last accepted height is past
+// CursorValidThrough.
+type GetL3Reply struct {
+	Orders             []L3Order `json:"orders"`                // Bids then asks, best price and time priority first
+	NextCursor         string    `json:"next_cursor,omitempty"` // Empty on the last page
+	Total              int       `json:"total"`                 // Resting orders at Height
+	Sequence           uint64    `json:"sequence"`
+	Height             uint64    `json:"height"`
+	CursorValidThrough uint64    `json:"cursor_valid_through"` // Last accepted height NextCursor can be read at
+}
+
+// GetL3 handles listing the resting orders of a market order by order
+func (h *Handler) GetL3(req *http.Request, args *GetL3Args, reply *GetL3Reply) error {
+	_, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetL3")
+	defer span.End()
+
+	limit := args.Limit
+	if limit == 0 {
+		limit = DefaultL3Limit
+	}
+	if limit < 0 || limit > MaxL3Limit {
+		return ErrInvalidCursor
+	}
+
+	var (
+		snapshot *storage.BookSnapshot
+		offset   int
+		err      error
+	)
+	if args.Cursor == "" {
+		snapshot, err = h.c.vm.Snapshot(args.Market)
+	} else {
+		var height uint64
+		height, offset, err = parseL3Cursor(args.Cursor)
+		if err != nil {
+			return err
+		}
+		snapshot, err = h.c.vm.SnapshotAt(args.Market, height)
+	}
+	if err != nil {
+		return err
+	}
+
+	page := snapshot.L3(offset, limit)
+	reply.Orders = make([]L3Order, len(page))
+	for i, o := range page {
+		reply.Orders[i] = L3Order{
+			OrderID:   o.ID,
+			Owner:     utils.Address(o.Owner),
+			Side:      string(o.Side),
+			Price:     o.Price,
+			Quantity:  o.Quantity,
+			Timestamp: o.Timestamp.Unix(),
+			Level:     o.Level,
+			Position:  o.Position,
+		}
+	}
+	if next := offset + len(page); next < snapshot.NumOrders() {
+		reply.NextCursor = fmt.Sprintf("%d:%d", snapshot.Height, next)
+	}
+	reply.Total = snapshot.NumOrders()
+	reply.Sequence = snapshot.Sequence
+	reply.Height = snapshot.Height
+	reply.CursorValidThrough = snapshot.Height + engine.SnapshotHistory - 1
+	return nil
+}
+
+// parseL3Cursor returns the height and offset encoded in an L3 cursor
+func parseL3Cursor(cursor string) (uint64, int, error) {
+	var (
+		height uint64
+		offset int
+	)
+	if n, err := fmt.Sscanf(cursor, "%d:%d", &height, &offset); err != nil || n != 2 || offset < 0 {
+		return 0, 0, ErrInvalidCursor
+	}
+	return height, offset, nil
+}
+
+// flattenLevels lists the orders of the given levels in priority order
+func flattenLevels(levels []storage.LevelSnapshot) []storage.Order {
+	orders := []storage.Order{}
+	for _, level := range levels {
+		orders = append(orders, level.Orders...)
+	}
+	return orders
+}
+
 // ListOrdersArgs represents the request payload for listing all orders of a user
 type ListOrdersArgs struct {
 	Address string `json:"address"`
@@ -219,7 +1077,7 @@ type ListOrdersArgs struct {
 
 // ListOrdersReply represents the response containing a list of orders
 type ListOrdersReply struct {
-	Orders []storage.Order `json:"orders"`
+	Orders []*storage.OrderRecord `json:"orders"`
 }
 
 // ListOrders handles listing all orders associated with a specific address
@@ -240,3 +1098,71 @@ func (h *Handler) ListOrders(req *http.Request, args *ListOrdersArgs, reply *Lis
 	reply.Orders = orders
 	return nil
 }
+
+// GetBalanceArgs represents the request payload for retrieving a balance
+type GetBalanceArgs struct {
+	Address string `json:"address"`
+	Asset   ids.ID `json:"asset"` // Empty ID is the native asset
+}
+
+// GetBalanceReply represents the response containing a balance
+type GetBalanceReply struct {
+	Amount   uint64 `json:"amount"`
+	Reserved uint64 `json:"reserved"` // Part of Amount held by resting orders
+}
+
+// GetBalance handles retrieving the balance of an address in an asset
+func (h *Handler) GetBalance(req *http.Request, args *GetBalanceArgs, reply *GetBalanceReply) error {
+	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetBalance")
+	defer span.End()
+
+	address, err := utils.ParseAddress(args.Address)
+	if err != nil {
+		return err
+	}
+
+	balance, err := storage.GetBalanceFromState(ctx, h.c.inner.ReadState, address, args.Asset)
+	if err != nil {
+		return err
+	}
+	reserved, err := storage.GetReservedFromState(ctx, h.c.inner.ReadState, address, args.Asset)
+	if err != nil {
+		return err
+	}
+
+	reply.Amount = balance
+	reply.Reserved = reserved
+	return nil
+}
+
+// GetAssetArgs represents the request payload for retrieving an asset
+type GetAssetArgs struct {
+	Asset ids.ID `json:"asset"`
+}
+
+// GetAssetReply represents the response containing asset metadata
+type GetAssetReply struct {
+	Symbol   []byte `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+	Metadata []byte `json:"metadata"`
+	Supply   uint64 `json:"supply"`
+	Owner    string `json:"owner"`
+}
+
+// GetAsset handles retrieving the metadata of an asset
+func (h *Handler) GetAsset(req *http.Request, args *GetAssetArgs, reply *GetAssetReply) error {
+	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.GetAsset")
+	defer span.End()
+
+	asset, err := storage.GetAssetFromState(ctx, h.c.inner.ReadState, args.Asset)
+	if err != nil {
+		return err
+	}
+
+	reply.Symbol = asset.Symbol
+	reply.Decimals = asset.Decimals
+	reply.Metadata = asset.Metadata
+	reply.Supply = asset.Supply
+	reply.Owner = utils.Address(asset.Owner)
+	return nil
+}
@@ -0,0 +1,457 @@
+// controller/indexer.go
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/hypersdk/chain"
+
+	"CLOB/actions"
+	"CLOB/auth"
+	"CLOB/storage"
+	engine "CLOB/vm"
+)
+
+// Indexer maintains the metadata indexes of orders and fills from the views
+// of accepted blocks. It only reads metaDB and writes to the batch it is
+// given, so a block is indexed atomically with its transactions.
+type Indexer struct {
+	db database.Database
+}
+
+// NewIndexer creates an indexer over [db]
+func NewIndexer(db database.Database) *Indexer {
+	return &Indexer{db: db}
+}
+
+// blockIndex holds the records written while indexing one block, which are
+// not in db until the batch is written
+type blockIndex struct {
+	*Indexer
+
+	batch   database.Batch
+	view    *engine.BlockView
+	records map[string]*storage.OrderRecord
+	states  map[string]*storage.OrderBookState
+	candles map[string]*storage.Candle // By key
+	fills   uint32                     // Fills indexed so far in the block
+	events  uint32                     // Order events indexed so far in the block
+}
+
+// IndexBlock writes the order updates and fills of an accepted block to
+// [batch]. Events are applied in block order, so the records hold the state
+// of each order after the block. Orders that never reached the book, either
+// because their tx failed or because the engine refused them, are recorded
+// as rejected.
+func (i *Indexer) IndexBlock(
+	ctx context.Context,
+	batch database.Batch,
+	blk *chain.StatelessBlock,
+	view *engine.BlockView,
+) error {
+	b := &blockIndex{
+		Indexer: i,
+		batch:   batch,
+		view:    view,
+		records: make(map[string]*storage.OrderRecord),
+		states:  make(map[string]*storage.OrderBookState),
+		candles: make(map[string]*storage.Candle),
+	}
+
+	// Orders expired by dead-man's switches before any tx executed
+	for _, update := range view.PreEvents.Updates {
+		if err := b.storeUpdate(ctx, update, engine.BlockTx{}); err != nil {
+			return err
+		}
+	}
+
+	for t, events := range view.Events {
+		tx := view.Txs[t]
+		for _, update := range events.Updates {
+			if err := b.storeUpdate(ctx, update, tx); err != nil {
+				return err
+			}
+		}
+		for _, fill := range events.Fills {
+			if err := b.storeFill(ctx, fill, tx); err != nil {
+				return err
+			}
+		}
+
+		// Orders refused by the engine leave no event behind
+		updated := make(map[string]struct{}, len(events.Updates))
+		for _, update := range events.Updates {
+			updated[update.Order.ID] = struct{}{}
+		}
+		for _, refused := range refusedOrders(tx.Action, view.Results[t]) {
+			if _, ok := updated[refused.order.ID]; ok {
+				continue
+			}
+			record := storage.NewOrderRecord(refused.order, storage.StatusRejected, rejectReason(refused.err))
+			if err := b.reject(ctx, record, tx); err != nil {
+				return err
+			}
+		}
+
+		switch a := tx.Action.(type) {
+		case *actions.BatchOrdersAction:
+			if err := storage.StoreBatchResults(ctx, b.batch, tx.TxID, batchResults(a, view.Results[t])); err != nil {
+				return err
+			}
+		case *actions.CancelAllAction:
+			if err := storage.StoreCancelled(ctx, b.batch, tx.TxID, a.Cancelled); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Txs that failed on chain never reach the engine
+	results := blk.Results()
+	for t, tx := range blk.Txs {
+		orderTx, ok := tx.Action.(actions.OrderTx)
+		if results[t].Success || !ok {
+			continue
+		}
+		action := orderTx.EngineAction(auth.GetActor(tx.Auth), tx.ID(), blk.GetTimestamp())
+		failed := errors.New(string(results[t].Output))
+		for _, refused := range refusedOrders(action, failed) {
+			record := storage.NewOrderRecord(refused.order, storage.StatusRejected, refused.err.Error())
+			if err := b.reject(ctx, record, engine.BlockTx{TxID: tx.ID()}); err != nil {
+				return err
+			}
+		}
+		if batch, ok := action.(*actions.BatchOrdersAction); ok {
+			if err := storage.StoreBatchResults(ctx, b.batch, tx.ID(), batchResults(batch, failed)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := b.storeStates(ctx); err != nil {
+		return err
+	}
+	return b.storeCandles(ctx)
+}
+
+// refusedOrder is an order an action tried to place and the reason it failed
+type refusedOrder struct {
+	order *storage.Order
+	err   error
+}
+
+// refusedOrders returns the orders [action] failed to place, given the error
+// it returned
+func refusedOrders(action actions.Action, result error) []refusedOrder {
+	var refused []refusedOrder
+	switch a := action.(type) {
+	case *actions.AddOrderAction:
+		if result != nil {
+			refused = append(refused, refusedOrder{a.Order, result})
+		}
+	case *actions.BatchOrdersAction:
+		for i, inner := range a.Actions {
+			add, ok := inner.(*actions.AddOrderAction)
+			if !ok {
+				continue
+			}
+			// Results are missing if the batch never started
+			err := result
+			if i < len(a.Results) {
+				err = a.Results[i]
+			}
+			if err != nil {
+				refused = append(refused, refusedOrder{add.Order, err})
+			}
+		}
+	}
+	return refused
+}
+
+// rejectReason describes why an order was refused. Failed pre-trade checks
+// are recorded by reason alone so clients can tell them apart.
+func rejectReason(err error) string {
+	var rerr *actions.RejectError
+	if errors.As(err, &rerr) {
+		return string(rerr.Reason)
+	}
+	return err.Error()
+}
+
+// batchResults describes the outcome of every instruction of a batch
+func batchResults(batch *actions.BatchOrdersAction, result error) []*storage.InstructionResult {
+	results := make([]*storage.InstructionResult, len(batch.Actions))
+	for i, inner := range batch.Actions {
+		r := &storage.InstructionResult{}
+		switch a := inner.(type) {
+		case *actions.AddOrderAction:
+			r.Kind, r.OrderID, r.ClientOrderID = actions.BatchPlace, a.Order.ID, a.Order.ClientOrderID
+		case *actions.CancelOrderAction:
+			r.Kind, r.OrderID, r.ClientOrderID = actions.BatchCancel, a.OrderID, a.ClientOrderID
+		case *actions.AmendOrderAction:
+			r.Kind, r.OrderID, r.ClientOrderID = actions.BatchAmend, a.OrderID, a.ClientOrderID
+		}
+		err := result
+		if i < len(batch.Results) {
+			err = batch.Results[i]
+		}
+		r.Success = err == nil
+		if err != nil {
+			r.Error = err.Error()
+		}
+		results[i] = r
+	}
+	return results
+}
+
+// getOrder returns the latest record of an order, or nil if it was never
+// indexed
+func (b *blockIndex) getOrder(ctx context.Context, orderID string) (*storage.OrderRecord, error) {
+	if record, ok := b.records[orderID]; ok {
+		return record, nil
+	}
+	record, err := storage.GetOrder(ctx, b.db, orderID)
+	if errors.Is(err, storage.ErrOrderNotFound) {
+		return nil, nil
+	}
+	return record, err
+}
+
+// storeOrder replaces the record of an order with [record]
+func (b *blockIndex) storeOrder(ctx context.Context, record *storage.OrderRecord, tx engine.BlockTx) error {
+	prev, err := b.getOrder(ctx, record.ID)
+	if err != nil {
+		return err
+	}
+	record.TxID = tx.TxID
+	record.Height = b.view.Height
+	if err := storage.StoreOrder(ctx, b.batch, prev, record); err != nil {
+		return err
+	}
+	b.records[record.ID] = record
+	return nil
+}
+
+// storeUpdate records the order update [update] of [tx], both as the latest
+// record of the order and as an event to replay the book from
+func (b *blockIndex) storeUpdate(ctx context.Context, update storage.OrderUpdate, tx engine.BlockTx) error {
+	record := storage.NewOrderRecord(&update.Order, update.Status, update.Reason)
+	if err := b.storeOrder(ctx, record, tx); err != nil {
+		return err
+	}
+	event := &storage.OrderEvent{OrderRecord: *record, Sequence: update.Sequence}
+	if err := storage.StoreOrderEvent(ctx, b.batch, event, b.events); err != nil {
+		return err
+	}
+	b.events++
+	return nil
+}
+
+// reject records an order that never reached the book. An order with the
+// same ID that did is left untouched, as the rejection is not about it.
+func (b *blockIndex) reject(ctx context.Context, record *storage.OrderRecord, tx engine.BlockTx) error {
+	prev, err := b.getOrder(ctx, record.ID)
+	if err != nil {
+		return err
+	}
+	if prev != nil && prev.Status != storage.StatusRejected {
+		return nil
+	}
+	return b.storeOrder(ctx, record, tx)
+}
+
+// storeFill indexes [fill] at its position in the block
+func (b *blockIndex) storeFill(ctx context.Context, fill storage.Fill, tx engine.BlockTx) error {
+	fill.TxID = tx.TxID
+	fill.Height = b.view.Height
+	fill.Timestamp = b.view.Timestamp
+	fill.Index = b.fills
+	b.fills++
+	if err := storage.StoreFill(ctx, b.batch, &fill); err != nil {
+		return err
+	}
+
+	state, err := b.getState(ctx, fill.Market)
+	if err != nil {
+		return err
+	}
+	state.NumFills++
+	state.Volume += fill.Quantity
+	state.LastPrice = fill.Price
+
+	for _, r := range storage.Resolutions {
+		candle, err := b.getCandle(ctx, fill.Market, r, fill.Timestamp)
+		if err != nil {
+			return err
+		}
+		candle.Add(&fill)
+	}
+	return nil
+}
+
+// getCandle returns the candle of [market] at resolution [r] containing
+// [timestamp], loading it on first use
+func (b *blockIndex) getCandle(ctx context.Context, market string, r storage.Resolution, timestamp int64) (*storage.Candle, error) {
+	start := r.Start(timestamp)
+	key := string(storage.CandleKey(market, r, start))
+	if candle, ok := b.candles[key]; ok {
+		return candle, nil
+	}
+	candle, found, err := storage.GetCandle(ctx, b.db, market, r, start)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		candle = storage.NewCandle(market, r, timestamp)
+	}
+	b.candles[key] = candle
+	return candle, nil
+}
+
+// storeCandles writes every candle the block's fills went into
+func (b *blockIndex) storeCandles(ctx context.Context) error {
+	for _, candle := range b.candles {
+		if err := storage.StoreCandle(ctx, b.batch, candle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillCandles builds the candle index from the indexed fills, unless it
+// already covers them, e.g. after the candle index was dropped or when the
+// fills were indexed before candles existed. Candles are rebuilt from
+// scratch, so the result is the same as if they had been kept all along.
+func (i *Indexer) BackfillCandles(ctx context.Context) error {
+	built, err := storage.CandlesBuilt(ctx, i.db)
+	if err != nil || built {
+		return err
+	}
+
+	candles := make(map[string]*storage.Candle)
+	err = storage.ForEachFill(ctx, i.db, func(fill *storage.Fill) error {
+		for _, r := range storage.Resolutions {
+			key := string(storage.CandleKey(fill.Market, r, r.Start(fill.Timestamp)))
+			candle, ok := candles[key]
+			if !ok {
+				candle = storage.NewCandle(fill.Market, r, fill.Timestamp)
+				candles[key] = candle
+			}
+			candle.Add(fill)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	batch := i.db.NewBatch()
+	defer batch.Reset()
+	for _, candle := range candles {
+		if err := storage.StoreCandle(ctx, batch, candle); err != nil {
+			return err
+		}
+	}
+	if err := storage.SetCandlesBuilt(ctx, batch); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// getState returns the indexed state of a market, loading it on first use
+func (b *blockIndex) getState(ctx context.Context, market string) (*storage.OrderBookState, error) {
+	if state, ok := b.states[market]; ok {
+		return state, nil
+	}
+	state, err := storage.GetOrderBookState(ctx, b.db, market)
+	if err != nil {
+		return nil, err
+	}
+	b.states[market] = state
+	return state, nil
+}
+
+// storeStates writes the state and book checksum of every market the block
+// touched
+func (b *blockIndex) storeStates(ctx context.Context) error {
+	for market := range b.view.Touched {
+		state, err := b.getState(ctx, market)
+		if err != nil {
+			return err
+		}
+		state.Height = b.view.Height
+		state.Timestamp = b.view.Timestamp
+		if err := storage.StoreOrderBookState(ctx, b.batch, state); err != nil {
+			return err
+		}
+
+		snapshot, ok := b.view.Snapshots[market]
+		if !ok {
+			continue
+		}
+		checksum := &storage.BookChecksum{
+			Market:   market,
+			Height:   b.view.Height,
+			Sequence: snapshot.Sequence,
+			Checksum: snapshot.Checksum,
+		}
+		if err := storage.StoreChecksum(ctx, b.batch, checksum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StoreHistory stores the books of every market in [books], as of the block
+// at [height], if it falls on [interval], and prunes the history older than
+// [retention] blocks. A zero [interval] stores nothing and a zero
+// [retention] prunes nothing.
+func (i *Indexer) StoreHistory(
+	ctx context.Context,
+	batch database.Batch,
+	books engine.Snapshots,
+	height uint64,
+	interval uint64,
+	retention uint64,
+) error {
+	if interval == 0 || height%interval != 0 {
+		return nil
+	}
+	for market, snapshot := range books {
+		if err := storage.StoreStoredBook(ctx, batch, storage.NewStoredBook(snapshot)); err != nil {
+			return err
+		}
+		if retention == 0 || height < retention {
+			continue
+		}
+		if err := storage.PruneOrderHistory(ctx, i.db, batch, market, height-retention); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InitHistory stores the current book of every market in [books] that has
+// no stored book yet, so the history of a new chain, or of a node that never
+// kept one, starts at the books' height
+func (i *Indexer) InitHistory(ctx context.Context, books engine.Snapshots) error {
+	batch := i.db.NewBatch()
+	defer batch.Reset()
+
+	for market, snapshot := range books {
+		_, found, err := storage.GetStoredBook(ctx, i.db, market, math.MaxUint64)
+		if err != nil {
+			return err
+		}
+		if found {
+			continue
+		}
+		if err := storage.StoreStoredBook(ctx, batch, storage.NewStoredBook(snapshot)); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
@@ -0,0 +1,68 @@
+// controller/indexer_test.go
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/database/memdb"
+
+	"CLOB/storage"
+)
+
+func TestBackfillCandles(t *testing.T) {
+	ctx := context.Background()
+	db := memdb.New()
+	fills := []storage.Fill{
+		{Market: "a", Price: 10, Quantity: 1, Height: 1, Timestamp: 3_600},
+		{Market: "b", Price: 50, Quantity: 4, Height: 1, Timestamp: 3_600, Index: 1},
+		{Market: "a", Price: 12, Quantity: 2, Height: 2, Timestamp: 3_659},
+		{Market: "a", Price: 11, Quantity: 1, Height: 3, Timestamp: 3_660},
+	}
+	for i := range fills {
+		if err := storage.StoreFill(ctx, db, &fills[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	indexer := NewIndexer(db)
+	if err := indexer.BackfillCandles(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		market string
+		r      storage.Resolution
+		start  int64
+		want   storage.Candle
+	}{
+		{"a", storage.Minute, 3_600, storage.Candle{Open: 10, High: 12, Low: 10, Close: 12, Volume: 3, QuoteVolume: 34, Trades: 2}},
+		{"a", storage.Minute, 3_660, storage.Candle{Open: 11, High: 11, Low: 11, Close: 11, Volume: 1, QuoteVolume: 11, Trades: 1}},
+		{"a", storage.Hour, 3_600, storage.Candle{Open: 10, High: 12, Low: 10, Close: 11, Volume: 4, QuoteVolume: 45, Trades: 3}},
+		{"b", storage.Day, 0, storage.Candle{Open: 50, High: 50, Low: 50, Close: 50, Volume: 4, QuoteVolume: 200, Trades: 1}},
+	}
+	for _, tt := range tests {
+		got, found, err := storage.GetCandle(ctx, db, tt.market, tt.r, tt.start)
+		if err != nil || !found {
+			t.Fatalf("%s candle of %s at %d not found: %v", tt.r, tt.market, tt.start, err)
+		}
+		tt.want.Market, tt.want.Resolution, tt.want.Start = tt.market, tt.r, tt.start
+		if *got != tt.want {
+			t.Fatalf("%s candle of %s at %d is %+v, want %+v", tt.r, tt.market, tt.start, *got, tt.want)
+		}
+	}
+
+	// Once built, the index is left alone
+	if built, err := storage.CandlesBuilt(ctx, db); err != nil || !built {
+		t.Fatalf("candles not marked built: %v", err)
+	}
+	if err := db.Delete(storage.CandleKey("a", storage.Day, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := indexer.BackfillCandles(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := storage.GetCandle(ctx, db, "a", storage.Day, 0); found {
+		t.Fatal("rebuilt candles that were already built")
+	}
+}
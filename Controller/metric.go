@@ -3,16 +3,45 @@
 package controller
 
 import (
+	"errors"
+
+	ametrics "github.com/ava-labs/avalanchego/api/metrics"
 	"github.com/prometheus/client_golang/prometheus"
+
+	"CLOB/actions"
+	"CLOB/storage"
+	engine "CLOB/vm"
 )
 
+// depthBand is how far from the mid price the depth gauge counts orders
+const depthBand = 0.01
+
+// rejectOther labels refusals that are not failed pre-trade checks
+const rejectOther = "other"
+
 type Metrics struct {
 	addOrder    prometheus.Counter
 	cancelOrder prometheus.Counter
 	matchOrder  prometheus.Counter
+
+	// Top of book, by market as of the last accepted block
+	bestBid       *prometheus.GaugeVec
+	bestAsk       *prometheus.GaugeVec
+	spread        *prometheus.GaugeVec
+	restingOrders *prometheus.GaugeVec
+	priceLevels   *prometheus.GaugeVec
+	depthNearMid  *prometheus.GaugeVec // By market and side
+
+	fills   *prometheus.CounterVec // By market
+	volume  *prometheus.CounterVec // By market, in the base asset
+	rejects *prometheus.CounterVec // By reason
+
+	matchLatency  prometheus.Histogram
+	levelsCrossed prometheus.Histogram
 }
 
 func newMetrics(gatherer ametrics.MultiGatherer) (*Metrics, error) {
+	market := []string{"market"}
 	m := &Metrics{
 		addOrder: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "orderbook_add_order_total",
@@ -26,21 +55,75 @@ func newMetrics(gatherer ametrics.MultiGatherer) (*Metrics, error) {
 			Name: "orderbook_match_order_total",
 			Help: "Total number of MatchOrder actions executed",
 		}),
+		bestBid: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "orderbook_best_bid",
+			Help: "Highest resting bid price, 0 if there is none",
+		}, market),
+		bestAsk: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "orderbook_best_ask",
+			Help: "Lowest resting ask price, 0 if there is none",
+		}, market),
+		spread: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "orderbook_spread",
+			Help: "Best ask minus best bid, 0 unless both sides have orders",
+		}, market),
+		restingOrders: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "orderbook_resting_orders",
+			Help: "Number of orders resting in the book",
+		}, market),
+		priceLevels: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "orderbook_price_levels",
+			Help: "Number of non-empty price levels in the book",
+		}, market),
+		depthNearMid: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "orderbook_depth_near_mid",
+			Help: "Resting quantity priced within 1% of the mid price",
+		}, []string{"market", "side"}),
+		fills: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "orderbook_fills_total",
+			Help: "Total number of fills",
+		}, market),
+		volume: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "orderbook_volume_total",
+			Help: "Total filled quantity, in the base asset",
+		}, market),
+		rejects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "orderbook_rejects_total",
+			Help: "Total number of orders refused, by reason",
+		}, []string{"reason"}),
+		matchLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "orderbook_match_latency_seconds",
+			Help:    "Time the matching engine took to execute an order tx",
+			Buckets: prometheus.ExponentialBuckets(1e-6, 4, 12),
+		}),
+		levelsCrossed: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "orderbook_levels_crossed",
+			Help:    "Number of price levels an incoming order matched against",
+			Buckets: []float64{0, 1, 2, 3, 5, 10, 20, 50, 100},
+		}),
 	}
 
 	// Register metrics
 	registry := prometheus.NewRegistry()
-	err := registry.Register(m.addOrder)
-	if err != nil {
-		return nil, err
-	}
-	err = registry.Register(m.cancelOrder)
-	if err != nil {
-		return nil, err
-	}
-	err = registry.Register(m.matchOrder)
-	if err != nil {
-		return nil, err
+	for _, c := range []prometheus.Collector{
+		m.addOrder,
+		m.cancelOrder,
+		m.matchOrder,
+		m.bestBid,
+		m.bestAsk,
+		m.spread,
+		m.restingOrders,
+		m.priceLevels,
+		m.depthNearMid,
+		m.fills,
+		m.volume,
+		m.rejects,
+		m.matchLatency,
+		m.levelsCrossed,
+	} {
+		if err := registry.Register(c); err != nil {
+			return nil, err
+		}
 	}
 
 	// Add registry to the gatherer
@@ -48,3 +131,87 @@ func newMetrics(gatherer ametrics.MultiGatherer) (*Metrics, error) {
 
 	return m, nil
 }
+
+// observeBooks sets the book gauges of every market in [books]
+func (m *Metrics) observeBooks(books engine.Snapshots) {
+	for market, s := range books {
+		var bid, ask, spread float64
+		if len(s.Bids) > 0 {
+			bid = s.Bids[0].Price
+		}
+		if len(s.Asks) > 0 {
+			ask = s.Asks[0].Price
+		}
+		if bid > 0 && ask > 0 {
+			spread = ask - bid
+		}
+		m.bestBid.WithLabelValues(market).Set(bid)
+		m.bestAsk.WithLabelValues(market).Set(ask)
+		m.spread.WithLabelValues(market).Set(spread)
+		m.restingOrders.WithLabelValues(market).Set(float64(s.NumOrders()))
+		m.priceLevels.WithLabelValues(market).Set(float64(len(s.Bids) + len(s.Asks)))
+
+		bidDepth, askDepth := s.DepthNearMid(depthBand)
+		m.depthNearMid.WithLabelValues(market, string(storage.Buy)).Set(bidDepth)
+		m.depthNearMid.WithLabelValues(market, string(storage.Sell)).Set(askDepth)
+	}
+}
+
+// observeBlock records the fills, refusals and matching work of an accepted
+// block, and the books it changed
+func (m *Metrics) observeBlock(view *engine.BlockView) {
+	for t, events := range view.Events {
+		m.matchLatency.Observe(view.Latencies[t].Seconds())
+
+		levels := make(map[string]map[float64]struct{}) // Prices crossed by taker order
+		for _, fill := range events.Fills {
+			m.fills.WithLabelValues(fill.Market).Inc()
+			m.volume.WithLabelValues(fill.Market).Add(fill.Quantity)
+			if levels[fill.TakerOrderID] == nil {
+				levels[fill.TakerOrderID] = make(map[float64]struct{})
+			}
+			levels[fill.TakerOrderID][fill.Price] = struct{}{}
+		}
+		refused := make(map[string]struct{})
+		for _, r := range refusedOrders(view.Txs[t].Action, view.Results[t]) {
+			refused[r.order.ID] = struct{}{}
+			m.reject(r.err)
+		}
+		for _, order := range placedOrders(view.Txs[t].Action) {
+			if _, ok := refused[order.ID]; !ok {
+				m.levelsCrossed.Observe(float64(len(levels[order.ID])))
+			}
+		}
+	}
+	m.observeBooks(view.Snapshots)
+}
+
+// reject counts an order refused with [err], by pre-trade check if it failed
+// one
+func (m *Metrics) reject(err error) {
+	reason := rejectOther
+	var rerr *actions.RejectError
+	if errors.As(err, &rerr) {
+		reason = string(rerr.Reason)
+	} else if r, ok := actions.ParseRejectReason(err.Error()); ok {
+		reason = string(r)
+	}
+	m.rejects.WithLabelValues(reason).Inc()
+}
+
+// placedOrders returns the orders [action] tries to place
+func placedOrders(action actions.Action) []*storage.Order {
+	switch a := action.(type) {
+	case *actions.AddOrderAction:
+		return []*storage.Order{a.Order}
+	case *actions.BatchOrdersAction:
+		var orders []*storage.Order
+		for _, inner := range a.Actions {
+			if add, ok := inner.(*actions.AddOrderAction); ok {
+				orders = append(orders, add.Order)
+			}
+		}
+		return orders
+	}
+	return nil
+}
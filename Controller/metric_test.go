@@ -0,0 +1,80 @@
+// controller/metric_test.go
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"CLOB/actions"
+)
+
+// testGatherer keeps the registry the metrics register
+type testGatherer struct {
+	prometheus.Gatherer
+}
+
+func (g *testGatherer) Register(_ string, gatherer prometheus.Gatherer) error {
+	g.Gatherer = gatherer
+	return nil
+}
+
+// rejects returns the count of refused orders by reason label
+func rejects(t *testing.T, g prometheus.Gatherer) map[string]float64 {
+	t.Helper()
+	families, err := g.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != "orderbook_rejects_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "reason" {
+					counts[label.GetValue()] = m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return counts
+}
+
+func TestRejectLabels(t *testing.T) {
+	g := &testGatherer{}
+	m, err := newMetrics(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	throttled := &actions.RejectError{Reason: actions.RejectThrottled, Detail: "too many orders"}
+	for _, err := range []error{
+		throttled,
+		fmt.Errorf("engine refused order: %w", throttled),
+		errors.New(string(actions.RejectInsufficientBalance.Output())), // Failed on chain
+		errors.New("order already exists"),
+		errors.New("order_to_trade_throttled, but not a reason"),
+	} {
+		m.reject(err)
+	}
+
+	want := map[string]float64{
+		string(actions.RejectThrottled):           2,
+		string(actions.RejectInsufficientBalance): 1,
+		rejectOther: 2,
+	}
+	got := rejects(t, g)
+	if len(got) != len(want) {
+		t.Fatalf("reject counts %v, want %v", got, want)
+	}
+	for reason, n := range want {
+		if got[reason] != n {
+			t.Fatalf("reject counts %v, want %v", got, want)
+		}
+	}
+}
@@ -27,12 +27,20 @@ func init() {
 		// Register Actions
 		consts.ActionRegistry.Register(&actions.AddOrder{}, actions.UnmarshalAddOrder, false),
 		consts.ActionRegistry.Register(&actions.CancelOrder{}, actions.UnmarshalCancelOrder, false),
+		consts.ActionRegistry.Register(&actions.BatchOrders{}, actions.UnmarshalBatchOrders, false),
+		consts.ActionRegistry.Register(&actions.CancelAll{}, actions.UnmarshalCancelAll, false),
+		consts.ActionRegistry.Register(&actions.SetCancelAfter{}, actions.UnmarshalSetCancelAfter, false),
 		consts.ActionRegistry.Register(&actions.MatchOrder{}, actions.UnmarshalMatchOrder, false),
+		consts.ActionRegistry.Register(&actions.CreateAssetAction{}, actions.UnmarshalCreateAsset, false),
+		consts.ActionRegistry.Register(&actions.MintAssetAction{}, actions.UnmarshalMintAsset, false),
+		consts.ActionRegistry.Register(&actions.BurnAssetAction{}, actions.UnmarshalBurnAsset, false),
+		consts.ActionRegistry.Register(&actions.TransferAction{}, actions.UnmarshalTransfer, false),
+		consts.ActionRegistry.Register(&actions.Settle{}, actions.UnmarshalSettle, false),
 
 		// Register Auth Types
 		consts.AuthRegistry.Register(&auth.ED25519{}, auth.UnmarshalED25519, false),
 	)
-	
+
 	// If any errors occurred during registration, panic to prevent the application from starting incorrectly
 	if errs.Errored() {
 		panic(errs.Err)
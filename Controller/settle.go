@@ -0,0 +1,147 @@
+// controller/settle.go
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"sort"
+
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/crypto"
+	"go.uber.org/zap"
+
+	"CLOB/actions"
+	"CLOB/storage"
+	engine "CLOB/vm"
+)
+
+// maxSettledOrders bounds the orders (or accounts that only traded) one
+// Settle transaction reports, so it stays well below the network size limit
+// however busy the block was
+const maxSettledOrders = 1024
+
+// settle submits the Settle transactions reporting what the engine did in
+// the block of [view], if this node holds the operator key.
+// Settling is best effort: a settlement that fails to reach a block leaves
+// the reservations of its orders in place and its trades uncounted, which
+// only makes the chain stricter, and owners can still release reservations
+// by cancelling.
+func (c *Controller) settle(ctx context.Context, view *engine.BlockView) {
+	key, ok := c.config.GetOperatorKey()
+	if !ok {
+		return
+	}
+	operator, ok := c.genesis.Rules(view.Timestamp).GetOperator()
+	if !ok || key.PublicKey() != operator {
+		return
+	}
+
+	settlements := settlements(view)
+	if len(settlements) == 0 {
+		return
+	}
+	txs := make([]*chain.Transaction, 0, len(settlements))
+	for _, settle := range settlements {
+		tx, err := c.signTx(ctx, key, settle)
+		if err != nil {
+			c.inner.Logger().Warn("unable to sign settlement", zap.Uint64("height", view.Height), zap.Error(err))
+			return
+		}
+		txs = append(txs, tx)
+	}
+	for i, err := range c.inner.Submit(ctx, false, txs) {
+		if err != nil {
+			c.inner.Logger().Warn(
+				"unable to submit settlement",
+				zap.Uint64("height", view.Height),
+				zap.String("market", settlements[i].Market),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// settlements returns the Settle actions reporting, for every account, the
+// orders closed in the block of [view] (expired before its txs, filled,
+// cancelled or expired by them, or refused by the engine after their tx
+// reserved for them) and the trades it took part in. They are ordered by
+// market, then owner, so every node would build the same ones.
+func settlements(view *engine.BlockView) []*actions.Settle {
+	accounts := make(map[string]map[crypto.PublicKey]*actions.SettledAccount) // By market and owner
+	account := func(market string, owner crypto.PublicKey) *actions.SettledAccount {
+		if accounts[market] == nil {
+			accounts[market] = make(map[crypto.PublicKey]*actions.SettledAccount)
+		}
+		if accounts[market][owner] == nil {
+			accounts[market][owner] = &actions.SettledAccount{Owner: owner}
+		}
+		return accounts[market][owner]
+	}
+	closeOrder := func(order *storage.Order) {
+		a := account(order.Market, order.Owner)
+		a.Closed = append(a.Closed, order.ID)
+	}
+	for _, update := range view.PreEvents.Updates {
+		if update.Status.Final() {
+			closeOrder(&update.Order)
+		}
+	}
+	for t, events := range view.Events {
+		for _, fill := range events.Fills {
+			account(fill.Market, fill.Taker).Trades++
+			account(fill.Market, fill.Maker).Trades++
+		}
+		for _, update := range events.Updates {
+			if update.Status.Final() {
+				closeOrder(&update.Order)
+			}
+		}
+		for _, refused := range refusedOrders(view.Txs[t].Action, view.Results[t]) {
+			closeOrder(refused.order)
+		}
+	}
+
+	markets := make([]string, 0, len(accounts))
+	for market := range accounts {
+		markets = append(markets, market)
+	}
+	sort.Strings(markets)
+
+	var settlements []*actions.Settle
+	for _, market := range markets {
+		owners := make([]crypto.PublicKey, 0, len(accounts[market]))
+		for owner := range accounts[market] {
+			owners = append(owners, owner)
+		}
+		sort.Slice(owners, func(i, j int) bool { return bytes.Compare(owners[i][:], owners[j][:]) < 0 })
+
+		// Accounts with many closed orders are split across settlements,
+		// their trades going with the first part
+		var (
+			settle *actions.Settle
+			orders int
+		)
+		for _, owner := range owners {
+			a := accounts[market][owner]
+			for orderIDs, trades := a.Closed, a.Trades; len(orderIDs) > 0 || trades > 0; trades = 0 {
+				if settle == nil || orders >= maxSettledOrders {
+					settle = &actions.Settle{Market: market, Height: view.Height, Timestamp: view.Timestamp}
+					settlements = append(settlements, settle)
+					orders = 0
+				}
+				n := len(orderIDs)
+				if n > maxSettledOrders-orders {
+					n = maxSettledOrders - orders
+				}
+				settle.Accounts = append(settle.Accounts, actions.SettledAccount{Owner: owner, Closed: orderIDs[:n], Trades: trades})
+				orders += n
+				if n == 0 {
+					orders++ // Accounts that only traded take up room too
+				}
+				orderIDs = orderIDs[n:]
+			}
+		}
+	}
+	return settlements
+}
@@ -0,0 +1,81 @@
+// controller/settle_test.go
+
+package controller
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ava-labs/hypersdk/crypto"
+
+	"CLOB/actions"
+	"CLOB/storage"
+	engine "CLOB/vm"
+)
+
+func TestSettlements(t *testing.T) {
+	alice, bob := crypto.PublicKey{1}, crypto.PublicKey{2}
+	order := func(id string, market string, owner crypto.PublicKey) storage.Order {
+		return storage.Order{ID: id, Market: market, Owner: owner}
+	}
+	refused := &actions.AddOrderAction{Order: &storage.Order{ID: "refused", Market: "a", Owner: alice}}
+
+	view := &engine.BlockView{
+		BlockInfo: engine.BlockInfo{Height: 7, Timestamp: 70},
+		PreEvents: storage.BookEvents{Updates: []storage.OrderUpdate{
+			{Order: order("expired", "b", bob), Status: storage.StatusExpired},
+		}},
+		Txs: []engine.BlockTx{
+			{Action: &actions.AddOrderAction{Order: &storage.Order{ID: "taker", Market: "a", Owner: bob}}},
+			{Action: refused},
+		},
+		Results: []error{nil, errors.New("refused")},
+		Events: []storage.BookEvents{
+			{Fills: []storage.Fill{
+				{Market: "a", TakerOrderID: "taker", MakerOrderID: "maker", Taker: bob, Maker: alice},
+			}, Updates: []storage.OrderUpdate{
+				{Order: order("maker", "a", alice), Status: storage.StatusPartiallyFilled},
+				{Order: order("taker", "a", bob), Status: storage.StatusFilled},
+				{Order: order("resting", "a", bob), Status: storage.StatusOpen},
+			}},
+			{},
+		},
+	}
+
+	want := []*actions.Settle{
+		{Market: "a", Height: 7, Timestamp: 70, Accounts: []actions.SettledAccount{
+			{Owner: alice, Closed: []string{"refused"}, Trades: 1},
+			{Owner: bob, Closed: []string{"taker"}, Trades: 1},
+		}},
+		{Market: "b", Height: 7, Timestamp: 70, Accounts: []actions.SettledAccount{
+			{Owner: bob, Closed: []string{"expired"}},
+		}},
+	}
+	if got := settlements(view); !reflect.DeepEqual(got, want) {
+		t.Fatalf("settlements %+v, want %+v", got, want)
+	}
+}
+
+func TestSettlementsSplitLargeBlocks(t *testing.T) {
+	owner := crypto.PublicKey{1}
+	var updates []storage.OrderUpdate
+	for i := 0; i < maxSettledOrders+1; i++ {
+		updates = append(updates, storage.OrderUpdate{
+			Order:  storage.Order{ID: storage.NewOrderID([32]byte{byte(i), byte(i >> 8)}, 0), Market: "a", Owner: owner},
+			Status: storage.StatusCancelled,
+		})
+	}
+	view := &engine.BlockView{PreEvents: storage.BookEvents{Updates: updates}}
+
+	got := settlements(view)
+	if len(got) != 2 {
+		t.Fatalf("%d settlements, want 2", len(got))
+	}
+	if n := len(got[0].Accounts[0].Closed); n != maxSettledOrders {
+		t.Fatalf("first settlement closes %d orders, want %d", n, maxSettledOrders)
+	}
+	if n := len(got[1].Accounts[0].Closed); n != 1 {
+		t.Fatalf("second settlement closes %d orders, want 1", n)
+	}
+}
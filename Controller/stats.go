@@ -0,0 +1,196 @@
+// controller/stats.go
+
+package controller
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/ava-labs/avalanchego/database"
+
+	"CLOB/storage"
+	engine "CLOB/vm"
+)
+
+// StatsWindow is the period, in seconds, market statistics cover. It rolls
+// by whole minutes as blocks are accepted.
+const StatsWindow = 24 * 60 * 60
+
+// MarketStats are the rolling trading statistics of a market and its top of
+// book. Prices are 0 when there is nothing to report, e.g. no trade in the
+// window or an empty side of the book.
+type MarketStats struct {
+	Market    string  `json:"market"`
+	LastPrice float64 `json:"last_price"` // Of the last trade ever
+
+	Open        float64 `json:"open"` // Price of the first trade in the window
+	High        float64 `json:"high"`
+	Low         float64 `json:"low"`
+	Volume      float64 `json:"volume"`       // In the base asset
+	QuoteVolume float64 `json:"quote_volume"` // In the quote asset
+	Trades      uint64  `json:"trades"`
+
+	BestBid  float64 `json:"best_bid"`
+	BestAsk  float64 `json:"best_ask"`
+	Spread   float64 `json:"spread"`    // BestAsk - BestBid, 0 unless both sides are set
+	MidPrice float64 `json:"mid_price"` // 0 unless both sides are set
+
+	Height    uint64 `json:"height"`    // Block the statistics are as of
+	Timestamp int64  `json:"timestamp"` // Timestamp of that block
+}
+
+// marketWindow holds the minute candles of a market within StatsWindow
+type marketWindow struct {
+	candles   []*storage.Candle // Oldest first
+	lastPrice float64
+}
+
+// StatsTracker keeps the statistics of every market up to date as blocks
+// are accepted, so reading them never scans trades
+type StatsTracker struct {
+	mu      sync.RWMutex
+	windows map[string]*marketWindow
+	stats   map[string]MarketStats
+}
+
+// NewStatsTracker loads the window of every market of [books] from the
+// minute candles in [db], as of the block at [height] and [timestamp]
+func NewStatsTracker(
+	ctx context.Context,
+	db database.Database,
+	books engine.Snapshots,
+	height uint64,
+	timestamp int64,
+) (*StatsTracker, error) {
+	t := &StatsTracker{
+		windows: make(map[string]*marketWindow, len(books)),
+		stats:   make(map[string]MarketStats, len(books)),
+	}
+	for market, snapshot := range books {
+		from := storage.Minute.Start(timestamp - StatsWindow + int64(storage.Minute))
+		candles, err := storage.GetCandles(ctx, db, market, storage.Minute, from, math.MaxInt64, 0)
+		if err != nil {
+			return nil, err
+		}
+		state, err := storage.GetOrderBookState(ctx, db, market)
+		if err != nil {
+			return nil, err
+		}
+		w := &marketWindow{candles: candles, lastPrice: state.LastPrice}
+		t.windows[market] = w
+		t.stats[market] = w.stats(snapshot, height, timestamp)
+	}
+	return t, nil
+}
+
+// Update rolls every window forward to the accepted block of [view] and
+// adds the block's fills. Markets the block left alone are only recomputed
+// if trades fell out of their window.
+func (t *StatsTracker) Update(view *engine.BlockView, books engine.Snapshots) {
+	fills := make(map[string][]storage.Fill)
+	for _, events := range view.Events {
+		for _, fill := range events.Fills {
+			fills[fill.Market] = append(fills[fill.Market], fill)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for market, snapshot := range books {
+		w, ok := t.windows[market]
+		if !ok {
+			w = &marketWindow{}
+			t.windows[market] = w
+		}
+		_, touched := view.Snapshots[market]
+		evicted := w.evict(view.Timestamp)
+		for i := range fills[market] {
+			w.add(&fills[market][i], view.Timestamp)
+		}
+		if !ok || touched || evicted {
+			t.stats[market] = w.stats(snapshot, view.Height, view.Timestamp)
+			continue
+		}
+		s := t.stats[market]
+		s.Height, s.Timestamp = view.Height, view.Timestamp
+		t.stats[market] = s
+	}
+}
+
+// Get returns the statistics of [market], or false if it does not exist
+func (t *StatsTracker) Get(market string) (MarketStats, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	s, ok := t.stats[market]
+	return s, ok
+}
+
+// All returns the statistics of every market, sorted by market
+func (t *StatsTracker) All() []MarketStats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	all := make([]MarketStats, 0, len(t.stats))
+	for _, s := range t.stats {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Market < all[j].Market })
+	return all
+}
+
+// evict drops the candles that ended before the window as of [timestamp],
+// and reports whether there were any
+func (w *marketWindow) evict(timestamp int64) bool {
+	from := storage.Minute.Start(timestamp - StatsWindow + int64(storage.Minute))
+	n := 0
+	for n < len(w.candles) && w.candles[n].Start < from {
+		n++
+	}
+	w.candles = w.candles[n:]
+	return n > 0
+}
+
+// add adds [fill] of a block at [timestamp] to the window
+func (w *marketWindow) add(fill *storage.Fill, timestamp int64) {
+	start := storage.Minute.Start(timestamp)
+	if n := len(w.candles); n == 0 || w.candles[n-1].Start != start {
+		w.candles = append(w.candles, storage.NewCandle(fill.Market, storage.Minute, timestamp))
+	}
+	w.candles[len(w.candles)-1].Add(fill)
+	w.lastPrice = fill.Price
+}
+
+// stats aggregates the window with the top of [snapshot]
+func (w *marketWindow) stats(snapshot *storage.BookSnapshot, height uint64, timestamp int64) MarketStats {
+	s := MarketStats{
+		Market:    snapshot.Market,
+		LastPrice: w.lastPrice,
+		Height:    height,
+		Timestamp: timestamp,
+	}
+	for _, c := range w.candles {
+		if s.Trades == 0 {
+			s.Open, s.High, s.Low = c.Open, c.High, c.Low
+		}
+		s.High = math.Max(s.High, c.High)
+		s.Low = math.Min(s.Low, c.Low)
+		s.Volume += c.Volume
+		s.QuoteVolume += c.QuoteVolume
+		s.Trades += c.Trades
+	}
+	if len(snapshot.Bids) > 0 {
+		s.BestBid = snapshot.Bids[0].Price
+	}
+	if len(snapshot.Asks) > 0 {
+		s.BestAsk = snapshot.Asks[0].Price
+	}
+	if s.BestBid > 0 && s.BestAsk > 0 {
+		s.Spread = s.BestAsk - s.BestBid
+		s.MidPrice = (s.BestAsk + s.BestBid) / 2
+	}
+	return s
+}
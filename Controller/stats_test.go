@@ -0,0 +1,77 @@
+// controller/stats_test.go
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/database/memdb"
+
+	"CLOB/storage"
+	engine "CLOB/vm"
+)
+
+func TestStatsRollingWindow(t *testing.T) {
+	book := &storage.BookSnapshot{
+		Market: "a",
+		Bids:   []storage.LevelSnapshot{{Price: 9, Quantity: 1}},
+		Asks:   []storage.LevelSnapshot{{Price: 11, Quantity: 1}},
+	}
+	books := engine.Snapshots{"a": book}
+	tracker, err := NewStatsTracker(context.Background(), memdb.New(), books, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	height := uint64(0)
+	accept := func(timestamp int64, fills ...storage.Fill) MarketStats {
+		t.Helper()
+		height++
+		view := &engine.BlockView{BlockInfo: engine.BlockInfo{Height: height, Timestamp: timestamp}}
+		if len(fills) > 0 {
+			view.Events = []storage.BookEvents{{Fills: fills}}
+			view.Snapshots = books
+		}
+		tracker.Update(view, books)
+		s, ok := tracker.Get("a")
+		if !ok {
+			t.Fatal("no statistics")
+		}
+		if s.Height != height || s.Timestamp != timestamp {
+			t.Fatalf("statistics as of %d at %d, want %d at %d", s.Height, s.Timestamp, height, timestamp)
+		}
+		return s
+	}
+
+	const first = 1_000 // In the minute starting at 960
+	s := accept(first, storage.Fill{Market: "a", Price: 10, Quantity: 1}, storage.Fill{Market: "a", Price: 12, Quantity: 2})
+	if s.Trades != 2 || s.Open != 10 || s.High != 12 || s.Low != 10 || s.Volume != 3 || s.QuoteVolume != 34 || s.LastPrice != 12 {
+		t.Fatalf("statistics %+v after the first trades", s)
+	}
+	if s.BestBid != 9 || s.BestAsk != 11 || s.Spread != 2 || s.MidPrice != 10 {
+		t.Fatalf("top of book %+v, want 9/11", s)
+	}
+
+	s = accept(first+3_600, storage.Fill{Market: "a", Price: 8, Quantity: 1})
+	if s.Trades != 3 || s.Open != 10 || s.High != 12 || s.Low != 8 || s.LastPrice != 8 {
+		t.Fatalf("statistics %+v an hour later", s)
+	}
+
+	// The window is the last StatsWindow of whole minutes, so the minute of
+	// the first trades leaves it StatsWindow after it began
+	s = accept(960 + StatsWindow - 1)
+	if s.Trades != 3 {
+		t.Fatalf("%d trades at the end of the window, want 3", s.Trades)
+	}
+	s = accept(960 + StatsWindow)
+	if s.Trades != 1 || s.Open != 8 || s.High != 8 || s.Low != 8 || s.Volume != 1 {
+		t.Fatalf("statistics %+v once the first trades left the window", s)
+	}
+
+	// The last price outlives the window
+	s = accept(first + 3_600 + 2*StatsWindow)
+	if s.Trades != 0 || s.Open != 0 || s.Volume != 0 || s.LastPrice != 8 {
+		t.Fatalf("statistics %+v once every trade left the window", s)
+	}
+}
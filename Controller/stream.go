@@ -0,0 +1,581 @@
+// controller/stream.go
+
+package controller
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/ava-labs/hypersdk/crypto"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"CLOB/auth"
+	"CLOB/storage"
+	"CLOB/utils"
+	engine "CLOB/vm"
+)
+
+// StreamEndpoint is where the WebSocket stream is served, next to the JSON-RPC
+// endpoint of the chain
+const StreamEndpoint = "/stream"
+
+// Stream channels. Trades and depth are public and per market; orders carries
+// the order updates and fills of the authenticated account across markets.
+const (
+	ChannelTrades = "trades"
+	ChannelDepth  = "depth"
+	ChannelOrders = "orders"
+)
+
+// Stream requests
+const (
+	OpSubscribe   = "subscribe"
+	OpUnsubscribe = "unsubscribe"
+	OpAuth        = "auth"
+)
+
+// Stream message types other than the channels
+const (
+	TypeSubscribed    = "subscribed"
+	TypeUnsubscribed  = "unsubscribed"
+	TypeChallenge     = "challenge"
+	TypeAuthenticated = "authenticated"
+	TypeError         = "error"
+)
+
+const (
+	// StreamNonceLen is the length of the nonce a connection is challenged
+	// with
+	StreamNonceLen = 32
+
+	streamBuffer       = 1024 // Messages queued per connection before it is dropped
+	streamReadLimit    = 4 * 1024
+	streamWriteTimeout = 10 * time.Second
+)
+
+var (
+	ErrUnknownOp        = errors.New("unknown op")
+	ErrUnknownChannel   = errors.New("unknown channel")
+	ErrUnknownMarket    = errors.New("unknown market")
+	ErrNotAuthenticated = errors.New("authentication required")
+	ErrInvalidNonce     = errors.New("auth nonce was not issued to this connection or was already used")
+	ErrInvalidAuth      = errors.New("invalid auth signature")
+)
+
+// StreamRequest is a message from a stream client
+type StreamRequest struct {
+	Op      string `json:"op"`
+	Channel string `json:"channel,omitempty"`
+	Market  string `json:"market,omitempty"` // Unused by the orders channel
+
+	// Auth only: [Address] signs auth.StreamAuthMessage with the [Nonce] of
+	// the last challenge the connection received
+	Address   string `json:"address,omitempty"`
+	Nonce     []byte `json:"nonce,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// Trade is a public fill, without the accounts that traded
+type Trade struct {
+	Price     float64      `json:"price"`
+	Quantity  float64      `json:"quantity"`
+	TakerSide storage.Side `json:"taker_side"`
+	TxID      ids.ID       `json:"tx_id"`
+	Height    uint64       `json:"height"`
+	Timestamp int64        `json:"timestamp"`
+	Index     uint32       `json:"index"` // Position of the fill within its block
+}
+
+// publicTrade returns the public view of [fill]
+func publicTrade(fill *storage.Fill) Trade {
+	return Trade{
+		Price:     fill.Price,
+		Quantity:  fill.Quantity,
+		TakerSide: fill.TakerSide,
+		TxID:      fill.TxID,
+		Height:    fill.Height,
+		Timestamp: fill.Timestamp,
+		Index:     fill.Index,
+	}
+}
+
+// StreamMessage is a message to a stream client. Channel messages are sent
+// once per accepted block that has something for them, in block order.
+//
+// A connection is first sent a challenge with a nonce to sign to
+// authenticate. Every auth request uses up the nonce, whether it succeeds or
+// not, and is followed by a new challenge.
+//
+// Depth messages carry the levels that changed in the block, with emptied
+// levels at zero quantity, and the book sequence before (PrevSequence) and
+// after (Sequence) the block. A client applies a message only if
+// PrevSequence matches its own sequence; otherwise it missed updates and
+// must resubscribe. The first depth message after subscribing is a full
+// snapshot instead. Checksum is that of the top of the book after the
+// message, see storage.Checksum.
+type StreamMessage struct {
+	Type      string `json:"type"`
+	Channel   string `json:"channel,omitempty"`
+	Market    string `json:"market,omitempty"`
+	Height    uint64 `json:"height,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+
+	Snapshot     bool                 `json:"snapshot,omitempty"`
+	Sequence     uint64               `json:"sequence,omitempty"`
+	PrevSequence uint64               `json:"prev_sequence,omitempty"`
+	Checksum     uint32               `json:"checksum,omitempty"`
+	Bids         []storage.DepthLevel `json:"bids,omitempty"`
+	Asks         []storage.DepthLevel `json:"asks,omitempty"`
+
+	Trades  []Trade                `json:"trades,omitempty"`
+	Updates []*storage.OrderRecord `json:"updates,omitempty"`
+	Fills   []storage.Fill         `json:"fills,omitempty"`
+
+	Nonce []byte `json:"nonce,omitempty"` // Challenge only
+	Error string `json:"error,omitempty"`
+}
+
+// Streamer serves the WebSocket stream and publishes the trades, depth
+// changes and order updates of every accepted block to its subscribers
+type Streamer struct {
+	log      logging.Logger
+	chainID  ids.ID
+	upgrader websocket.Upgrader
+
+	mu    sync.Mutex
+	conns map[*streamConn]struct{}
+	books engine.Snapshots // Snapshots as of the last published block
+}
+
+// NewStreamer creates a streamer whose depth channels start from [books] and
+// that browsers may connect to from the node's own origin or [origins]
+func NewStreamer(log logging.Logger, chainID ids.ID, books engine.Snapshots, origins []string) *Streamer {
+	return &Streamer{
+		log:     log,
+		chainID: chainID,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  streamReadLimit,
+			WriteBufferSize: streamReadLimit,
+			CheckOrigin:     checkOrigin(origins),
+		},
+		conns: make(map[*streamConn]struct{}),
+		books: books,
+	}
+}
+
+// checkOrigin returns the origin check of the WebSocket handshake. The
+// orders channel is only served to connections that signed a challenge, so
+// a page cannot ride on a browser's credentials; the check rather keeps
+// arbitrary sites from using visitors' browsers as stream clients of the
+// node. Requests without an Origin do not come from a browser and are
+// let through, as are same-origin ones and those from [allowed] ("*" for
+// any origin).
+func checkOrigin(allowed []string) func(*http.Request) bool {
+	origins := make(map[string]struct{}, len(allowed))
+	for _, origin := range allowed {
+		origins[strings.ToLower(origin)] = struct{}{}
+	}
+	_, anyOrigin := origins["*"]
+	return func(req *http.Request) bool {
+		origin := req.Header.Get("Origin")
+		if origin == "" || anyOrigin {
+			return true
+		}
+		if _, ok := origins[strings.ToLower(origin)]; ok {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, req.Host)
+	}
+}
+
+// streamConn is a stream client and its subscriptions, which are guarded by
+// Streamer.mu
+type streamConn struct {
+	ws   *websocket.Conn
+	send chan []byte
+	done chan struct{}
+	once sync.Once
+
+	trades map[string]struct{}
+	depth  map[string]struct{}
+	orders bool
+	owner  *crypto.PublicKey // Set once authenticated
+
+	nonce []byte // Nonce of the last challenge, nil once used. Read loop only.
+}
+
+// ServeHTTP upgrades the request to a WebSocket and serves it until either
+// side closes it
+func (s *Streamer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		s.log.Debug("failed to upgrade stream connection", zap.Error(err))
+		return
+	}
+	ws.SetReadLimit(streamReadLimit)
+	c := &streamConn{
+		ws:     ws,
+		send:   make(chan []byte, streamBuffer),
+		done:   make(chan struct{}),
+		trades: make(map[string]struct{}),
+		depth:  make(map[string]struct{}),
+	}
+
+	if err := s.challenge(c); err != nil {
+		s.log.Warn("failed to create stream auth nonce", zap.Error(err))
+		_ = ws.Close()
+		return
+	}
+
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+
+	go c.write()
+	s.read(c)
+
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+	c.close()
+}
+
+// read handles the requests of [c] until it fails
+func (s *Streamer) read(c *streamConn) {
+	for {
+		var req StreamRequest
+		if err := c.ws.ReadJSON(&req); err != nil {
+			return
+		}
+		if err := s.handle(c, &req); err != nil {
+			s.enqueue(c, &StreamMessage{Type: TypeError, Channel: req.Channel, Market: req.Market, Error: err.Error()})
+		}
+	}
+}
+
+// handle applies a request of [c]. Replies are queued under the same lock
+// as published messages, so a subscription never misses or repeats a block.
+func (s *Streamer) handle(c *streamConn, req *StreamRequest) error {
+	if req.Op == OpAuth {
+		owner, err := s.authenticate(c, req)
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		c.owner = &owner
+		s.enqueue(c, &StreamMessage{Type: TypeAuthenticated})
+		return nil
+	}
+	if req.Op != OpSubscribe && req.Op != OpUnsubscribe {
+		return ErrUnknownOp
+	}
+	subscribe := req.Op == OpSubscribe
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var subs map[string]struct{}
+	switch req.Channel {
+	case ChannelTrades:
+		subs = c.trades
+	case ChannelDepth:
+		subs = c.depth
+	case ChannelOrders:
+		if c.owner == nil {
+			return ErrNotAuthenticated
+		}
+		c.orders = subscribe
+		s.enqueue(c, &StreamMessage{Type: replyType(subscribe), Channel: req.Channel})
+		return nil
+	default:
+		return ErrUnknownChannel
+	}
+
+	snapshot, ok := s.books[req.Market]
+	if !ok {
+		return ErrUnknownMarket
+	}
+	if !subscribe {
+		delete(subs, req.Market)
+		s.enqueue(c, &StreamMessage{Type: TypeUnsubscribed, Channel: req.Channel, Market: req.Market})
+		return nil
+	}
+	subs[req.Market] = struct{}{}
+	s.enqueue(c, &StreamMessage{Type: TypeSubscribed, Channel: req.Channel, Market: req.Market})
+	if req.Channel == ChannelDepth {
+		msg := depthMessage(snapshot)
+		msg.Snapshot = true
+		msg.Bids, msg.Asks = snapshot.Depth(len(snapshot.Bids)+len(snapshot.Asks), 0)
+		s.enqueue(c, msg)
+	}
+	return nil
+}
+
+// replyType returns the reply type of a (un)subscription
+func replyType(subscribe bool) string {
+	if subscribe {
+		return TypeSubscribed
+	}
+	return TypeUnsubscribed
+}
+
+// challenge issues a new nonce to [c], replacing any unused one
+func (s *Streamer) challenge(c *streamConn) error {
+	nonce := make([]byte, StreamNonceLen)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	c.nonce = nonce
+	s.enqueue(c, &StreamMessage{Type: TypeChallenge, Nonce: nonce})
+	return nil
+}
+
+// authenticate checks that [req] was signed by the account it names, for
+// this chain and the nonce [c] was last challenged with. The nonce is used
+// up either way, so a signature can never be replayed.
+func (s *Streamer) authenticate(c *streamConn, req *StreamRequest) (crypto.PublicKey, error) {
+	nonce := c.nonce
+	c.nonce = nil
+	if err := s.challenge(c); err != nil {
+		return crypto.EmptyPublicKey, err
+	}
+
+	owner, err := utils.ParseAddress(req.Address)
+	if err != nil {
+		return crypto.EmptyPublicKey, err
+	}
+	if nonce == nil || !bytes.Equal(req.Nonce, nonce) {
+		return crypto.EmptyPublicKey, ErrInvalidNonce
+	}
+	if len(req.Signature) != crypto.SignatureLen {
+		return crypto.EmptyPublicKey, ErrInvalidAuth
+	}
+	var sig crypto.Signature
+	copy(sig[:], req.Signature)
+	if !crypto.Verify(auth.StreamAuthMessage(s.chainID, nonce), owner, sig) {
+		return crypto.EmptyPublicKey, ErrInvalidAuth
+	}
+	return owner, nil
+}
+
+// depthMessage returns a depth message for [snapshot] without any level
+func depthMessage(snapshot *storage.BookSnapshot) *StreamMessage {
+	return &StreamMessage{
+		Type:      ChannelDepth,
+		Channel:   ChannelDepth,
+		Market:    snapshot.Market,
+		Height:    snapshot.Height,
+		Timestamp: snapshot.Timestamp,
+		Sequence:  snapshot.Sequence,
+		Checksum:  snapshot.Checksum,
+	}
+}
+
+// blockStream is what an accepted block publishes, split by recipient
+type blockStream struct {
+	trades  map[string]*StreamMessage // By market
+	depth   map[string]*StreamMessage // By market
+	updates map[crypto.PublicKey][]*storage.OrderRecord
+	fills   map[crypto.PublicKey][]storage.Fill
+}
+
+// Publish sends the trades, depth changes and order updates of an accepted
+// block to the connections subscribed to them. It never blocks on a client:
+// one that falls [streamBuffer] messages behind is disconnected.
+func (s *Streamer) Publish(view *engine.BlockView) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.collect(view)
+	for market, snapshot := range view.Snapshots {
+		prev, ok := s.books[market]
+		if !ok {
+			continue
+		}
+		msg := depthMessage(snapshot)
+		msg.PrevSequence = prev.Sequence
+		msg.Bids, msg.Asks = storage.DepthChanges(prev, snapshot)
+		b.depth[market] = msg
+	}
+	next := make(engine.Snapshots, len(s.books))
+	for market, snapshot := range s.books {
+		next[market] = snapshot
+	}
+	for market, snapshot := range view.Snapshots {
+		next[market] = snapshot
+	}
+	s.books = next
+
+	encoded := make(map[*StreamMessage][]byte)
+	send := func(c *streamConn, msg *StreamMessage) {
+		raw, ok := encoded[msg]
+		if !ok {
+			var err error
+			if raw, err = json.Marshal(msg); err != nil {
+				s.log.Warn("failed to encode stream message", zap.Error(err))
+				return
+			}
+			encoded[msg] = raw
+		}
+		s.enqueueRaw(c, raw)
+	}
+	for c := range s.conns {
+		for market := range c.trades {
+			if msg, ok := b.trades[market]; ok {
+				send(c, msg)
+			}
+		}
+		for market := range c.depth {
+			if msg, ok := b.depth[market]; ok {
+				send(c, msg)
+			}
+		}
+		if !c.orders {
+			continue
+		}
+		updates, fills := b.updates[*c.owner], b.fills[*c.owner]
+		if len(updates) == 0 && len(fills) == 0 {
+			continue
+		}
+		send(c, &StreamMessage{
+			Type:      ChannelOrders,
+			Channel:   ChannelOrders,
+			Height:    view.Height,
+			Timestamp: view.Timestamp,
+			Updates:   updates,
+			Fills:     fills,
+		})
+	}
+}
+
+// collect splits the events of [view] into public trades and private order
+// updates and fills. Fills are stamped with their block the same way the
+// indexer stores them.
+func (s *Streamer) collect(view *engine.BlockView) *blockStream {
+	b := &blockStream{
+		trades:  make(map[string]*StreamMessage),
+		depth:   make(map[string]*StreamMessage),
+		updates: make(map[crypto.PublicKey][]*storage.OrderRecord),
+		fills:   make(map[crypto.PublicKey][]storage.Fill),
+	}
+	addUpdate := func(record *storage.OrderRecord, tx engine.BlockTx) {
+		record.TxID = tx.TxID
+		record.Height = view.Height
+		b.updates[record.Owner] = append(b.updates[record.Owner], record)
+	}
+
+	for _, update := range view.PreEvents.Updates {
+		addUpdate(storage.NewOrderRecord(&update.Order, update.Status, update.Reason), engine.BlockTx{})
+	}
+	var index uint32
+	for t, events := range view.Events {
+		tx := view.Txs[t]
+		for _, update := range events.Updates {
+			addUpdate(storage.NewOrderRecord(&update.Order, update.Status, update.Reason), tx)
+		}
+		for _, fill := range events.Fills {
+			fill.TxID = tx.TxID
+			fill.Height = view.Height
+			fill.Timestamp = view.Timestamp
+			fill.Index = index
+			index++
+
+			msg, ok := b.trades[fill.Market]
+			if !ok {
+				msg = &StreamMessage{
+					Type:      ChannelTrades,
+					Channel:   ChannelTrades,
+					Market:    fill.Market,
+					Height:    view.Height,
+					Timestamp: view.Timestamp,
+				}
+				b.trades[fill.Market] = msg
+			}
+			msg.Trades = append(msg.Trades, publicTrade(&fill))
+			b.fills[fill.Taker] = append(b.fills[fill.Taker], fill)
+			if fill.Maker != fill.Taker {
+				b.fills[fill.Maker] = append(b.fills[fill.Maker], fill)
+			}
+		}
+
+		// Orders refused by the engine leave no event behind
+		updated := make(map[string]struct{}, len(events.Updates))
+		for _, update := range events.Updates {
+			updated[update.Order.ID] = struct{}{}
+		}
+		for _, refused := range refusedOrders(tx.Action, view.Results[t]) {
+			if _, ok := updated[refused.order.ID]; ok {
+				continue
+			}
+			addUpdate(storage.NewOrderRecord(refused.order, storage.StatusRejected, rejectReason(refused.err)), tx)
+		}
+	}
+	return b
+}
+
+// enqueue encodes [msg] and queues it for [c]
+func (s *Streamer) enqueue(c *streamConn, msg *StreamMessage) {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		s.log.Warn("failed to encode stream message", zap.Error(err))
+		return
+	}
+	s.enqueueRaw(c, raw)
+}
+
+// enqueueRaw queues [raw] for [c], disconnecting it if its queue is full
+func (s *Streamer) enqueueRaw(c *streamConn, raw []byte) {
+	select {
+	case c.send <- raw:
+	default:
+		s.log.Debug("dropping slow stream connection", zap.Stringer("remote", c.ws.RemoteAddr()))
+		c.close()
+	}
+}
+
+// write sends the queued messages of [c] until it is closed
+func (c *streamConn) write() {
+	for {
+		select {
+		case raw := <-c.send:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := c.ws.WriteMessage(websocket.TextMessage, raw); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// close closes the connection, which also ends its reader
+func (c *streamConn) close() {
+	c.once.Do(func() {
+		close(c.done)
+		_ = c.ws.Close()
+	})
+}
+
+// Close disconnects every stream client
+func (s *Streamer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.conns {
+		c.close()
+	}
+}
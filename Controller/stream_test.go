@@ -0,0 +1,224 @@
+// controller/stream_test.go
+
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/ava-labs/hypersdk/crypto"
+	"github.com/gorilla/websocket"
+
+	"CLOB/auth"
+	"CLOB/storage"
+	"CLOB/utils"
+	engine "CLOB/vm"
+)
+
+// testStream serves a streamer of [books] and returns it with its URL
+func testStream(t *testing.T, chainID ids.ID, books engine.Snapshots, origins []string) (*Streamer, string) {
+	t.Helper()
+	s := NewStreamer(logging.NoLog{}, chainID, books, origins)
+	srv := httptest.NewServer(s)
+	t.Cleanup(func() {
+		s.Close()
+		srv.Close()
+	})
+	return s, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// dialStream connects to [url] and returns the connection and the nonce of
+// its first challenge
+func dialStream(t *testing.T, url string) (*websocket.Conn, []byte) {
+	t.Helper()
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ws.Close() })
+	return ws, expectType(t, ws, TypeChallenge).Nonce
+}
+
+// expectType reads the next message of [ws] and checks its type
+func expectType(t *testing.T, ws *websocket.Conn, typ string) *StreamMessage {
+	t.Helper()
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg StreamMessage
+	if err := ws.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != typ {
+		t.Fatalf("message %+v, want type %q", msg, typ)
+	}
+	return &msg
+}
+
+// send writes [req] to [ws]
+func send(t *testing.T, ws *websocket.Conn, req *StreamRequest) {
+	t.Helper()
+	if err := ws.WriteJSON(req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamOrigins(t *testing.T) {
+	_, url := testStream(t, ids.GenerateTestID(), engine.Snapshots{}, []string{"https://app.example.com"})
+	host := strings.TrimPrefix(url, "ws://")
+
+	for _, tt := range []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true}, // Not a browser
+		{"http://" + host, true},
+		{"https://APP.example.com", true},
+		{"https://evil.example.com", false},
+		{"https://app.example.com.evil.example.com", false},
+	} {
+		header := http.Header{}
+		if tt.origin != "" {
+			header.Set("Origin", tt.origin)
+		}
+		ws, resp, err := websocket.DefaultDialer.Dial(url, header)
+		if tt.ok {
+			if err != nil {
+				t.Fatalf("origin %q refused: %v", tt.origin, err)
+			}
+			_ = ws.Close()
+			continue
+		}
+		if err == nil {
+			_ = ws.Close()
+			t.Fatalf("origin %q accepted", tt.origin)
+		}
+		if resp == nil || resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("origin %q refused with %v, want %d", tt.origin, resp, http.StatusForbidden)
+		}
+	}
+}
+
+func TestStreamAuthNonceSingleUse(t *testing.T) {
+	chainID := ids.GenerateTestID()
+	_, url := testStream(t, chainID, engine.Snapshots{}, nil)
+	key, err := crypto.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authRequest := func(nonce []byte) *StreamRequest {
+		sig := crypto.Sign(auth.StreamAuthMessage(chainID, nonce), key)
+		return &StreamRequest{Op: OpAuth, Address: utils.Address(key.PublicKey()), Nonce: nonce, Signature: sig[:]}
+	}
+	expectError := func(ws *websocket.Conn, want error) []byte {
+		t.Helper()
+		nonce := expectType(t, ws, TypeChallenge).Nonce
+		if msg := expectType(t, ws, TypeError); msg.Error != want.Error() {
+			t.Fatalf("error %q, want %q", msg.Error, want)
+		}
+		return nonce
+	}
+
+	ws, nonce := dialStream(t, url)
+
+	// The orders channel needs authentication
+	send(t, ws, &StreamRequest{Op: OpSubscribe, Channel: ChannelOrders})
+	if msg := expectType(t, ws, TypeError); msg.Error != ErrNotAuthenticated.Error() {
+		t.Fatalf("error %q, want %q", msg.Error, ErrNotAuthenticated)
+	}
+
+	signed := authRequest(nonce)
+	send(t, ws, signed)
+	next := expectType(t, ws, TypeChallenge).Nonce
+	expectType(t, ws, TypeAuthenticated)
+
+	// Replaying the signature fails, as its nonce was used up
+	send(t, ws, signed)
+	next = expectError(ws, ErrInvalidNonce)
+
+	// A failed attempt uses up its nonce too
+	bad := authRequest(next)
+	bad.Signature[0] ^= 1
+	send(t, ws, bad)
+	last := expectError(ws, ErrInvalidAuth)
+	send(t, ws, authRequest(next))
+	expectError(ws, ErrInvalidNonce)
+
+	// Nonces are only good on the connection they were issued to
+	other, _ := dialStream(t, url)
+	send(t, other, authRequest(last))
+	expectError(other, ErrInvalidNonce)
+}
+
+func TestStreamDepthSequencing(t *testing.T) {
+	level := func(price float64, quantity float64, orders int) []storage.LevelSnapshot {
+		return []storage.LevelSnapshot{{Price: price, Quantity: quantity, Orders: make([]storage.Order, orders)}}
+	}
+	book := func(sequence uint64, bids []storage.LevelSnapshot, asks []storage.LevelSnapshot) *storage.BookSnapshot {
+		return &storage.BookSnapshot{Market: "a", Height: sequence, Sequence: sequence, Bids: bids, Asks: asks}
+	}
+	s, url := testStream(t, ids.GenerateTestID(), engine.Snapshots{
+		"a": book(3, level(9, 1, 1), nil),
+	}, nil)
+	ws, _ := dialStream(t, url)
+
+	send(t, ws, &StreamRequest{Op: OpSubscribe, Channel: ChannelDepth, Market: "missing"})
+	if msg := expectType(t, ws, TypeError); msg.Error != ErrUnknownMarket.Error() {
+		t.Fatalf("error %q, want %q", msg.Error, ErrUnknownMarket)
+	}
+
+	// Subscribing replies, then sends the whole book
+	send(t, ws, &StreamRequest{Op: OpSubscribe, Channel: ChannelDepth, Market: "a"})
+	expectType(t, ws, TypeSubscribed)
+	snapshot := expectType(t, ws, ChannelDepth)
+	if !snapshot.Snapshot || snapshot.Sequence != 3 {
+		t.Fatalf("first depth message %+v, want a snapshot at sequence 3", snapshot)
+	}
+	if want := []storage.DepthLevel{{Price: 9, Quantity: 1, Orders: 1}}; !reflect.DeepEqual(snapshot.Bids, want) {
+		t.Fatalf("snapshot bids %+v, want %+v", snapshot.Bids, want)
+	}
+
+	// Every block then chains on the sequence of the previous message
+	publish := func(height uint64, snapshots engine.Snapshots) {
+		s.Publish(&engine.BlockView{BlockInfo: engine.BlockInfo{Height: height}, Snapshots: snapshots})
+	}
+	publish(5, engine.Snapshots{"a": book(5,
+		level(9, 2, 2),
+		level(11, 1, 1),
+	)})
+	update := expectType(t, ws, ChannelDepth)
+	if update.Snapshot || update.PrevSequence != 3 || update.Sequence != 5 {
+		t.Fatalf("depth update %+v, want 3 to 5", update)
+	}
+	if len(update.Bids) != 1 || update.Bids[0].Quantity != 2 || len(update.Asks) != 1 || update.Asks[0].Price != 11 {
+		t.Fatalf("depth changes %+v/%+v", update.Bids, update.Asks)
+	}
+
+	publish(6, engine.Snapshots{}) // Leaves the book alone
+	publish(7, engine.Snapshots{"a": book(7, nil, level(11, 1, 1))})
+	update = expectType(t, ws, ChannelDepth)
+	if update.PrevSequence != 5 || update.Sequence != 7 {
+		t.Fatalf("depth update %+v, want 5 to 7", update)
+	}
+	if want := []storage.DepthLevel{{Price: 9}}; !reflect.DeepEqual(update.Bids, want) || len(update.Asks) != 0 {
+		t.Fatalf("depth changes %+v/%+v, want the bid emptied", update.Bids, update.Asks)
+	}
+
+	// Nothing is sent after unsubscribing
+	send(t, ws, &StreamRequest{Op: OpUnsubscribe, Channel: ChannelDepth, Market: "a"})
+	expectType(t, ws, TypeUnsubscribed)
+	publish(8, engine.Snapshots{"a": book(8, nil, nil)})
+	send(t, ws, &StreamRequest{Op: OpSubscribe, Channel: ChannelTrades, Market: "a"})
+	expectType(t, ws, TypeSubscribed)
+
+	// and a new subscription starts over from the latest book
+	send(t, ws, &StreamRequest{Op: OpSubscribe, Channel: ChannelDepth, Market: "a"})
+	expectType(t, ws, TypeSubscribed)
+	if snapshot = expectType(t, ws, ChannelDepth); !snapshot.Snapshot || snapshot.Sequence != 8 {
+		t.Fatalf("depth message %+v, want a snapshot at sequence 8", snapshot)
+	}
+}
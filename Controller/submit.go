@@ -0,0 +1,162 @@
+// controller/submit.go
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	hconsts "github.com/ava-labs/hypersdk/consts"
+	"github.com/ava-labs/hypersdk/crypto"
+
+	"CLOB/actions"
+	"CLOB/auth"
+	"CLOB/consts"
+	"CLOB/storage"
+)
+
+var (
+	ErrSignedTxRequired = errors.New("a signed transaction is required")
+	ErrUnexpectedAction = errors.New("transaction does not carry the expected action")
+	ErrTxNotFound       = errors.New("transaction not found")
+)
+
+// orderTx returns the transaction to submit for an order request: [raw] if
+// the client signed one, or else the action returned by [build] signed with
+// the dev key. Without either the request is refused, so orders never skip
+// signatures, consensus or fees.
+func (h *Handler) orderTx(ctx context.Context, raw []byte, build func() (chain.Action, error)) (*chain.Transaction, error) {
+	if len(raw) > 0 {
+		p := codec.NewReader(raw, hconsts.NetworkSizeLimit)
+		return chain.UnmarshalTx(p, consts.ActionRegistry, consts.AuthRegistry)
+	}
+
+	key, ok := h.c.config.GetDevKey()
+	if !ok {
+		return nil, ErrSignedTxRequired
+	}
+	action, err := build()
+	if err != nil {
+		return nil, err
+	}
+	return h.c.signTx(ctx, key, action)
+}
+
+// signTx returns a transaction carrying [action] signed with [key], paying
+// the suggested fee and valid for the whole validity window
+func (c *Controller) signTx(ctx context.Context, key crypto.PrivateKey, action chain.Action) (*chain.Transaction, error) {
+	unitPrice, _, err := c.inner.SuggestedFee(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := c.inner.Clock().Now().Unix()
+	base := &chain.Base{
+		Timestamp: now + c.genesis.Rules(now).GetValidityWindow(),
+		ChainID:   c.snowCtx.ChainID,
+		UnitPrice: unitPrice,
+	}
+	return chain.NewTx(base, nil, action).Sign(auth.NewED25519Factory(key), consts.ActionRegistry, consts.AuthRegistry)
+}
+
+// submitTx adds [tx] to the mempool once its signature and fees check out.
+// The tx is only executed once a block including it is accepted.
+func (h *Handler) submitTx(ctx context.Context, tx *chain.Transaction) (ids.ID, error) {
+	if errs := h.c.inner.Submit(ctx, true, []*chain.Transaction{tx}); errs[0] != nil {
+		return ids.Empty, errs[0]
+	}
+	return tx.ID(), nil
+}
+
+// preTrade runs the pre-trade risk checks on an order before it enters the
+// mempool, against the accepted balances, reservations, order-to-trade
+// counters and books. Orders failing them never reach a block; they all run
+// again when the tx is executed.
+func (h *Handler) preTrade(ctx context.Context, actor crypto.PublicKey, add *actions.AddOrder) (*actions.RejectError, error) {
+	now := h.c.inner.Clock().Now().Unix()
+	rules := h.c.genesis.Rules(now)
+	orderType := storage.OrderType(add.OrderType)
+	if rerr := actions.CheckOrderLimits(rules, add.Market, orderType, add.Price, add.Quantity); rerr != nil {
+		return rerr, nil
+	}
+
+	snapshot, err := h.c.vm.Snapshot(add.Market)
+	if err != nil {
+		return nil, err
+	}
+	record, err := storage.GetAccountMarketFromState(ctx, h.c.inner.ReadState, actor, add.Market)
+	if err != nil {
+		return nil, err
+	}
+	if orderType == storage.Limit {
+		if rerr := actions.CheckOpenOrders(rules, len(record.Reservations)); rerr != nil {
+			return rerr, nil
+		}
+	}
+	if maxRatio, window, _ := rules.GetOrderToTradeLimit(); maxRatio > 0 {
+		if rerr := actions.CheckOrderToTrade(rules, record.Activity.At(now, window)); rerr != nil {
+			return rerr, nil
+		}
+	}
+
+	base, err := h.availableBalance(ctx, actor, snapshot.BaseAsset)
+	if err != nil {
+		return nil, err
+	}
+	quote, err := h.availableBalance(ctx, actor, snapshot.QuoteAsset)
+	if err != nil {
+		return nil, err
+	}
+	return actions.CheckBalance(storage.Side(add.Side), add.Price, add.Quantity, base, quote), nil
+}
+
+// availableBalance returns the accepted balance of [pk] in [asset] that its
+// orders have not reserved
+func (h *Handler) availableBalance(ctx context.Context, pk crypto.PublicKey, asset ids.ID) (uint64, error) {
+	bal, err := storage.GetBalanceFromState(ctx, h.c.inner.ReadState, pk, asset)
+	if err != nil {
+		return 0, err
+	}
+	reserved, err := storage.GetReservedFromState(ctx, h.c.inner.ReadState, pk, asset)
+	if err != nil {
+		return 0, err
+	}
+	if reserved >= bal {
+		return 0, nil
+	}
+	return bal - reserved, nil
+}
+
+// TxArgs represents the request payload for retrieving the result of a
+// transaction
+type TxArgs struct {
+	TxID ids.ID `json:"tx_id"`
+}
+
+// TxReply represents the response containing the result of a transaction
+type TxReply struct {
+	Timestamp int64  `json:"timestamp"`
+	Success   bool   `json:"success"`
+	Units     uint64 `json:"units"`
+}
+
+// Tx handles retrieving the result of an accepted transaction
+func (h *Handler) Tx(req *http.Request, args *TxArgs, reply *TxReply) error {
+	ctx, span := h.c.inner.Tracer().Start(req.Context(), "Handler.Tx")
+	defer span.End()
+
+	found, t, success, units, err := storage.GetTransaction(ctx, h.c.metaDB, args.TxID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrTxNotFound
+	}
+	reply.Timestamp = t
+	reply.Success = success
+	reply.Units = units
+	return nil
+}
@@ -1,7 +1,14 @@
 // CLOB/actions/action_interfaces.go
 package actions
 
-import "CLOB/storage"
+import (
+    "CLOB/genesis"
+    "CLOB/storage"
+
+    "github.com/ava-labs/avalanchego/ids"
+    "github.com/ava-labs/hypersdk/chain"
+    "github.com/ava-labs/hypersdk/crypto"
+)
 
 // Action defines the interface for all actions
 type Action interface {
@@ -10,5 +17,32 @@ type Action interface {
 
 // VMContext provides access to the VM's state for actions
 type VMContext interface {
-    GetOrderBook() *storage.OrderBook
+    GetOrderBook(market string) (*storage.OrderBook, error)
+    GetRules() *genesis.Rules
+    Markets() []string // IDs of the markets the action may touch, sorted
+}
+
+// TimerContext is implemented by the VMContexts that expose the dead-man's
+// switches of every account. Contexts scoped to a market or a batch only
+// expose order books, so they do not implement it.
+type TimerContext interface {
+    VMContext
+    GetCancelTimers() *storage.CancelTimers
+}
+
+// MarketScoped is implemented by actions that only touch the book of a
+// single market. Consecutive market-scoped actions of a block are executed
+// concurrently across markets, in block order within each market. An action
+// whose MarketID is empty may touch every market and runs alone.
+type MarketScoped interface {
+    MarketID() string
+}
+
+// OrderTx is implemented by chain actions that carry an order book action.
+// The chain only checks their format; the matching engine executes the
+// returned Action against the block's view of the order book once the block
+// has been verified.
+type OrderTx interface {
+    chain.Action
+    EngineAction(actor crypto.PublicKey, txID ids.ID, timestamp int64) Action
 }
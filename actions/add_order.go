@@ -3,30 +3,234 @@
 package actions
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"time"
 
+	"CLOB/auth"
 	"CLOB/genesis"
 	"CLOB/storage"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+	"github.com/ava-labs/hypersdk/crypto"
+	"github.com/ava-labs/hypersdk/utils"
 )
 
+// AddOrderAction places an order in the order book. The order is matched
+// against the opposite side first and any limit order remainder rests.
 type AddOrderAction struct {
 	Order *storage.Order
 }
 
-func (a *AddOrderAction) Execute(vm *vm.MatchingEngineVM) error {
-	orderBook := vm.OrderBook
-	rules := vm.Rules
+func (a *AddOrderAction) MarketID() string {
+	return a.Order.Market
+}
+
+func (a *AddOrderAction) Execute(vm VMContext) error {
+	orderBook, err := vm.GetOrderBook(a.Order.Market)
+	if err != nil {
+		return err
+	}
+	rules := vm.GetRules()
 
-	// Example usage of Rules
-	maxBlockTxs := rules.GetMaxBlockTxs()
-	if orderBook.CurrentBlockTxs >= maxBlockTxs {
-		return fmt.Errorf("cannot add order: max block transactions (%d) reached", maxBlockTxs)
+	if a.Order.OriginalQuantity == 0 {
+		a.Order.OriginalQuantity = a.Order.Quantity
+	}
+
+	if _, exists := orderBook.OrderMap[a.Order.ID]; exists {
+		return fmt.Errorf("cannot add order '%s': %w", a.Order.ID, storage.ErrOrderExists)
+	}
+	if a.Order.ClientOrderID != "" {
+		if _, exists := orderBook.LookupOrder(a.Order.Owner, "", a.Order.ClientOrderID); exists {
+			return fmt.Errorf("cannot add order '%s': %w", a.Order.ClientOrderID, storage.ErrClientOrderIDExists)
+		}
 	}
 
-	// Proceed to add the order
-	if err := orderBook.AddOrder(orderBook.Context, a.Order); err != nil {
+	// Limit orders must be placed on the tick size active for this block
+	if a.Order.OrderType == storage.Limit && !storage.IsOnTick(a.Order.Price, rules.GetTickSize()) {
+		return fmt.Errorf("cannot add order: price %v is not a multiple of tick size %v", a.Order.Price, rules.GetTickSize())
+	}
+
+	// Pre-trade checks already ran when the tx was verified; they are
+	// repeated here for orders placed by batches. The open-order limit and
+	// the order-to-trade throttle are only checked on chain, against the
+	// reservations and counters of the owner.
+	if rerr := CheckOrderLimits(rules, a.Order.Market, a.Order.OrderType, a.Order.Price, a.Order.Quantity); rerr != nil {
+		return rerr
+	}
+
+	// Proceed to match the order
+	switch a.Order.OrderType {
+	case storage.Market:
+		err = MatchMarketOrder(orderBook, a.Order)
+	case storage.Limit:
+		err = MatchLimitOrder(orderBook, a.Order)
+	default:
+		err = fmt.Errorf("unknown order type '%s'", a.Order.OrderType)
+	}
+	if err != nil {
 		return fmt.Errorf("failed to add order: %w", err)
 	}
 
 	return nil
 }
+
+var _ OrderTx = (*AddOrder)(nil)
+
+// AddOrder is the transaction payload that places an order. Once its block
+// is verified it is executed by the matching engine as an AddOrderAction
+// owned by the signer. The order's ID is derived from the transaction ID (see
+// storage.NewOrderID); ClientOrderID optionally lets the signer refer to it by
+// an ID of their own. Client order IDs are unique per owner within a market
+// only, so an order is always referred to by its client order ID together
+// with its market.
+type AddOrder struct {
+	ClientOrderID string `json:"client_order_id,omitempty"`
+
+	Market    string  `json:"market"`
+	Side      string  `json:"side"`  // "buy" or "sell"
+	Price     float64 `json:"price"` // Worst price of a market order, 0 for none on a sell
+	Quantity  float64 `json:"quantity"`
+	OrderType string  `json:"order_type"` // "limit" or "market"
+}
+
+func (a *AddOrder) StateKeys(rauth chain.Auth, _ ids.ID) [][]byte {
+	// The book itself lives in the matching engine; these keys serialize txs
+	// on the same market and account so other markets run in parallel, and
+	// cover the balances the order is checked against and reserves
+	return orderStateKeys(auth.GetActor(rauth), a.Market)
+}
+
+func (a *AddOrder) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	timestamp int64,
+	rauth chain.Auth,
+	txID ids.ID,
+) (*chain.Result, error) {
+	unitsUsed := a.MaxUnits(r)
+	if output := a.validate(); output != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: output}, nil
+	}
+	rules, _ := r.(*genesis.Rules)
+	if rules != nil {
+		rerr := CheckOrderLimits(rules, a.Market, storage.OrderType(a.OrderType), a.Price, a.Quantity)
+		if rerr != nil {
+			return &chain.Result{Success: false, Units: unitsUsed, Output: rerr.Reason.Output()}, nil
+		}
+	}
+
+	market, ok := storage.LookupMarket(a.Market)
+	if !ok {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: RejectUnknownMarket.Output()}, nil
+	}
+	actor := auth.GetActor(rauth)
+	record, err := storage.GetAccountMarket(ctx, db, actor, a.Market)
+	if err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	if rerr := countOrder(rules, record, timestamp); rerr != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: rerr.Reason.Output()}, nil
+	}
+	rerr, err := reserveOrder(
+		ctx, db, rules, actor, market, record,
+		storage.NewOrderID(txID, 0), a.ClientOrderID,
+		storage.Side(a.Side), storage.OrderType(a.OrderType), a.Price, a.Quantity,
+	)
+	if err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	if rerr != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: rerr.Reason.Output()}, nil
+	}
+	if err := storage.SetAccountMarket(ctx, db, actor, a.Market, record); err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	return &chain.Result{Success: true, Units: unitsUsed}, nil
+}
+
+// validate checks the fields of the order that don't depend on the book
+func (a *AddOrder) validate() []byte {
+	if len(a.ClientOrderID) > storage.MaxClientOrderIDSize {
+		return OutputClientIDTooLarge
+	}
+	if len(a.Market) == 0 {
+		return OutputMarketEmpty
+	}
+	if a.Side != string(storage.Buy) && a.Side != string(storage.Sell) {
+		return OutputInvalidSide
+	}
+	if a.OrderType != string(storage.Limit) && a.OrderType != string(storage.Market) {
+		return OutputInvalidOrderType
+	}
+	// A market buy's price is the most it pays, which bounds its cost; a
+	// market sell may set the least it accepts, or 0
+	if a.OrderType == string(storage.Limit) || a.Side == string(storage.Buy) {
+		if !(a.Price > 0) || math.IsInf(a.Price, 0) {
+			return OutputInvalidPrice
+		}
+	} else if !(a.Price >= 0) || math.IsInf(a.Price, 0) {
+		return OutputInvalidPrice
+	}
+	if !(a.Quantity > 0) || math.IsInf(a.Quantity, 0) {
+		return OutputInvalidQuantity
+	}
+	return nil
+}
+
+func (a *AddOrder) EngineAction(actor crypto.PublicKey, txID ids.ID, timestamp int64) Action {
+	return a.engineAction(actor, storage.NewOrderID(txID, 0), timestamp)
+}
+
+// engineAction returns the AddOrderAction placing this order under [orderID]
+func (a *AddOrder) engineAction(actor crypto.PublicKey, orderID string, timestamp int64) *AddOrderAction {
+	return &AddOrderAction{
+		Order: &storage.Order{
+			ID:        orderID,
+			Market:    a.Market,
+			Side:      storage.Side(a.Side),
+			Price:     a.Price,
+			Quantity:  a.Quantity,
+			Timestamp: time.Unix(timestamp, 0).UTC(),
+			OrderType: storage.OrderType(a.OrderType),
+			Owner:     actor,
+
+			ClientOrderID:    a.ClientOrderID,
+			OriginalQuantity: a.Quantity,
+		},
+	}
+}
+
+func (a *AddOrder) MaxUnits(chain.Rules) uint64 {
+	return uint64(len(a.ClientOrderID)+len(a.Market)+len(a.Side)+len(a.OrderType)) + consts.Uint64Len*2
+}
+
+func (a *AddOrder) Marshal(p *codec.Packer) {
+	p.PackString(a.ClientOrderID)
+	p.PackString(a.Market)
+	p.PackString(a.Side)
+	p.PackUint64(math.Float64bits(a.Price))
+	p.PackUint64(math.Float64bits(a.Quantity))
+	p.PackString(a.OrderType)
+}
+
+func UnmarshalAddOrder(p *codec.Packer, _ *codec.Message) (chain.Action, error) {
+	var add AddOrder
+	add.ClientOrderID = p.UnpackString(false)
+	add.Market = p.UnpackString(true)
+	add.Side = p.UnpackString(true)
+	add.Price = math.Float64frombits(p.UnpackUint64(false))
+	add.Quantity = math.Float64frombits(p.UnpackUint64(true))
+	add.OrderType = p.UnpackString(true)
+	return &add, p.Err()
+}
+
+func (*AddOrder) ValidRange(chain.Rules) (int64, int64) {
+	// Returning -1, -1 means that the action is always valid.
+	return -1, -1
+}
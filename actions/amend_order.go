@@ -0,0 +1,72 @@
+// CLOB/actions/amend_order.go
+
+package actions
+
+import (
+	"fmt"
+	"math"
+
+	"CLOB/storage"
+
+	"github.com/ava-labs/hypersdk/crypto"
+)
+
+// AmendOrderAction changes the price and/or remaining quantity of a resting
+// limit order, identified by its ID or, if OrderID is empty, by the client
+// order ID its owner gave it. Reducing the quantity at the same price keeps
+// the order's place in the queue; any other change re-places it, matching it
+// against the book first if the new price crosses, and sends it to the back
+// of its price level.
+type AmendOrderAction struct {
+	OrderID       string
+	ClientOrderID string
+	Market        string
+	Owner         crypto.PublicKey // Empty skips the ownership check
+	Price         float64
+	Quantity      float64 // New remaining quantity
+}
+
+func (a *AmendOrderAction) MarketID() string {
+	return a.Market
+}
+
+func (a *AmendOrderAction) Execute(vm VMContext) error {
+	orderBook, err := vm.GetOrderBook(a.Market)
+	if err != nil {
+		return err
+	}
+	rules := vm.GetRules()
+
+	order, exists := orderBook.LookupOrder(a.Owner, a.OrderID, a.ClientOrderID)
+	if !exists {
+		return storage.ErrOrderNotFound
+	}
+	if a.Owner != crypto.EmptyPublicKey && order.Owner != a.Owner {
+		return storage.ErrNotOrderOwner
+	}
+	if !(a.Price > 0) || math.IsInf(a.Price, 0) {
+		return fmt.Errorf("cannot amend order: invalid price %v", a.Price)
+	}
+	if !(a.Quantity > 0) || math.IsInf(a.Quantity, 0) {
+		return fmt.Errorf("cannot amend order: invalid quantity %v", a.Quantity)
+	}
+	if !storage.IsOnTick(a.Price, rules.GetTickSize()) {
+		return fmt.Errorf("cannot amend order: price %v is not a multiple of tick size %v", a.Price, rules.GetTickSize())
+	}
+
+	// Shrinking in place keeps time priority
+	if a.Price == order.Price && a.Quantity <= order.Quantity {
+		order.Quantity = a.Quantity
+		order.OriginalQuantity = order.FilledQuantity + a.Quantity
+		orderBook.RecordUpdate(order, order.RestingStatus(), storage.ReasonAmended)
+		return nil
+	}
+
+	if err := orderBook.DetachOrder(order); err != nil {
+		return err
+	}
+	order.Price = a.Price
+	order.Quantity = a.Quantity
+	order.OriginalQuantity = order.FilledQuantity + a.Quantity
+	return MatchLimitOrder(orderBook, order)
+}
@@ -0,0 +1,121 @@
+// CLOB/actions/asset_test.go
+
+package actions
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"CLOB/storage"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain"
+)
+
+func TestAssetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	db := testDB{}
+	owner, holder := testKey(t), testKey(t)
+	rules := testRules(t, ids.GenerateTestID(), testKey(t).PublicKey(), nil)
+
+	assetID := ids.GenerateTestID()
+	create := &CreateAssetAction{Symbol: []byte("TEST"), Decimals: 6}
+	mustSucceed(t, execute(t, db, rules, create, owner.PublicKey(), assetID))
+
+	// Only the owner may mint
+	mint := &MintAssetAction{To: holder.PublicKey(), Asset: assetID, Value: 100}
+	mustFail(t, execute(t, db, rules, mint, holder.PublicKey(), ids.GenerateTestID()), OutputWrongOwner)
+	mustSucceed(t, execute(t, db, rules, mint, owner.PublicKey(), ids.GenerateTestID()))
+
+	transfer := &TransferAction{To: owner.PublicKey(), Asset: assetID, Value: 30}
+	mustSucceed(t, execute(t, db, rules, transfer, holder.PublicKey(), ids.GenerateTestID()))
+	if got := balance(t, db, holder.PublicKey(), assetID); got != 70 {
+		t.Fatalf("holder balance %d, want 70", got)
+	}
+	if got := balance(t, db, owner.PublicKey(), assetID); got != 30 {
+		t.Fatalf("owner balance %d, want 30", got)
+	}
+
+	// Any holder may burn
+	burn := &BurnAssetAction{Asset: assetID, Value: 20}
+	mustSucceed(t, execute(t, db, rules, burn, holder.PublicKey(), ids.GenerateTestID()))
+	if got := balance(t, db, holder.PublicKey(), assetID); got != 50 {
+		t.Fatalf("holder balance %d after burning, want 50", got)
+	}
+	asset, err := storage.GetAsset(ctx, db, assetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Supply != 80 || asset.Owner != owner.PublicKey() || string(asset.Symbol) != "TEST" {
+		t.Fatalf("asset %+v, want 80 TEST owned by the creator", asset)
+	}
+}
+
+func TestAssetEdgeCases(t *testing.T) {
+	db := testDB{}
+	owner := testKey(t).PublicKey()
+	rules := testRules(t, ids.GenerateTestID(), testKey(t).PublicKey(), nil)
+	assetID, missing := ids.GenerateTestID(), ids.GenerateTestID()
+	mustSucceed(t, execute(t, db, rules, &CreateAssetAction{Symbol: []byte("TEST")}, owner, assetID))
+	mustSucceed(t, execute(t, db, rules, &MintAssetAction{To: owner, Asset: assetID, Value: math.MaxUint64}, owner, ids.GenerateTestID()))
+
+	tests := []struct {
+		name   string
+		action chain.Action
+		output []byte
+	}{
+		{"mint overflow", &MintAssetAction{To: owner, Asset: assetID, Value: 1}, OutputSupplyOverflow},
+		{"mint missing", &MintAssetAction{To: owner, Asset: missing, Value: 1}, OutputAssetMissing},
+		{"mint native", &MintAssetAction{To: owner, Asset: storage.NativeAsset, Value: 1}, OutputAssetIsNative},
+		{"mint zero", &MintAssetAction{To: owner, Asset: assetID}, OutputValueZero},
+		{"burn missing", &BurnAssetAction{Asset: missing, Value: 1}, OutputAssetMissing},
+		{"burn native", &BurnAssetAction{Asset: storage.NativeAsset, Value: 1}, OutputAssetIsNative},
+		{"burn zero", &BurnAssetAction{Asset: assetID}, OutputValueZero},
+		{"transfer zero", &TransferAction{To: owner, Asset: assetID}, OutputValueZero},
+		{"create without symbol", &CreateAssetAction{}, OutputSymbolEmpty},
+		{"create with too many decimals", &CreateAssetAction{Symbol: []byte("TEST"), Decimals: storage.MaxDecimals + 1}, OutputDecimalsTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mustFail(t, execute(t, db, rules, tt.action, owner, ids.GenerateTestID()), tt.output)
+		})
+	}
+	if got := balance(t, db, owner, assetID); got != math.MaxUint64 {
+		t.Fatalf("balance %d after failed actions, want %d", got, uint64(math.MaxUint64))
+	}
+}
+
+func TestBurnReservedBalance(t *testing.T) {
+	ctx := context.Background()
+	db := testDB{}
+	owner := testKey(t).PublicKey()
+	base := ids.GenerateTestID()
+	rules := testRules(t, base, testKey(t).PublicKey(), nil)
+	mustSucceed(t, execute(t, db, rules, &CreateAssetAction{Symbol: []byte("BASE")}, owner, base))
+	mustSucceed(t, execute(t, db, rules, &MintAssetAction{To: owner, Asset: base, Value: 10}, owner, ids.GenerateTestID()))
+
+	// A resting sell reserves the base asset it may deliver
+	sell := &AddOrder{Market: testMarket, Side: "sell", Price: 1, Quantity: 6, OrderType: "limit"}
+	mustSucceed(t, execute(t, db, rules, sell, owner, ids.GenerateTestID()))
+
+	// Reserved balance can be neither burned nor transferred, and a failed
+	// burn leaves the balance and supply untouched
+	mustFail(t, execute(t, db, rules, &BurnAssetAction{Asset: base, Value: 5}, owner, ids.GenerateTestID()), OutputBalanceUnavailable)
+	mustFail(t, execute(t, db, rules, &TransferAction{To: testKey(t).PublicKey(), Asset: base, Value: 5}, owner, ids.GenerateTestID()), OutputBalanceUnavailable)
+	asset, err := storage.GetAsset(ctx, db, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := balance(t, db, owner, base); got != 10 || asset.Supply != 10 {
+		t.Fatalf("balance %d and supply %d after a refused burn, want 10 and 10", got, asset.Supply)
+	}
+
+	mustSucceed(t, execute(t, db, rules, &BurnAssetAction{Asset: base, Value: 4}, owner, ids.GenerateTestID()))
+	if got := balance(t, db, owner, base); got != 6 {
+		t.Fatalf("balance %d, want 6", got)
+	}
+	if got := reserved(t, db, owner, base); got != 6 {
+		t.Fatalf("reserved %d, want 6", got)
+	}
+}
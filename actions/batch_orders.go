@@ -0,0 +1,351 @@
+// CLOB/actions/batch_orders.go
+
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"CLOB/auth"
+	"CLOB/genesis"
+	"CLOB/storage"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+	"github.com/ava-labs/hypersdk/crypto"
+	"github.com/ava-labs/hypersdk/utils"
+)
+
+// Kinds of batch instructions
+const (
+	BatchPlace  = "place"
+	BatchCancel = "cancel"
+	BatchAmend  = "amend"
+)
+
+var (
+	ErrBatchAborted       = errors.New("batch aborted by an earlier instruction")
+	ErrUnknownInstruction = errors.New("unknown batch instruction")
+)
+
+// BatchOrdersAction executes place, cancel and amend instructions on one
+// market in order. With AllOrNothing, the first failing instruction aborts
+// the batch and the book is left as it was; otherwise every instruction is
+// attempted and failures only affect themselves. Results holds the outcome
+// of each instruction once executed.
+type BatchOrdersAction struct {
+	Market       string
+	Owner        crypto.PublicKey
+	AllOrNothing bool
+	Actions      []Action // AddOrderAction, CancelOrderAction or AmendOrderAction
+	Results      []error  // Outcome of each action, nil on success
+}
+
+func (a *BatchOrdersAction) MarketID() string {
+	return a.Market
+}
+
+func (a *BatchOrdersAction) Execute(vm VMContext) error {
+	orderBook, err := vm.GetOrderBook(a.Market)
+	if err != nil {
+		return err
+	}
+
+	// All-or-nothing batches run against a copy that is only kept if every
+	// instruction succeeds
+	target := orderBook
+	if a.AllOrNothing {
+		target = orderBook.Clone()
+	}
+	ctx := &bookContext{market: a.Market, book: target, rules: vm.GetRules()}
+
+	a.Results = make([]error, len(a.Actions))
+	for i, action := range a.Actions {
+		a.Results[i] = action.Execute(ctx)
+		if a.Results[i] == nil || !a.AllOrNothing {
+			continue
+		}
+		failed := a.Results[i]
+		for j := range a.Results {
+			if j != i {
+				a.Results[j] = ErrBatchAborted
+			}
+		}
+		return fmt.Errorf("%w: instruction %d: %v", ErrBatchAborted, i, failed)
+	}
+	if a.AllOrNothing {
+		orderBook.Assign(target)
+	}
+	return nil
+}
+
+// bookContext is the VMContext of the instructions of a batch. It only
+// exposes the (possibly speculative) book of the batch's market.
+type bookContext struct {
+	market string
+	book   *storage.OrderBook
+	rules  *genesis.Rules
+}
+
+func (b *bookContext) GetOrderBook(market string) (*storage.OrderBook, error) {
+	if market != b.market {
+		return nil, fmt.Errorf("%w: %s", storage.ErrUnknownMarket, market)
+	}
+	return b.book, nil
+}
+
+func (b *bookContext) GetRules() *genesis.Rules { return b.rules }
+
+func (b *bookContext) Markets() []string { return []string{b.market} }
+
+// BatchInstruction is a single step of a BatchOrders transaction. Place uses
+// ClientOrderID, Side, OrderType, Price and Quantity. Cancel and amend
+// identify their order by exactly one of OrderID and ClientOrderID; amend
+// also sets the new Price and Quantity.
+type BatchInstruction struct {
+	Kind          string  `json:"kind"` // "place", "cancel" or "amend"
+	OrderID       string  `json:"order_id,omitempty"`
+	ClientOrderID string  `json:"client_order_id,omitempty"`
+	Side          string  `json:"side,omitempty"`
+	OrderType     string  `json:"order_type,omitempty"`
+	Price         float64 `json:"price,omitempty"`
+	Quantity      float64 `json:"quantity,omitempty"`
+}
+
+var _ OrderTx = (*BatchOrders)(nil)
+
+// BatchOrders is the transaction payload that executes many order
+// instructions on one market under a single signature. The order placed by
+// instruction i gets the ID storage.NewOrderID(txID, i).
+type BatchOrders struct {
+	Market       string             `json:"market"`
+	AllOrNothing bool               `json:"all_or_nothing"`
+	Instructions []BatchInstruction `json:"instructions"`
+}
+
+func (b *BatchOrders) StateKeys(rauth chain.Auth, _ ids.ID) [][]byte {
+	// The book itself lives in the matching engine; these keys serialize txs
+	// on the same market and account so other markets run in parallel, and
+	// cover the balances the orders are checked against and reserve
+	return orderStateKeys(auth.GetActor(rauth), b.Market)
+}
+
+// Execute validates the batch and applies the balance reservations of its
+// instructions in order: places reserve, cancels release and amends reserve
+// again at their new price and quantity. The engine may still refuse single
+// instructions, but one that cannot be paid for fails the whole batch, so
+// no order reaches the book without its reservation.
+func (b *BatchOrders) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	timestamp int64,
+	rauth chain.Auth,
+	txID ids.ID,
+) (*chain.Result, error) {
+	unitsUsed := b.MaxUnits(r)
+	if output := b.validate(r); output != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: output}, nil
+	}
+
+	market, ok := storage.LookupMarket(b.Market)
+	if !ok {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: RejectUnknownMarket.Output()}, nil
+	}
+	rules, _ := r.(*genesis.Rules)
+	actor := auth.GetActor(rauth)
+	record, err := storage.GetAccountMarket(ctx, db, actor, b.Market)
+	if err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	for i, in := range b.Instructions {
+		var rerr *RejectError
+		switch in.Kind {
+		case BatchPlace:
+			if rerr = countOrder(rules, record, timestamp); rerr != nil {
+				break
+			}
+			rerr, err = reserveOrder(
+				ctx, db, rules, actor, market, record,
+				storage.NewOrderID(txID, i), in.ClientOrderID,
+				storage.Side(in.Side), storage.OrderType(in.OrderType), in.Price, in.Quantity,
+			)
+		case BatchCancel:
+			_, _, err = releaseOrder(ctx, db, actor, market, record, in.OrderID, in.ClientOrderID)
+		case BatchAmend:
+			var (
+				reserved storage.Reservation
+				found    bool
+			)
+			reserved, found, err = releaseOrder(ctx, db, actor, market, record, in.OrderID, in.ClientOrderID)
+			if err == nil && found {
+				rerr, err = reserveOrder(
+					ctx, db, rules, actor, market, record,
+					reserved.OrderID, reserved.ClientOrderID,
+					reserved.Side, storage.Limit, in.Price, in.Quantity,
+				)
+			}
+		}
+		if err != nil {
+			return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+		}
+		if rerr != nil {
+			return &chain.Result{Success: false, Units: unitsUsed, Output: rerr.Reason.Output()}, nil
+		}
+	}
+	if err := storage.SetAccountMarket(ctx, db, actor, b.Market, record); err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	return &chain.Result{Success: true, Units: unitsUsed}, nil
+}
+
+// validate checks the shape of every instruction. Whether they apply to the
+// book is only known once the matching engine executes them.
+func (b *BatchOrders) validate(r chain.Rules) []byte {
+	if len(b.Market) == 0 {
+		return OutputMarketEmpty
+	}
+	if len(b.Instructions) == 0 {
+		return OutputBatchEmpty
+	}
+	if rules, ok := r.(*genesis.Rules); ok && len(b.Instructions) > rules.GetMaxBatchInstructions() {
+		return OutputBatchTooLarge
+	}
+	for _, in := range b.Instructions {
+		var output []byte
+		switch in.Kind {
+		case BatchPlace:
+			add := &AddOrder{
+				ClientOrderID: in.ClientOrderID,
+				Market:        b.Market,
+				Side:          in.Side,
+				Price:         in.Price,
+				Quantity:      in.Quantity,
+				OrderType:     in.OrderType,
+			}
+			output = add.validate()
+		case BatchCancel, BatchAmend:
+			switch {
+			case len(in.OrderID) == 0 && len(in.ClientOrderID) == 0:
+				output = OutputOrderIDEmpty
+			case len(in.OrderID) > 0 && len(in.ClientOrderID) > 0:
+				output = OutputOrderIDConflict
+			case in.Kind == BatchAmend && (!(in.Price > 0) || math.IsInf(in.Price, 0)):
+				output = OutputInvalidPrice
+			case in.Kind == BatchAmend && (!(in.Quantity > 0) || math.IsInf(in.Quantity, 0)):
+				output = OutputInvalidQuantity
+			}
+		default:
+			output = OutputInvalidInstruction
+		}
+		if output != nil {
+			return output
+		}
+	}
+	return nil
+}
+
+func (b *BatchOrders) EngineAction(actor crypto.PublicKey, txID ids.ID, timestamp int64) Action {
+	batch := &BatchOrdersAction{
+		Market:       b.Market,
+		Owner:        actor,
+		AllOrNothing: b.AllOrNothing,
+		Actions:      make([]Action, len(b.Instructions)),
+	}
+	for i, in := range b.Instructions {
+		switch in.Kind {
+		case BatchPlace:
+			add := &AddOrder{
+				ClientOrderID: in.ClientOrderID,
+				Market:        b.Market,
+				Side:          in.Side,
+				Price:         in.Price,
+				Quantity:      in.Quantity,
+				OrderType:     in.OrderType,
+			}
+			batch.Actions[i] = add.engineAction(actor, storage.NewOrderID(txID, i), timestamp)
+		case BatchCancel:
+			batch.Actions[i] = &CancelOrderAction{
+				OrderID:       in.OrderID,
+				ClientOrderID: in.ClientOrderID,
+				Market:        b.Market,
+				Owner:         actor,
+			}
+		case BatchAmend:
+			batch.Actions[i] = &AmendOrderAction{
+				OrderID:       in.OrderID,
+				ClientOrderID: in.ClientOrderID,
+				Market:        b.Market,
+				Owner:         actor,
+				Price:         in.Price,
+				Quantity:      in.Quantity,
+			}
+		default:
+			batch.Actions[i] = &invalidAction{kind: in.Kind}
+		}
+	}
+	return batch
+}
+
+// invalidAction stands in for an instruction of unknown kind, which chain
+// validation rejects before it can reach the engine
+type invalidAction struct {
+	kind string
+}
+
+func (a *invalidAction) Execute(VMContext) error {
+	return fmt.Errorf("%w: %s", ErrUnknownInstruction, a.kind)
+}
+
+func (b *BatchOrders) MaxUnits(chain.Rules) uint64 {
+	units := uint64(len(b.Market)) + consts.BoolLen
+	for _, in := range b.Instructions {
+		units += uint64(len(in.Kind)+len(in.OrderID)+len(in.ClientOrderID)+len(in.Side)+len(in.OrderType)) + consts.Uint64Len*2
+	}
+	return units
+}
+
+func (b *BatchOrders) Marshal(p *codec.Packer) {
+	p.PackString(b.Market)
+	p.PackBool(b.AllOrNothing)
+	p.PackInt(len(b.Instructions))
+	for _, in := range b.Instructions {
+		p.PackString(in.Kind)
+		p.PackString(in.OrderID)
+		p.PackString(in.ClientOrderID)
+		p.PackString(in.Side)
+		p.PackString(in.OrderType)
+		p.PackUint64(math.Float64bits(in.Price))
+		p.PackUint64(math.Float64bits(in.Quantity))
+	}
+}
+
+func UnmarshalBatchOrders(p *codec.Packer, _ *codec.Message) (chain.Action, error) {
+	var batch BatchOrders
+	batch.Market = p.UnpackString(true)
+	batch.AllOrNothing = p.UnpackBool()
+	count := p.UnpackInt(true)
+	// Instructions are appended one by one so a forged count cannot force a
+	// large allocation; unpacking stops at the first error
+	for i := 0; i < count && p.Err() == nil; i++ {
+		var in BatchInstruction
+		in.Kind = p.UnpackString(true)
+		in.OrderID = p.UnpackString(false)
+		in.ClientOrderID = p.UnpackString(false)
+		in.Side = p.UnpackString(false)
+		in.OrderType = p.UnpackString(false)
+		in.Price = math.Float64frombits(p.UnpackUint64(false))
+		in.Quantity = math.Float64frombits(p.UnpackUint64(false))
+		batch.Instructions = append(batch.Instructions, in)
+	}
+	return &batch, p.Err()
+}
+
+func (*BatchOrders) ValidRange(chain.Rules) (int64, int64) {
+	// Returning -1, -1 means that the action is always valid.
+	return -1, -1
+}
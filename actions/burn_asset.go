@@ -0,0 +1,105 @@
+// CLOB/actions/burn_asset.go
+
+package actions
+
+import (
+	"context"
+	"errors"
+
+	"CLOB/auth"
+	"CLOB/storage"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+	"github.com/ava-labs/hypersdk/utils"
+)
+
+var _ chain.Action = (*BurnAssetAction)(nil)
+
+// BurnAssetAction destroys [Value] units of [Asset] held by the signer and
+// reduces the asset's supply accordingly. Any holder may burn.
+type BurnAssetAction struct {
+	Asset ids.ID `json:"asset"`
+	Value uint64 `json:"value"`
+}
+
+func (b *BurnAssetAction) StateKeys(rauth chain.Auth, _ ids.ID) [][]byte {
+	actor := auth.GetActor(rauth)
+	return [][]byte{
+		storage.AssetKey(b.Asset),
+		storage.BalanceKey(actor, b.Asset),
+		storage.ReservedKey(actor, b.Asset),
+	}
+}
+
+func (b *BurnAssetAction) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	_ int64,
+	rauth chain.Auth,
+	_ ids.ID,
+) (*chain.Result, error) {
+	actor := auth.GetActor(rauth)
+	unitsUsed := b.MaxUnits(r)
+
+	if b.Asset == storage.NativeAsset {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputAssetIsNative}, nil
+	}
+	if b.Value == 0 {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputValueZero}, nil
+	}
+
+	// Check the asset before touching any balance, so a failed burn leaves
+	// state as it was
+	asset, err := storage.GetAsset(ctx, db, b.Asset)
+	if errors.Is(err, storage.ErrAssetNotFound) {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputAssetMissing}, nil
+	}
+	if err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	if asset.Supply < b.Value {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputInsufficientSupply}, nil
+	}
+
+	// Balance reserved by resting orders cannot leave the account
+	available, err := storage.GetAvailableBalance(ctx, db, actor, b.Asset)
+	if err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	if available < b.Value {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputBalanceUnavailable}, nil
+	}
+	if err := storage.SubtractBalance(ctx, db, actor, b.Asset, b.Value); err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	asset.Supply -= b.Value
+	if err := storage.SetAsset(ctx, db, b.Asset, asset); err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	return &chain.Result{Success: true, Units: unitsUsed}, nil
+}
+
+func (*BurnAssetAction) MaxUnits(chain.Rules) uint64 {
+	return consts.IDLen + consts.Uint64Len
+}
+
+func (b *BurnAssetAction) Marshal(p *codec.Packer) {
+	p.PackID(b.Asset)
+	p.PackUint64(b.Value)
+}
+
+func UnmarshalBurnAsset(p *codec.Packer, _ *codec.Message) (chain.Action, error) {
+	var burn BurnAssetAction
+	p.UnpackID(true, &burn.Asset)
+	burn.Value = p.UnpackUint64(true)
+	return &burn, p.Err()
+}
+
+func (*BurnAssetAction) ValidRange(chain.Rules) (int64, int64) {
+	// Returning -1, -1 means that the action is always valid.
+	return -1, -1
+}
@@ -0,0 +1,95 @@
+// CLOB/actions/cancel_after.go
+
+package actions
+
+import (
+	"context"
+	"errors"
+
+	"CLOB/auth"
+	"CLOB/storage"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+	"github.com/ava-labs/hypersdk/crypto"
+)
+
+var ErrNoCancelTimers = errors.New("context does not expose cancel timers")
+
+// SetCancelAfterAction arms, renews or disarms the dead-man's switch of
+// Owner. Unless it is renewed in time, every resting order of Owner expires
+// in the first block at or past Height or Timestamp, before that block's txs
+// execute. Setting neither deadline disarms the switch. The switch applies to
+// every market, so the action is not market-scoped.
+type SetCancelAfterAction struct {
+	Owner     crypto.PublicKey
+	Height    uint64 // 0 if unset
+	Timestamp int64  // Unix seconds, 0 if unset
+}
+
+func (a *SetCancelAfterAction) Execute(vm VMContext) error {
+	tctx, ok := vm.(TimerContext)
+	if !ok {
+		return ErrNoCancelTimers
+	}
+	tctx.GetCancelTimers().Set(storage.CancelTimer{Owner: a.Owner, Height: a.Height, Timestamp: a.Timestamp})
+	return nil
+}
+
+var _ OrderTx = (*SetCancelAfter)(nil)
+
+// SetCancelAfter is the transaction payload that sets the signer's
+// dead-man's switch. Once its block is verified it is executed by the
+// matching engine as a SetCancelAfterAction.
+type SetCancelAfter struct {
+	Height    uint64 `json:"height,omitempty"`    // Expire orders at this block height
+	Timestamp int64  `json:"timestamp,omitempty"` // Expire orders at this block time
+}
+
+func (s *SetCancelAfter) StateKeys(rauth chain.Auth, _ ids.ID) [][]byte {
+	// The timer itself lives in the matching engine; this key only orders
+	// renewals of the same account
+	return [][]byte{storage.CancelAfterKey(auth.GetActor(rauth))}
+}
+
+func (s *SetCancelAfter) Execute(
+	_ context.Context,
+	r chain.Rules,
+	_ chain.Database,
+	timestamp int64,
+	_ chain.Auth,
+	_ ids.ID,
+) (*chain.Result, error) {
+	unitsUsed := s.MaxUnits(r)
+	if s.Timestamp != 0 && s.Timestamp <= timestamp {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputDeadlinePassed}, nil
+	}
+	return &chain.Result{Success: true, Units: unitsUsed}, nil
+}
+
+func (s *SetCancelAfter) EngineAction(actor crypto.PublicKey, _ ids.ID, _ int64) Action {
+	return &SetCancelAfterAction{Owner: actor, Height: s.Height, Timestamp: s.Timestamp}
+}
+
+func (*SetCancelAfter) MaxUnits(chain.Rules) uint64 {
+	return consts.Uint64Len * 2
+}
+
+func (s *SetCancelAfter) Marshal(p *codec.Packer) {
+	p.PackUint64(s.Height)
+	p.PackInt64(s.Timestamp)
+}
+
+func UnmarshalSetCancelAfter(p *codec.Packer, _ *codec.Message) (chain.Action, error) {
+	var set SetCancelAfter
+	set.Height = p.UnpackUint64(false)
+	set.Timestamp = p.UnpackInt64(false)
+	return &set, p.Err()
+}
+
+func (*SetCancelAfter) ValidRange(chain.Rules) (int64, int64) {
+	// Returning -1, -1 means that the action is always valid.
+	return -1, -1
+}
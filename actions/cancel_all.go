@@ -0,0 +1,153 @@
+// CLOB/actions/cancel_all.go
+
+package actions
+
+import (
+	"context"
+
+	"CLOB/auth"
+	"CLOB/storage"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/crypto"
+	"github.com/ava-labs/hypersdk/utils"
+)
+
+// CancelAllAction cancels every resting order of Owner, optionally only in
+// Market and only on Side. Orders are found through the owner index of each
+// book, so the cost is proportional to the owner's orders, not to the book.
+// Cancelled holds the IDs of the cancelled orders once executed.
+type CancelAllAction struct {
+	Owner     crypto.PublicKey
+	Market    string       // Empty cancels in every market
+	Side      storage.Side // Empty cancels both sides
+	Cancelled []string
+}
+
+// MarketID returns the market the action is confined to, or the empty string
+// if it cancels in every market.
+func (a *CancelAllAction) MarketID() string {
+	return a.Market
+}
+
+func (a *CancelAllAction) Execute(vm VMContext) error {
+	markets := []string{a.Market}
+	if a.Market == "" {
+		markets = vm.Markets()
+	}
+
+	a.Cancelled = nil
+	for _, market := range markets {
+		orderBook, err := vm.GetOrderBook(market)
+		if err != nil {
+			return err
+		}
+		for _, order := range orderBook.OrdersOf(a.Owner) {
+			if a.Side != "" && order.Side != a.Side {
+				continue
+			}
+			if err := orderBook.CancelOrder(order); err != nil {
+				return err
+			}
+			a.Cancelled = append(a.Cancelled, order.ID)
+		}
+	}
+	return nil
+}
+
+var _ OrderTx = (*CancelAll)(nil)
+
+// CancelAll is the transaction payload that cancels every resting order of
+// the signer, optionally filtered by market and side. Once its block is
+// verified it is executed by the matching engine as a CancelAllAction.
+type CancelAll struct {
+	Market string `json:"market,omitempty"` // Empty cancels in every market
+	Side   string `json:"side,omitempty"`   // Empty cancels both sides
+}
+
+func (c *CancelAll) StateKeys(rauth chain.Auth, _ ids.ID) [][]byte {
+	// The book itself lives in the matching engine; these keys serialize txs
+	// on the same market and account so other markets run in parallel, and
+	// cover the balances the orders reserved. The engine executes a cancel in
+	// every market alone, in block order.
+	actor := auth.GetActor(rauth)
+	if c.Market != "" {
+		return reservationStateKeys(actor, c.Market)
+	}
+	keys := [][]byte{
+		storage.MarketKey(c.Market),
+		storage.AccountMarketKey(actor, c.Market),
+	}
+	for _, market := range c.markets() {
+		keys = append(keys, reservationStateKeys(actor, market.ID)...)
+	}
+	return keys
+}
+
+// markets returns the markets the cancel applies to
+func (c *CancelAll) markets() []storage.MarketInfo {
+	if c.Market == "" {
+		return storage.RegisteredMarkets()
+	}
+	if market, ok := storage.LookupMarket(c.Market); ok {
+		return []storage.MarketInfo{market}
+	}
+	return nil
+}
+
+func (c *CancelAll) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	_ int64,
+	rauth chain.Auth,
+	_ ids.ID,
+) (*chain.Result, error) {
+	unitsUsed := c.MaxUnits(r)
+	if c.Side != "" && c.Side != string(storage.Buy) && c.Side != string(storage.Sell) {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputInvalidSide}, nil
+	}
+
+	// Reservations are released even for orders that already left the book
+	actor := auth.GetActor(rauth)
+	for _, market := range c.markets() {
+		record, err := storage.GetAccountMarket(ctx, db, actor, market.ID)
+		if err != nil {
+			return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+		}
+		if err := releaseAll(ctx, db, actor, market, record, storage.Side(c.Side)); err != nil {
+			return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+		}
+		if err := storage.SetAccountMarket(ctx, db, actor, market.ID, record); err != nil {
+			return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+		}
+	}
+	return &chain.Result{Success: true, Units: unitsUsed}, nil
+}
+
+func (c *CancelAll) EngineAction(actor crypto.PublicKey, _ ids.ID, _ int64) Action {
+	return &CancelAllAction{Owner: actor, Market: c.Market, Side: storage.Side(c.Side)}
+}
+
+func (c *CancelAll) MaxUnits(chain.Rules) uint64 {
+	return uint64(len(c.Market) + len(c.Side))
+}
+
+func (c *CancelAll) Marshal(p *codec.Packer) {
+	p.PackString(c.Market)
+	p.PackString(c.Side)
+}
+
+func UnmarshalCancelAll(p *codec.Packer, _ *codec.Message) (chain.Action, error) {
+	var cancel CancelAll
+	cancel.Market = p.UnpackString(false)
+	cancel.Side = p.UnpackString(false)
+	return &cancel, p.Err()
+}
+
+func (*CancelAll) ValidRange(chain.Rules) (int64, int64) {
+	// Returning -1, -1 means that the action is always valid.
+	return -1, -1
+}
@@ -1,21 +1,43 @@
 // CLOB/actions/cancel_order.go
 package actions
 
-import "CLOB/storage"
+import (
+    "context"
 
-/
+    "CLOB/auth"
+    "CLOB/storage"
+
+    "github.com/ava-labs/avalanchego/ids"
+    "github.com/ava-labs/hypersdk/chain"
+    "github.com/ava-labs/hypersdk/codec"
+    "github.com/ava-labs/hypersdk/crypto"
+    "github.com/ava-labs/hypersdk/utils"
+)
+
+// CancelOrderAction represents an action to cancel an existing order.
 // This struct encapsulates the information needed to cancel an order in the order book.
-// It includes the `OrderID` field, which specifies the ID of the order to be canceled.
+// It includes the `OrderID` field, which specifies the ID of the order to be canceled,
+// or, when it is empty, the `ClientOrderID` the owner gave the order, and the `Owner`
+// field, which, when set, must match the owner of the order.
 type CancelOrderAction struct {
-    OrderID string // The unique identifier of the order to cancel.
+    OrderID       string           // The canonical identifier of the order to cancel.
+    ClientOrderID string           // The owner's identifier of the order, used if OrderID is empty.
+    Market        string           // The market whose book holds the order.
+    Owner         crypto.PublicKey // The account requesting the cancel (empty skips the check).
+}
+
+// MarketID returns the market whose book the cancel touches.
+func (a *CancelOrderAction) MarketID() string {
+    return a.Market
 }
 
 // The `Execute` method implements the logic to cancel an order in the order book.
 // It performs the following steps:
 // 1. Retrieve the order book from the VMContext.
-// 2. Look up the order in the `OrderMap` using the provided `OrderID`.
-// 3. If the order exists, invoke the `CancelOrder` method to remove it from the order book.
-// 4. Return an error if the order does not exist.
+// 2. Look up the order by `OrderID`, or by the owner's `ClientOrderID`.
+// 3. Check that the order belongs to the account requesting the cancel.
+// 4. If the order exists, invoke the `CancelOrder` method to remove it from the order book.
+// 5. Return an error if the order does not exist.
 //
 // Parameters:
 // - vm (VMContext): The virtual machine context that provides access to the order book.
@@ -23,22 +45,113 @@ type CancelOrderAction struct {
 // Returns:
 // - error: An error if the order cannot be found or if there are issues during cancellation.
 func (a *CancelOrderAction) Execute(vm VMContext) error {
-    
+
     // The `VMContext` provides access to the shared state of the order book.
     // This allows the function to interact with the current orders.
-    orderBook := vm.GetOrderBook()
+    orderBook, err := vm.GetOrderBook(a.Market)
+    if err != nil {
+        return err
+    }
 
-    // The `OrderMap` is a mapping of order IDs to their corresponding order objects.
-    // Check if the order exists in the map using the provided `OrderID`.
-    order, exists := orderBook.OrderMap[a.OrderID]
+    // Check if the order rests in the book, by canonical ID or, failing that,
+    // by the client order ID the owner gave it.
+    order, exists := orderBook.LookupOrder(a.Owner, a.OrderID, a.ClientOrderID)
     if !exists {
         // If the order does not exist, return a predefined error indicating that
         // the order was not found.
         return storage.ErrOrderNotFound
     }
 
-    
+    // Only the account that placed the order may cancel it.
+    if a.Owner != crypto.EmptyPublicKey && order.Owner != a.Owner {
+        return storage.ErrNotOrderOwner
+    }
+
     // If the order exists, call the `CancelOrder` method on the order book to
     // remove the order and perform any necessary cleanup.
     return orderBook.CancelOrder(order)
 }
+
+var _ OrderTx = (*CancelOrder)(nil)
+
+// CancelOrder is the transaction payload that cancels an order, identified by
+// exactly one of its canonical ID and the client order ID the signer gave it.
+// Once its block is verified it is executed by the matching engine as a
+// CancelOrderAction on behalf of the signer.
+type CancelOrder struct {
+    OrderID       string `json:"order_id,omitempty"`
+    ClientOrderID string `json:"client_order_id,omitempty"`
+    Market        string `json:"market"`
+}
+
+func (c *CancelOrder) StateKeys(rauth chain.Auth, _ ids.ID) [][]byte {
+    // The book itself lives in the matching engine; these keys serialize txs
+    // on the same market and account so other markets run in parallel, and
+    // cover the balance the order reserved
+    return reservationStateKeys(auth.GetActor(rauth), c.Market)
+}
+
+func (c *CancelOrder) Execute(
+    ctx context.Context,
+    r chain.Rules,
+    db chain.Database,
+    _ int64,
+    rauth chain.Auth,
+    _ ids.ID,
+) (*chain.Result, error) {
+    unitsUsed := c.MaxUnits(r)
+    if len(c.OrderID) == 0 && len(c.ClientOrderID) == 0 {
+        return &chain.Result{Success: false, Units: unitsUsed, Output: OutputOrderIDEmpty}, nil
+    }
+    if len(c.OrderID) > 0 && len(c.ClientOrderID) > 0 {
+        return &chain.Result{Success: false, Units: unitsUsed, Output: OutputOrderIDConflict}, nil
+    }
+    if len(c.Market) == 0 {
+        return &chain.Result{Success: false, Units: unitsUsed, Output: OutputMarketEmpty}, nil
+    }
+
+    // The reservation is released even if the order already left the book
+    market, ok := storage.LookupMarket(c.Market)
+    if !ok {
+        return &chain.Result{Success: true, Units: unitsUsed}, nil
+    }
+    actor := auth.GetActor(rauth)
+    record, err := storage.GetAccountMarket(ctx, db, actor, c.Market)
+    if err != nil {
+        return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+    }
+    if _, _, err := releaseOrder(ctx, db, actor, market, record, c.OrderID, c.ClientOrderID); err != nil {
+        return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+    }
+    if err := storage.SetAccountMarket(ctx, db, actor, c.Market, record); err != nil {
+        return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+    }
+    return &chain.Result{Success: true, Units: unitsUsed}, nil
+}
+
+func (c *CancelOrder) EngineAction(actor crypto.PublicKey, _ ids.ID, _ int64) Action {
+    return &CancelOrderAction{OrderID: c.OrderID, ClientOrderID: c.ClientOrderID, Market: c.Market, Owner: actor}
+}
+
+func (c *CancelOrder) MaxUnits(chain.Rules) uint64 {
+    return uint64(len(c.OrderID) + len(c.ClientOrderID) + len(c.Market))
+}
+
+func (c *CancelOrder) Marshal(p *codec.Packer) {
+    p.PackString(c.OrderID)
+    p.PackString(c.ClientOrderID)
+    p.PackString(c.Market)
+}
+
+func UnmarshalCancelOrder(p *codec.Packer, _ *codec.Message) (chain.Action, error) {
+    var cancel CancelOrder
+    cancel.OrderID = p.UnpackString(false)
+    cancel.ClientOrderID = p.UnpackString(false)
+    cancel.Market = p.UnpackString(true)
+    return &cancel, p.Err()
+}
+
+func (*CancelOrder) ValidRange(chain.Rules) (int64, int64) {
+    // Returning -1, -1 means that the action is always valid.
+    return -1, -1
+}
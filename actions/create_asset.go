@@ -0,0 +1,91 @@
+// CLOB/actions/create_asset.go
+
+package actions
+
+import (
+	"context"
+
+	"CLOB/auth"
+	"CLOB/storage"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+	"github.com/ava-labs/hypersdk/utils"
+)
+
+var _ chain.Action = (*CreateAssetAction)(nil)
+
+// CreateAssetAction registers a new asset owned by the signer. The ID of the
+// new asset is the ID of the transaction that created it, and its supply
+// starts at zero until the owner mints.
+type CreateAssetAction struct {
+	Symbol   []byte `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+	Metadata []byte `json:"metadata"`
+}
+
+func (*CreateAssetAction) StateKeys(_ chain.Auth, txID ids.ID) [][]byte {
+	return [][]byte{storage.AssetKey(txID)}
+}
+
+func (c *CreateAssetAction) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	_ int64,
+	rauth chain.Auth,
+	txID ids.ID,
+) (*chain.Result, error) {
+	actor := auth.GetActor(rauth)
+	unitsUsed := c.MaxUnits(r)
+
+	if len(c.Symbol) == 0 {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputSymbolEmpty}, nil
+	}
+	if len(c.Symbol) > storage.MaxSymbolSize {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputSymbolTooLarge}, nil
+	}
+	if c.Decimals > storage.MaxDecimals {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputDecimalsTooLarge}, nil
+	}
+	if len(c.Metadata) > storage.MaxAssetMetadataSize {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputMetadataTooLarge}, nil
+	}
+
+	asset := &storage.Asset{
+		Symbol:   c.Symbol,
+		Decimals: c.Decimals,
+		Metadata: c.Metadata,
+		Supply:   0,
+		Owner:    actor,
+	}
+	if err := storage.SetAsset(ctx, db, txID, asset); err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	return &chain.Result{Success: true, Units: unitsUsed}, nil
+}
+
+func (c *CreateAssetAction) MaxUnits(chain.Rules) uint64 {
+	return uint64(len(c.Symbol)) + consts.Uint8Len + uint64(len(c.Metadata))
+}
+
+func (c *CreateAssetAction) Marshal(p *codec.Packer) {
+	p.PackBytes(c.Symbol)
+	p.PackByte(c.Decimals)
+	p.PackBytes(c.Metadata)
+}
+
+func UnmarshalCreateAsset(p *codec.Packer, _ *codec.Message) (chain.Action, error) {
+	var create CreateAssetAction
+	p.UnpackBytes(storage.MaxSymbolSize, true, &create.Symbol)
+	create.Decimals = p.UnpackByte()
+	p.UnpackBytes(storage.MaxAssetMetadataSize, false, &create.Metadata)
+	return &create, p.Err()
+}
+
+func (*CreateAssetAction) ValidRange(chain.Rules) (int64, int64) {
+	// Returning -1, -1 means that the action is always valid.
+	return -1, -1
+}
@@ -0,0 +1,131 @@
+// CLOB/actions/helpers_test.go
+
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"CLOB/auth"
+	"CLOB/genesis"
+	"CLOB/storage"
+	"CLOB/utils"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/crypto"
+)
+
+const testMarket = "test"
+
+// testDB is an in-memory chain.Database
+type testDB map[string][]byte
+
+func (db testDB) GetValue(_ context.Context, key []byte) ([]byte, error) {
+	v, ok := db[string(key)]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	return v, nil
+}
+
+func (db testDB) Insert(_ context.Context, key []byte, value []byte) error {
+	db[string(key)] = value
+	return nil
+}
+
+func (db testDB) Remove(_ context.Context, key []byte) error {
+	delete(db, string(key))
+	return nil
+}
+
+// testKey returns a new account key
+func testKey(t *testing.T) crypto.PrivateKey {
+	t.Helper()
+	k, err := crypto.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// testRules returns the rules of a genesis with one market, trading
+// [base] against the native asset and settled by [operator], with
+// [overrides] applied on top. The market is registered for the test.
+func testRules(t *testing.T, base ids.ID, operator crypto.PublicKey, overrides map[string]any) *genesis.Rules {
+	t.Helper()
+	config := map[string]any{
+		"operator":       utils.Address(operator),
+		"markets":        []map[string]any{{"id": testMarket, "base_asset": base.String()}},
+		"initial_orders": []any{},
+	}
+	for k, v := range overrides {
+		config[k] = v
+	}
+	b, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := genesis.New(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	markets, err := g.GetMarkets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.RegisterMarkets(markets)
+	t.Cleanup(func() { storage.RegisterMarkets(nil) })
+	return g.Rules(0)
+}
+
+// execute runs [action] signed by [actor] and returns its result
+func execute(t *testing.T, db chain.Database, rules chain.Rules, action chain.Action, actor crypto.PublicKey, txID ids.ID) *chain.Result {
+	t.Helper()
+	result, err := action.Execute(context.Background(), rules, db, 0, &auth.ED25519{Signer: actor}, txID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// mustSucceed fails the test unless [result] is a success
+func mustSucceed(t *testing.T, result *chain.Result) {
+	t.Helper()
+	if !result.Success {
+		t.Fatalf("action failed: %s", result.Output)
+	}
+}
+
+// mustFail fails the test unless [result] failed with [output]
+func mustFail(t *testing.T, result *chain.Result, output []byte) {
+	t.Helper()
+	if result.Success {
+		t.Fatalf("action succeeded, want %q", output)
+	}
+	if string(result.Output) != string(output) {
+		t.Fatalf("action failed with %q, want %q", result.Output, output)
+	}
+}
+
+// balance returns the balance of [pk] in [asset]
+func balance(t *testing.T, db chain.Database, pk crypto.PublicKey, asset ids.ID) uint64 {
+	t.Helper()
+	bal, err := storage.GetBalance(context.Background(), db, pk, asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bal
+}
+
+// reserved returns the balance of [pk] in [asset] its orders reserved
+func reserved(t *testing.T, db chain.Database, pk crypto.PublicKey, asset ids.ID) uint64 {
+	t.Helper()
+	r, err := storage.GetReserved(context.Background(), db, pk, asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
@@ -2,37 +2,49 @@
 package actions
 
 import (
+    "CLOB/storage"
     "container/heap"
     "errors"
-    "CLOB/storage"
 )
 
-// MatchMarketOrder processes a market order
+// MatchMarketOrder processes a market order, at prices no worse than its
+// price if it has one
 func MatchMarketOrder(orderBook *storage.OrderBook, order *storage.Order) error {
     // Get the opposite side of the order (buy/sell)
     oppositeSide := orderBook.GetOppositeSide(order.Side)
+    compare := storage.GetPriceComparator(order.Side)
     remainingQty := order.Quantity
 
     // Loop until the order is fully matched or no orders left on the opposite side
     for remainingQty > 0 && oppositeSide.Prices.Len() > 0 {
+        // Never fill beyond the order's price: a buy was only checked against
+        // what it costs at that price. A sell without a price takes any bid.
+        if order.Price > 0 && !compare(oppositeSide.PeekBestPriceLevel().Price, order.Price) {
+            break
+        }
+
         // Get the best price level from the opposite side
         bestPriceLevel := heap.Pop(oppositeSide.Prices).(*storage.PriceLevel)
         ordersQueue := bestPriceLevel.Orders
 
         // Process orders in the queue until the market order is matched or queue is empty
         for ordersQueue.Size > 0 && remainingQty > 0 {
-            headOrder := ordersQueue.Dequeue() // Get the next order in the queue
+            headOrder := ordersQueue.Head()                           // Get the next order in the queue
             tradeQty := storage.Min(remainingQty, headOrder.Quantity) // Determine trade quantity
 
             // Settle the trade
-            storage.SettleTrade(order, headOrder, tradeQty)
+            orderBook.SettleTrade(order, headOrder, tradeQty)
 
-            remainingQty -= tradeQty // Decrease remaining quantity
+            remainingQty -= tradeQty       // Decrease remaining quantity
             headOrder.Quantity -= tradeQty // Decrease head order quantity
 
-            // Remove head order if fully matched
+            // Remove head order if fully matched, a partially filled one keeps its priority
             if headOrder.Quantity == 0 {
-                delete(orderBook.OrderMap, headOrder.ID)
+                ordersQueue.Dequeue()
+                orderBook.RemoveOrder(headOrder)
+                orderBook.RecordUpdate(headOrder, storage.StatusFilled, storage.ReasonMatched)
+            } else {
+                orderBook.RecordUpdate(headOrder, storage.StatusPartiallyFilled, storage.ReasonMatched)
             }
 
             // Break if the market order is fully matched
@@ -52,8 +64,13 @@ func MatchMarketOrder(orderBook *storage.OrderBook, order *storage.Order) error
     // Return error if the market order could not be fully matched
     if remainingQty == 0 {
         delete(orderBook.OrderMap, order.ID)
+        order.Quantity = 0
+        orderBook.RecordUpdate(order, storage.StatusFilled, storage.ReasonMatched)
         return nil
     } else {
+        // The unmatched remainder never rests in the book
+        order.Quantity = remainingQty
+        orderBook.RecordUpdate(order, storage.StatusCancelled, storage.ReasonMarketUnfilled)
         return errors.New("market order could not be fully matched")
     }
 }
@@ -76,18 +93,22 @@ func MatchLimitOrder(orderBook *storage.OrderBook, order *storage.Order) error {
 
             // Process orders in the queue until the limit order is matched or queue is empty
             for ordersQueue.Size > 0 && remainingQty > 0 {
-                headOrder := ordersQueue.Dequeue() // Get the next order in the queue
+                headOrder := ordersQueue.Head()                           // Get the next order in the queue
                 tradeQty := storage.Min(remainingQty, headOrder.Quantity) // Determine trade quantity
 
                 // Settle the trade
-                storage.SettleTrade(order, headOrder, tradeQty)
+                orderBook.SettleTrade(order, headOrder, tradeQty)
 
-                remainingQty -= tradeQty // Decrease remaining quantity
+                remainingQty -= tradeQty       // Decrease remaining quantity
                 headOrder.Quantity -= tradeQty // Decrease head order quantity
 
-                // Remove head order if fully matched
+                // Remove head order if fully matched, a partially filled one keeps its priority
                 if headOrder.Quantity == 0 {
-                    delete(orderBook.OrderMap, headOrder.ID)
+                    ordersQueue.Dequeue()
+                    orderBook.RemoveOrder(headOrder)
+                    orderBook.RecordUpdate(headOrder, storage.StatusFilled, storage.ReasonMatched)
+                } else {
+                    orderBook.RecordUpdate(headOrder, storage.StatusPartiallyFilled, storage.ReasonMatched)
                 }
 
                 // Break if the limit order is fully matched
@@ -113,6 +134,8 @@ func MatchLimitOrder(orderBook *storage.OrderBook, order *storage.Order) error {
         return orderBook.AddLimitOrder(order)
     } else {
         delete(orderBook.OrderMap, order.ID) // Remove the order if fully matched
+        order.Quantity = 0
+        orderBook.RecordUpdate(order, storage.StatusFilled, storage.ReasonMatched)
     }
     return nil
 }
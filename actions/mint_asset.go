@@ -0,0 +1,102 @@
+// CLOB/actions/mint_asset.go
+
+package actions
+
+import (
+	"context"
+	"errors"
+
+	"CLOB/auth"
+	"CLOB/storage"
+
+	"github.com/ava-labs/avalanchego/ids"
+	smath "github.com/ava-labs/avalanchego/utils/math"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+	"github.com/ava-labs/hypersdk/crypto"
+	"github.com/ava-labs/hypersdk/utils"
+)
+
+var _ chain.Action = (*MintAssetAction)(nil)
+
+// MintAssetAction creates [Value] new units of [Asset] and credits them to
+// [To]. Only the owner of the asset may mint it.
+type MintAssetAction struct {
+	To    crypto.PublicKey `json:"to"`
+	Asset ids.ID           `json:"asset"`
+	Value uint64           `json:"value"`
+}
+
+func (m *MintAssetAction) StateKeys(chain.Auth, ids.ID) [][]byte {
+	return [][]byte{
+		storage.AssetKey(m.Asset),
+		storage.BalanceKey(m.To, m.Asset),
+	}
+}
+
+func (m *MintAssetAction) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	_ int64,
+	rauth chain.Auth,
+	_ ids.ID,
+) (*chain.Result, error) {
+	actor := auth.GetActor(rauth)
+	unitsUsed := m.MaxUnits(r)
+
+	if m.Asset == storage.NativeAsset {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputAssetIsNative}, nil
+	}
+	if m.Value == 0 {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputValueZero}, nil
+	}
+
+	asset, err := storage.GetAsset(ctx, db, m.Asset)
+	if errors.Is(err, storage.ErrAssetNotFound) {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputAssetMissing}, nil
+	}
+	if err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	if asset.Owner != actor {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputWrongOwner}, nil
+	}
+
+	newSupply, err := smath.Add64(asset.Supply, m.Value)
+	if err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputSupplyOverflow}, nil
+	}
+	asset.Supply = newSupply
+	if err := storage.SetAsset(ctx, db, m.Asset, asset); err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	if err := storage.AddBalance(ctx, db, m.To, m.Asset, m.Value); err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	return &chain.Result{Success: true, Units: unitsUsed}, nil
+}
+
+func (*MintAssetAction) MaxUnits(chain.Rules) uint64 {
+	return crypto.PublicKeyLen + consts.IDLen + consts.Uint64Len
+}
+
+func (m *MintAssetAction) Marshal(p *codec.Packer) {
+	p.PackPublicKey(m.To)
+	p.PackID(m.Asset)
+	p.PackUint64(m.Value)
+}
+
+func UnmarshalMintAsset(p *codec.Packer, _ *codec.Message) (chain.Action, error) {
+	var mint MintAssetAction
+	p.UnpackPublicKey(true, &mint.To)
+	p.UnpackID(true, &mint.Asset)
+	mint.Value = p.UnpackUint64(true)
+	return &mint, p.Err()
+}
+
+func (*MintAssetAction) ValidRange(chain.Rules) (int64, int64) {
+	// Returning -1, -1 means that the action is always valid.
+	return -1, -1
+}
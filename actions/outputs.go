@@ -0,0 +1,33 @@
+// CLOB/actions/outputs.go
+
+package actions
+
+// Outputs returned in chain.Result when an action fails. They are stored with
+// the transaction result so clients can tell why it failed.
+var (
+	OutputSymbolEmpty        = []byte("symbol is empty")
+	OutputSymbolTooLarge     = []byte("symbol is too large")
+	OutputDecimalsTooLarge   = []byte("decimals are too large")
+	OutputMetadataTooLarge   = []byte("metadata is too large")
+	OutputAssetMissing       = []byte("asset missing")
+	OutputAssetIsNative      = []byte("cannot mint or burn native asset")
+	OutputWrongOwner         = []byte("wrong owner")
+	OutputValueZero          = []byte("value is zero")
+	OutputSupplyOverflow     = []byte("asset supply overflow")
+	OutputInsufficientSupply = []byte("insufficient asset supply")
+	OutputOrderIDEmpty       = []byte("order ID is empty")
+	OutputOrderIDConflict    = []byte("only one of order ID and client order ID may be set")
+	OutputClientIDTooLarge   = []byte("client order ID is too large")
+	OutputMarketEmpty        = []byte("market is empty")
+	OutputInvalidSide        = []byte("invalid order side")
+	OutputInvalidOrderType   = []byte("invalid order type")
+	OutputInvalidPrice       = []byte("invalid order price")
+	OutputInvalidQuantity    = []byte("invalid order quantity")
+	OutputBatchEmpty         = []byte("batch has no instructions")
+	OutputBatchTooLarge      = []byte("batch has too many instructions")
+	OutputInvalidInstruction = []byte("invalid batch instruction")
+	OutputDeadlinePassed     = []byte("cancel-after deadline has already passed")
+	OutputBalanceUnavailable = []byte("insufficient balance not reserved by resting orders")
+	OutputNotOperator        = []byte("only the operator may settle")
+	OutputMarketUnknown      = []byte("unknown market")
+)
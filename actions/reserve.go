@@ -0,0 +1,191 @@
+// CLOB/actions/reserve.go
+
+package actions
+
+import (
+	"context"
+	"math"
+
+	"CLOB/genesis"
+	"CLOB/storage"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/crypto"
+)
+
+// Balances are checked when an order tx executes on chain, against what the
+// account holds minus what its resting orders reserved. A limit order then
+// reserves what it could cost: its quantity of the base asset for a sell,
+// its value in the quote asset for a buy. Market orders never rest, so they
+// are only checked, a buy for its value at its price.
+//
+// Fills are not settled into chain state (see storage.OrderBook.SettleTrade).
+// Instead, once a block is accepted the operator reports the orders the
+// engine closed in it (filled, cancelled, expired or refused) with a Settle
+// transaction, which releases their reservations. A partially filled order
+// keeps its whole reservation until it leaves the book. Reservations are
+// the open orders the open-order limit counts.
+//
+// The order-to-trade throttle is checked on chain too: orders are counted as
+// their transaction executes, and trades as the operator settles them.
+
+// marketAssets returns the distinct assets of [market]
+func marketAssets(market storage.MarketInfo) []ids.ID {
+	if market.BaseAsset == market.QuoteAsset {
+		return []ids.ID{market.BaseAsset}
+	}
+	return []ids.ID{market.BaseAsset, market.QuoteAsset}
+}
+
+// reservationStateKeys returns the keys a tx of [actor] releasing
+// reservations in [marketID] reads and writes
+func reservationStateKeys(actor crypto.PublicKey, marketID string) [][]byte {
+	keys := [][]byte{
+		storage.MarketKey(marketID),
+		storage.AccountMarketKey(actor, marketID),
+	}
+	if market, ok := storage.LookupMarket(marketID); ok {
+		for _, asset := range marketAssets(market) {
+			keys = append(keys, storage.ReservedKey(actor, asset))
+		}
+	}
+	return keys
+}
+
+// orderStateKeys returns the keys a tx of [actor] placing orders in
+// [marketID] reads and writes
+func orderStateKeys(actor crypto.PublicKey, marketID string) [][]byte {
+	keys := reservationStateKeys(actor, marketID)
+	if market, ok := storage.LookupMarket(marketID); ok {
+		for _, asset := range marketAssets(market) {
+			keys = append(keys, storage.BalanceKey(actor, asset))
+		}
+	}
+	return keys
+}
+
+// reservationOf returns the amount a limit order reserves
+func reservationOf(side storage.Side, price float64, quantity float64) uint64 {
+	if side == storage.Sell {
+		return uint64(math.Ceil(quantity))
+	}
+	return uint64(math.Ceil(price * quantity))
+}
+
+// reserveOrder checks that [actor] can pay for an order in [market] out of
+// its available balances and, for a limit order, reserves what the order
+// could cost in [record]. [rules] may be nil, which skips the open-order
+// limit.
+func reserveOrder(
+	ctx context.Context,
+	db chain.Database,
+	rules *genesis.Rules,
+	actor crypto.PublicKey,
+	market storage.MarketInfo,
+	record *storage.AccountMarket,
+	orderID string,
+	clientOrderID string,
+	side storage.Side,
+	orderType storage.OrderType,
+	price float64,
+	quantity float64,
+) (*RejectError, error) {
+	base, err := storage.GetAvailableBalance(ctx, db, actor, market.BaseAsset)
+	if err != nil {
+		return nil, err
+	}
+	quote, err := storage.GetAvailableBalance(ctx, db, actor, market.QuoteAsset)
+	if err != nil {
+		return nil, err
+	}
+	if rerr := CheckBalance(side, price, quantity, base, quote); rerr != nil {
+		return rerr, nil
+	}
+	if orderType != storage.Limit {
+		return nil, nil
+	}
+	if rules != nil {
+		if rerr := CheckOpenOrders(rules, len(record.Reservations)); rerr != nil {
+			return rerr, nil
+		}
+	}
+
+	amount := reservationOf(side, price, quantity)
+	if err := storage.AddReserved(ctx, db, actor, market.PaymentAsset(side), amount); err != nil {
+		return nil, err
+	}
+	record.Reservations = append(record.Reservations, storage.Reservation{
+		OrderID:       orderID,
+		ClientOrderID: clientOrderID,
+		Side:          side,
+		Amount:        amount,
+	})
+	return nil, nil
+}
+
+// countOrder checks the order-to-trade throttle of the account owning
+// [record] and counts one more order placed at [timestamp]. [rules] may be
+// nil, which skips the throttle. Orders are only counted while the throttle
+// is enabled, so the counters take up no state for nothing.
+func countOrder(rules *genesis.Rules, record *storage.AccountMarket, timestamp int64) *RejectError {
+	if rules == nil {
+		return nil
+	}
+	maxRatio, window, _ := rules.GetOrderToTradeLimit()
+	if maxRatio == 0 {
+		return nil
+	}
+	if rerr := CheckOrderToTrade(rules, record.Activity.At(timestamp, window)); rerr != nil {
+		return rerr
+	}
+	record.Activity.RecordOrder(timestamp, window)
+	return nil
+}
+
+// releaseOrder releases the reservation of the order with [orderID] or, if
+// it is empty, [clientOrderID], and returns it. Orders that reserved nothing
+// release nothing.
+func releaseOrder(
+	ctx context.Context,
+	db chain.Database,
+	actor crypto.PublicKey,
+	market storage.MarketInfo,
+	record *storage.AccountMarket,
+	orderID string,
+	clientOrderID string,
+) (storage.Reservation, bool, error) {
+	i := record.Find(orderID, clientOrderID)
+	if i < 0 {
+		return storage.Reservation{}, false, nil
+	}
+	r := record.Remove(i)
+	if err := storage.SubtractReserved(ctx, db, actor, market.PaymentAsset(r.Side), r.Amount); err != nil {
+		return r, true, err
+	}
+	return r, true, nil
+}
+
+// releaseAll releases the reservations of every order on [side] in
+// [record], or on both sides if it is empty
+func releaseAll(
+	ctx context.Context,
+	db chain.Database,
+	actor crypto.PublicKey,
+	market storage.MarketInfo,
+	record *storage.AccountMarket,
+	side storage.Side,
+) error {
+	kept := record.Reservations[:0]
+	for _, r := range record.Reservations {
+		if side != "" && r.Side != side {
+			kept = append(kept, r)
+			continue
+		}
+		if err := storage.SubtractReserved(ctx, db, actor, market.PaymentAsset(r.Side), r.Amount); err != nil {
+			return err
+		}
+	}
+	record.Reservations = kept
+	return nil
+}
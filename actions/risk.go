@@ -0,0 +1,125 @@
+// CLOB/actions/risk.go
+
+package actions
+
+import (
+	"fmt"
+	"math"
+
+	"CLOB/genesis"
+	"CLOB/storage"
+)
+
+// RejectReason identifies the pre-trade check an order failed
+type RejectReason string
+
+const (
+	RejectInsufficientBalance RejectReason = "insufficient_balance"
+	RejectQuantityTooSmall    RejectReason = "quantity_below_minimum"
+	RejectQuantityTooLarge    RejectReason = "quantity_above_maximum"
+	RejectPriceOutOfBand      RejectReason = "price_out_of_band"
+	RejectTooManyOpenOrders   RejectReason = "too_many_open_orders"
+	RejectBelowMinNotional    RejectReason = "notional_below_minimum"
+	RejectThrottled           RejectReason = "order_to_trade_throttled"
+	RejectUnknownMarket       RejectReason = "unknown_market"
+)
+
+// ParseRejectReason returns the reason [s] names, or false if it names none
+func ParseRejectReason(s string) (RejectReason, bool) {
+	switch r := RejectReason(s); r {
+	case RejectInsufficientBalance, RejectQuantityTooSmall, RejectQuantityTooLarge, RejectPriceOutOfBand,
+		RejectTooManyOpenOrders, RejectBelowMinNotional, RejectThrottled, RejectUnknownMarket:
+		return r, true
+	}
+	return "", false
+}
+
+// Output returns the chain.Result output of an order tx failing the check
+func (r RejectReason) Output() []byte {
+	return []byte(r)
+}
+
+// RejectError is returned when an order fails a pre-trade check. It is
+// serialized as is in RPC replies, so clients can tell reasons apart without
+// matching on messages.
+type RejectError struct {
+	Reason RejectReason `json:"reason"`
+	Detail string       `json:"detail"`
+}
+
+func (e *RejectError) Error() string {
+	return fmt.Sprintf("order rejected (%s): %s", e.Reason, e.Detail)
+}
+
+func reject(reason RejectReason, format string, args ...any) *RejectError {
+	return &RejectError{Reason: reason, Detail: fmt.Sprintf(format, args...)}
+}
+
+// CheckOrderLimits checks an order against the minimum notional, and the
+// size limits and price band of its market. Orders on unknown markets pass;
+// their transaction fails with RejectUnknownMarket.
+func CheckOrderLimits(rules *genesis.Rules, marketID string, orderType storage.OrderType, price float64, quantity float64) *RejectError {
+	// The value of a market order is only known once it matches
+	if min := rules.GetMinNotional(); min > 0 && orderType == storage.Limit && price*quantity < min {
+		return reject(RejectBelowMinNotional, "value %v is below the minimum of %v", price*quantity, min)
+	}
+	market, ok := rules.GetMarket(marketID)
+	if !ok {
+		return nil
+	}
+	if market.MinQuantity > 0 && quantity < market.MinQuantity {
+		return reject(RejectQuantityTooSmall, "quantity %v is below the minimum of %v", quantity, market.MinQuantity)
+	}
+	if market.MaxQuantity > 0 && quantity > market.MaxQuantity {
+		return reject(RejectQuantityTooLarge, "quantity %v is above the maximum of %v", quantity, market.MaxQuantity)
+	}
+	// Market orders take the book's prices, so only limit orders have one
+	if orderType == storage.Limit {
+		if (market.MinPrice > 0 && price < market.MinPrice) || (market.MaxPrice > 0 && price > market.MaxPrice) {
+			return reject(RejectPriceOutOfBand, "price %v is outside of [%v, %v]", price, market.MinPrice, market.MaxPrice)
+		}
+	}
+	return nil
+}
+
+// CheckOpenOrders checks that an account resting [open] orders in a market
+// may rest one more
+func CheckOpenOrders(rules *genesis.Rules, open int) *RejectError {
+	if max := rules.GetMaxOpenOrders(); max > 0 && open >= max {
+		return reject(RejectTooManyOpenOrders, "account already rests %d orders, the maximum", open)
+	}
+	return nil
+}
+
+// CheckOrderToTrade checks that an account with [activity] is not placing
+// too many orders for the trades it makes. Throttled accounts may place
+// orders again once enough of their orders fall out of the rolling window, or
+// once they trade. Throttling is the only penalty: fees do not depend on the
+// ratio.
+func CheckOrderToTrade(rules *genesis.Rules, activity storage.AccountActivity) *RejectError {
+	max, _, minOrders := rules.GetOrderToTradeLimit()
+	if max == 0 || activity.RollingOrders() < minOrders {
+		return nil
+	}
+	if ratio := activity.OrderToTradeRatio(); ratio > max {
+		return reject(RejectThrottled, "placed %d orders for %d trades, more than %v per trade", activity.RollingOrders(), activity.RollingTrades(), max)
+	}
+	return nil
+}
+
+// CheckBalance checks that an account holding [base] and [quote] can pay for
+// an order: its quantity of the base asset for a sell, its value in the quote
+// asset for a buy. A market buy never fills above its price, so its value at
+// that price bounds what it can cost.
+func CheckBalance(side storage.Side, price float64, quantity float64, base uint64, quote uint64) *RejectError {
+	if side == storage.Sell {
+		if need := math.Ceil(quantity); float64(base) < need {
+			return reject(RejectInsufficientBalance, "selling %v needs %v of the base asset, have %d", quantity, need, base)
+		}
+		return nil
+	}
+	if need := math.Ceil(price * quantity); float64(quote) < need {
+		return reject(RejectInsufficientBalance, "buying %v at up to %v needs %v of the quote asset, have %d", quantity, price, need, quote)
+	}
+	return nil
+}
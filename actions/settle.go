@@ -0,0 +1,147 @@
+// CLOB/actions/settle.go
+
+package actions
+
+import (
+	"context"
+
+	"CLOB/auth"
+	"CLOB/genesis"
+	"CLOB/storage"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+	"github.com/ava-labs/hypersdk/crypto"
+	"github.com/ava-labs/hypersdk/utils"
+)
+
+var _ chain.Action = (*Settle)(nil)
+
+// SettledAccount is what the engine did with the orders of one account
+type SettledAccount struct {
+	Owner  crypto.PublicKey `json:"owner"`
+	Closed []string         `json:"closed"` // IDs of the orders that were filled, cancelled, expired or refused
+	Trades uint64           `json:"trades"` // Trades the account took part in
+}
+
+// Settle reports what the matching engine did with the orders of Market in
+// the accepted block at Height, so chain state can follow: the reservations
+// of the orders it closed are released, and the trades of each account are
+// added to its order-to-trade counters in the window of Timestamp. The chain
+// cannot execute the engine itself, so only the operator named in genesis
+// may settle, and it is trusted to report the engine faithfully.
+//
+// An order ID is never reused, so releasing the reservation of a closed
+// order is final whenever the settlement lands; orders whose reservation is
+// already gone are skipped.
+type Settle struct {
+	Market    string           `json:"market"`
+	Height    uint64           `json:"height"`    // Block the engine executed the orders in
+	Timestamp int64            `json:"timestamp"` // Timestamp of that block
+	Accounts  []SettledAccount `json:"accounts"`
+}
+
+func (s *Settle) StateKeys(chain.Auth, ids.ID) [][]byte {
+	keys := [][]byte{storage.MarketKey(s.Market)}
+	for _, account := range s.Accounts {
+		keys = append(keys, reservationStateKeys(account.Owner, s.Market)...)
+	}
+	return keys
+}
+
+func (s *Settle) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	_ int64,
+	rauth chain.Auth,
+	_ ids.ID,
+) (*chain.Result, error) {
+	unitsUsed := s.MaxUnits(r)
+	rules, ok := r.(*genesis.Rules)
+	if !ok {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputNotOperator}, nil
+	}
+	if operator, ok := rules.GetOperator(); !ok || auth.GetActor(rauth) != operator {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputNotOperator}, nil
+	}
+	market, ok := storage.LookupMarket(s.Market)
+	if !ok {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputMarketUnknown}, nil
+	}
+	maxRatio, window, _ := rules.GetOrderToTradeLimit()
+
+	for _, account := range s.Accounts {
+		record, err := storage.GetAccountMarket(ctx, db, account.Owner, s.Market)
+		if err != nil {
+			return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+		}
+		for _, orderID := range account.Closed {
+			if _, _, err := releaseOrder(ctx, db, account.Owner, market, record, orderID, ""); err != nil {
+				return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+			}
+		}
+		// Like orders, trades are only counted while the throttle is enabled
+		if maxRatio > 0 && account.Trades > 0 {
+			record.Activity.RecordTrades(account.Trades, s.Timestamp, window)
+		}
+		if err := storage.SetAccountMarket(ctx, db, account.Owner, s.Market, record); err != nil {
+			return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+		}
+	}
+	return &chain.Result{Success: true, Units: unitsUsed}, nil
+}
+
+func (s *Settle) MaxUnits(chain.Rules) uint64 {
+	units := uint64(len(s.Market)) + consts.Uint64Len + consts.Int64Len
+	for _, account := range s.Accounts {
+		units += crypto.PublicKeyLen + consts.Uint64Len
+		for _, orderID := range account.Closed {
+			units += uint64(len(orderID))
+		}
+	}
+	return units
+}
+
+func (s *Settle) Marshal(p *codec.Packer) {
+	p.PackString(s.Market)
+	p.PackUint64(s.Height)
+	p.PackInt64(s.Timestamp)
+	p.PackInt(len(s.Accounts))
+	for _, account := range s.Accounts {
+		p.PackPublicKey(account.Owner)
+		p.PackInt(len(account.Closed))
+		for _, orderID := range account.Closed {
+			p.PackString(orderID)
+		}
+		p.PackUint64(account.Trades)
+	}
+}
+
+func UnmarshalSettle(p *codec.Packer, _ *codec.Message) (chain.Action, error) {
+	var settle Settle
+	settle.Market = p.UnpackString(true)
+	settle.Height = p.UnpackUint64(false)
+	settle.Timestamp = p.UnpackInt64(false)
+	count := p.UnpackInt(false)
+	// Entries are appended one by one so a forged count cannot force a large
+	// allocation; unpacking stops at the first error
+	for i := 0; i < count && p.Err() == nil; i++ {
+		var account SettledAccount
+		p.UnpackPublicKey(true, &account.Owner)
+		closed := p.UnpackInt(false)
+		for j := 0; j < closed && p.Err() == nil; j++ {
+			account.Closed = append(account.Closed, p.UnpackString(true))
+		}
+		account.Trades = p.UnpackUint64(false)
+		settle.Accounts = append(settle.Accounts, account)
+	}
+	return &settle, p.Err()
+}
+
+func (*Settle) ValidRange(chain.Rules) (int64, int64) {
+	// Returning -1, -1 means that the action is always valid.
+	return -1, -1
+}
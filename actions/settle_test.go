@@ -0,0 +1,185 @@
+// CLOB/actions/settle_test.go
+
+package actions
+
+import (
+	"context"
+	"testing"
+
+	"CLOB/storage"
+
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+func TestSettleReleasesClosedOrders(t *testing.T) {
+	ctx := context.Background()
+	db := testDB{}
+	base := ids.GenerateTestID()
+	operator, trader := testKey(t), testKey(t)
+	rules := testRules(t, base, operator.PublicKey(), nil)
+	owner := trader.PublicKey()
+	if err := storage.SetBalance(ctx, db, owner, storage.NativeAsset, 1_000); err != nil {
+		t.Fatal(err)
+	}
+
+	buy := &AddOrder{Market: testMarket, Side: "buy", Price: 10, Quantity: 3, OrderType: "limit"}
+	buyTx, otherTx := ids.GenerateTestID(), ids.GenerateTestID()
+	mustSucceed(t, execute(t, db, rules, buy, owner, buyTx))
+	mustSucceed(t, execute(t, db, rules, buy, owner, otherTx))
+	if got := reserved(t, db, owner, storage.NativeAsset); got != 60 {
+		t.Fatalf("reserved %d, want 60", got)
+	}
+
+	settle := &Settle{
+		Market: testMarket,
+		Height: 1,
+		Accounts: []SettledAccount{{
+			Owner:  owner,
+			Closed: []string{storage.NewOrderID(buyTx, 0), "unknown"},
+		}},
+	}
+
+	// Only the operator may settle
+	mustFail(t, execute(t, db, rules, settle, owner, ids.GenerateTestID()), OutputNotOperator)
+	if got := reserved(t, db, owner, storage.NativeAsset); got != 60 {
+		t.Fatalf("reserved %d after a refused settlement, want 60", got)
+	}
+
+	mustSucceed(t, execute(t, db, rules, settle, operator.PublicKey(), ids.GenerateTestID()))
+	if got := reserved(t, db, owner, storage.NativeAsset); got != 30 {
+		t.Fatalf("reserved %d after settling, want 30", got)
+	}
+	record, err := storage.GetAccountMarket(ctx, db, owner, testMarket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(record.Reservations) != 1 || record.Reservations[0].OrderID != storage.NewOrderID(otherTx, 0) {
+		t.Fatalf("reservations %+v, want only the open order", record.Reservations)
+	}
+
+	// Settling again releases nothing more
+	mustSucceed(t, execute(t, db, rules, settle, operator.PublicKey(), ids.GenerateTestID()))
+	if got := reserved(t, db, owner, storage.NativeAsset); got != 30 {
+		t.Fatalf("reserved %d after settling twice, want 30", got)
+	}
+}
+
+func TestSettleFreesOpenOrderSlots(t *testing.T) {
+	ctx := context.Background()
+	db := testDB{}
+	operator, trader := testKey(t), testKey(t)
+	rules := testRules(t, ids.GenerateTestID(), operator.PublicKey(), map[string]any{"max_open_orders": 1})
+	owner := trader.PublicKey()
+	if err := storage.SetBalance(ctx, db, owner, storage.NativeAsset, 1_000); err != nil {
+		t.Fatal(err)
+	}
+
+	buy := &AddOrder{Market: testMarket, Side: "buy", Price: 1, Quantity: 1, OrderType: "limit"}
+	txID := ids.GenerateTestID()
+	mustSucceed(t, execute(t, db, rules, buy, owner, txID))
+	mustFail(t, execute(t, db, rules, buy, owner, ids.GenerateTestID()), RejectTooManyOpenOrders.Output())
+
+	settle := &Settle{
+		Market:   testMarket,
+		Accounts: []SettledAccount{{Owner: owner, Closed: []string{storage.NewOrderID(txID, 0)}}},
+	}
+	mustSucceed(t, execute(t, db, rules, settle, operator.PublicKey(), ids.GenerateTestID()))
+	mustSucceed(t, execute(t, db, rules, buy, owner, ids.GenerateTestID()))
+}
+
+func TestSettleCountsTrades(t *testing.T) {
+	ctx := context.Background()
+	db := testDB{}
+	operator, trader := testKey(t), testKey(t)
+	rules := testRules(t, ids.GenerateTestID(), operator.PublicKey(), map[string]any{
+		"max_order_to_trade_ratio":  1,
+		"order_to_trade_window":     10,
+		"order_to_trade_min_orders": 2,
+	})
+	owner := trader.PublicKey()
+	if err := storage.SetBalance(ctx, db, owner, storage.NativeAsset, 1_000); err != nil {
+		t.Fatal(err)
+	}
+
+	buy := &AddOrder{Market: testMarket, Side: "buy", Price: 1, Quantity: 1, OrderType: "limit"}
+	mustSucceed(t, execute(t, db, rules, buy, owner, ids.GenerateTestID()))
+	mustSucceed(t, execute(t, db, rules, buy, owner, ids.GenerateTestID()))
+	mustFail(t, execute(t, db, rules, buy, owner, ids.GenerateTestID()), RejectThrottled.Output())
+
+	// Trades the operator settles count against the orders placed
+	settle := &Settle{Market: testMarket, Accounts: []SettledAccount{{Owner: owner, Trades: 2}}}
+	mustSucceed(t, execute(t, db, rules, settle, operator.PublicKey(), ids.GenerateTestID()))
+	record, err := storage.GetAccountMarket(ctx, db, owner, testMarket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Activity.Orders != 2 || record.Activity.Trades != 2 {
+		t.Fatalf("activity %+v, want 2 orders and 2 trades", record.Activity)
+	}
+	mustSucceed(t, execute(t, db, rules, buy, owner, ids.GenerateTestID()))
+}
+
+func TestAddOrderUnknownMarket(t *testing.T) {
+	db := testDB{}
+	trader := testKey(t)
+	rules := testRules(t, ids.GenerateTestID(), testKey(t).PublicKey(), nil)
+
+	add := &AddOrder{Market: "missing", Side: "buy", Price: 1, Quantity: 1, OrderType: "limit"}
+	mustFail(t, execute(t, db, rules, add, trader.PublicKey(), ids.GenerateTestID()), RejectUnknownMarket.Output())
+}
+
+func TestMarketBuyCostBound(t *testing.T) {
+	ctx := context.Background()
+	db := testDB{}
+	trader := testKey(t)
+	rules := testRules(t, ids.GenerateTestID(), testKey(t).PublicKey(), nil)
+	owner := trader.PublicKey()
+	if err := storage.SetBalance(ctx, db, owner, storage.NativeAsset, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	// A market buy must say the most it pays
+	unbounded := &AddOrder{Market: testMarket, Side: "buy", Quantity: 5, OrderType: "market"}
+	mustFail(t, execute(t, db, rules, unbounded, owner, ids.GenerateTestID()), OutputInvalidPrice)
+
+	// and be able to pay for its quantity at that price
+	expensive := &AddOrder{Market: testMarket, Side: "buy", Price: 21, Quantity: 5, OrderType: "market"}
+	mustFail(t, execute(t, db, rules, expensive, owner, ids.GenerateTestID()), RejectInsufficientBalance.Output())
+
+	// It reserves nothing, as it never rests
+	bounded := &AddOrder{Market: testMarket, Side: "buy", Price: 20, Quantity: 5, OrderType: "market"}
+	mustSucceed(t, execute(t, db, rules, bounded, owner, ids.GenerateTestID()))
+	if got := reserved(t, db, owner, storage.NativeAsset); got != 0 {
+		t.Fatalf("reserved %d, want 0", got)
+	}
+}
+
+func TestMatchMarketOrderStopsAtPrice(t *testing.T) {
+	book := storage.NewOrderBook()
+	book.Market = testMarket
+	for i, price := range []float64{10, 11, 12} {
+		ask := &storage.Order{
+			ID:        ids.GenerateTestID().String(),
+			Market:    testMarket,
+			Side:      storage.Sell,
+			Price:     price,
+			Quantity:  1,
+			OrderType: storage.Limit,
+		}
+		ask.Timestamp = ask.Timestamp.Add(1 << i)
+		if err := book.AddLimitOrder(ask); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buy := &storage.Order{ID: "buy", Market: testMarket, Side: storage.Buy, Price: 11, Quantity: 3, OrderType: storage.Market}
+	if err := MatchMarketOrder(book, buy); err == nil {
+		t.Fatal("matched a market buy beyond its price")
+	}
+	if buy.FilledQuantity != 2 || buy.FilledValue != 21 {
+		t.Fatalf("filled %v for %v, want 2 for 21", buy.FilledQuantity, buy.FilledValue)
+	}
+	if asks := book.GetSide(storage.Sell); asks.Prices.Len() != 1 {
+		t.Fatalf("%d ask levels left, want 1", asks.Prices.Len())
+	}
+}
@@ -0,0 +1,90 @@
+// CLOB/actions/transfer.go
+
+package actions
+
+import (
+	"context"
+
+	"CLOB/auth"
+	"CLOB/storage"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/consts"
+	"github.com/ava-labs/hypersdk/crypto"
+	"github.com/ava-labs/hypersdk/utils"
+)
+
+var _ chain.Action = (*TransferAction)(nil)
+
+// TransferAction moves [Value] units of [Asset] from the signer to [To].
+// The native asset is transferred with storage.NativeAsset.
+type TransferAction struct {
+	To    crypto.PublicKey `json:"to"`
+	Asset ids.ID           `json:"asset"`
+	Value uint64           `json:"value"`
+}
+
+func (t *TransferAction) StateKeys(rauth chain.Auth, _ ids.ID) [][]byte {
+	actor := auth.GetActor(rauth)
+	return [][]byte{
+		storage.BalanceKey(actor, t.Asset),
+		storage.ReservedKey(actor, t.Asset),
+		storage.BalanceKey(t.To, t.Asset),
+	}
+}
+
+func (t *TransferAction) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	_ int64,
+	rauth chain.Auth,
+	_ ids.ID,
+) (*chain.Result, error) {
+	actor := auth.GetActor(rauth)
+	unitsUsed := t.MaxUnits(r)
+
+	if t.Value == 0 {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputValueZero}, nil
+	}
+	// Balance reserved by resting orders cannot leave the account
+	available, err := storage.GetAvailableBalance(ctx, db, actor, t.Asset)
+	if err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	if available < t.Value {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: OutputBalanceUnavailable}, nil
+	}
+	if err := storage.SubtractBalance(ctx, db, actor, t.Asset, t.Value); err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	if err := storage.AddBalance(ctx, db, t.To, t.Asset, t.Value); err != nil {
+		return &chain.Result{Success: false, Units: unitsUsed, Output: utils.ErrBytes(err)}, nil
+	}
+	return &chain.Result{Success: true, Units: unitsUsed}, nil
+}
+
+func (*TransferAction) MaxUnits(chain.Rules) uint64 {
+	return crypto.PublicKeyLen + consts.IDLen + consts.Uint64Len
+}
+
+func (t *TransferAction) Marshal(p *codec.Packer) {
+	p.PackPublicKey(t.To)
+	p.PackID(t.Asset)
+	p.PackUint64(t.Value)
+}
+
+func UnmarshalTransfer(p *codec.Packer, _ *codec.Message) (chain.Action, error) {
+	var transfer TransferAction
+	p.UnpackPublicKey(false, &transfer.To) // Can transfer to blackhole
+	p.UnpackID(false, &transfer.Asset)     // Empty ID is the native asset
+	transfer.Value = p.UnpackUint64(true)
+	return &transfer, p.Err()
+}
+
+func (*TransferAction) ValidRange(chain.Rules) (int64, int64) {
+	// Returning -1, -1 means that the action is always valid.
+	return -1, -1
+}
@@ -29,7 +29,7 @@ func (*ED25519) ValidRange(chain.Rules) (int64, int64) {
 
 func (d *ED25519) StateKeys() [][]byte {
 	return [][]byte{
-		storage.BalanceKey(d.Signer),
+		storage.BalanceKey(d.Signer, storage.NativeAsset),
 	}
 }
 
@@ -70,7 +70,7 @@ func (d *ED25519) CanDeduct(
 	db chain.Database,
 	amount uint64,
 ) error {
-	bal, err := storage.GetBalance(ctx, db, d.Signer)
+	bal, err := storage.GetBalance(ctx, db, d.Signer, storage.NativeAsset)
 	if err != nil {
 		return err
 	}
@@ -85,7 +85,7 @@ func (d *ED25519) Deduct(
 	db chain.Database,
 	amount uint64,
 ) error {
-	return storage.SubtractBalance(ctx, db, d.Signer, amount)
+	return storage.SubtractBalance(ctx, db, d.Signer, storage.NativeAsset, amount)
 }
 
 func (d *ED25519) Refund(
@@ -93,7 +93,7 @@ func (d *ED25519) Refund(
 	db chain.Database,
 	amount uint64,
 ) error {
-	return storage.AddBalance(ctx, db, d.Signer, amount)
+	return storage.AddBalance(ctx, db, d.Signer, storage.NativeAsset, amount)
 }
 
 var _ chain.AuthFactory = (*ED25519Factory)(nil)
@@ -110,4 +110,3 @@ func (d *ED25519Factory) Sign(msg []byte, _ chain.Action) (chain.Auth, error) {
 	sig := crypto.Sign(msg, d.priv)
 	return &ED25519{d.priv.PublicKey(), sig}, nil
 }
-
@@ -3,6 +3,7 @@
 package auth
 
 import (
+	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/hypersdk/chain"
 	"github.com/ava-labs/hypersdk/crypto"
 )
@@ -24,3 +25,12 @@ func GetSigner(auth chain.Auth) crypto.PublicKey {
 		return crypto.EmptyPublicKey
 	}
 }
+
+// StreamAuthMessage returns the message an account signs to authenticate a
+// stream connection to chain [chainID] that was challenged with [nonce].
+// Binding the chain and the single-use nonce of the connection keeps a
+// signature from being replayed elsewhere or on another connection.
+func StreamAuthMessage(chainID ids.ID, nonce []byte) []byte {
+	msg := append([]byte("stream-auth:"), chainID[:]...)
+	return append(append(msg, ':'), nonce...)
+}
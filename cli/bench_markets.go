@@ -0,0 +1,184 @@
+// CLOB/cli/bench_markets.go
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"CLOB/actions"
+	"CLOB/genesis"
+	"CLOB/storage"
+	"CLOB/vm"
+
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+// benchMarkets implements the `bench-markets` subcommand. It executes the
+// same multi-market block serially and with the given parallelism, checks
+// that both produce identical books and results, and reports the speedup.
+// It returns the process exit code.
+func benchMarkets(args []string) int {
+	fs := flag.NewFlagSet("bench-markets", flag.ExitOnError)
+	numMarkets := fs.Int("markets", 16, "Number of markets in the workload")
+	ordersPerMarket := fs.Int("orders", 2000, "Number of orders per market")
+	parallelism := fs.Int("parallelism", runtime.NumCPU(), "Markets executed concurrently")
+	rounds := fs.Int("rounds", 5, "Number of times each configuration is run")
+	fs.Parse(args)
+
+	g, err := benchGenesis(*numMarkets, *numMarkets**ordersPerMarket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to build genesis: %v\n", err)
+		return 1
+	}
+	txs := benchWorkload(*numMarkets, *ordersPerMarket)
+
+	serial, serialView, err := benchRun(g, txs, 1, *rounds)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Serial run failed: %v\n", err)
+		return 1
+	}
+	parallel, parallelView, err := benchRun(g, txs, *parallelism, *rounds)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Parallel run failed: %v\n", err)
+		return 1
+	}
+	if err := compareViews(serialView, parallelView); err != nil {
+		fmt.Fprintf(os.Stderr, "Parallel execution diverged from serial execution: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("markets=%d orders/market=%d txs=%d rounds=%d\n", *numMarkets, *ordersPerMarket, len(txs), *rounds)
+	fmt.Printf("serial:             %v/block\n", serial)
+	fmt.Printf("parallel (%2d):      %v/block\n", *parallelism, parallel)
+	fmt.Printf("speedup:            %.2fx (results identical)\n", float64(serial)/float64(parallel))
+	return 0
+}
+
+// benchGenesis returns a genesis with [numMarkets] empty markets
+func benchGenesis(numMarkets int, maxBlockTxs int) (*genesis.Genesis, error) {
+	g := genesis.Default()
+	g.MaxBlockTxs = maxBlockTxs
+	g.InitialOrders = nil
+	g.Markets = make([]genesis.CustomMarket, numMarkets)
+	for i := range g.Markets {
+		g.Markets[i] = genesis.CustomMarket{ID: benchMarket(i)}
+	}
+	b, err := json.Marshal(g)
+	if err != nil {
+		return nil, err
+	}
+	return genesis.New(b)
+}
+
+func benchMarket(i int) string {
+	return fmt.Sprintf("bench-%d", i)
+}
+
+// benchWorkload builds a block interleaving orders across markets. Orders
+// alternate sides around a common mid price so that a large share of them
+// match and cross several levels.
+func benchWorkload(numMarkets int, ordersPerMarket int) []vm.BlockTx {
+	txs := make([]vm.BlockTx, 0, numMarkets*ordersPerMarket)
+	ts := time.Unix(0, 0).UTC()
+	for n := 0; n < ordersPerMarket; n++ {
+		for m := 0; m < numMarkets; m++ {
+			side, price := storage.Buy, 100-float64(n%7)
+			if n%2 == 1 {
+				side, price = storage.Sell, 97+float64(n%5)
+			}
+			order := &storage.Order{
+				ID:        fmt.Sprintf("%d-%d", m, n),
+				Market:    benchMarket(m),
+				Side:      side,
+				Price:     price,
+				Quantity:  float64(1 + n%10),
+				Timestamp: ts,
+				OrderType: storage.Limit,
+			}
+			txs = append(txs, vm.BlockTx{
+				TxID:   ids.GenerateTestID(),
+				Action: &actions.AddOrderAction{Order: order},
+			})
+		}
+	}
+	return txs
+}
+
+// benchRun verifies the workload [rounds] times on fresh engines and returns
+// the average time per block along with the last resulting view
+func benchRun(g *genesis.Genesis, txs []vm.BlockTx, parallelism int, rounds int) (time.Duration, *vm.BlockView, error) {
+	var (
+		total time.Duration
+		view  *vm.BlockView
+	)
+	for r := 0; r < rounds; r++ {
+		engine, err := vm.NewMatchingEngineVMFromGenesis(g)
+		if err != nil {
+			return 0, nil, err
+		}
+		engine.SetParallelism(parallelism)
+
+		// Actions mutate their orders, so every round gets fresh copies
+		blk := vm.BlockInfo{ID: ids.GenerateTestID(), Parent: ids.Empty, Height: 1}
+		start := time.Now()
+		view, err = engine.VerifyBlock(blk, cloneTxs(txs))
+		if err != nil {
+			return 0, nil, err
+		}
+		total += time.Since(start)
+	}
+	return total / time.Duration(rounds), view, nil
+}
+
+func cloneTxs(txs []vm.BlockTx) []vm.BlockTx {
+	cloned := make([]vm.BlockTx, len(txs))
+	for i, tx := range txs {
+		order := *tx.Action.(*actions.AddOrderAction).Order
+		cloned[i] = vm.BlockTx{TxID: tx.TxID, Action: &actions.AddOrderAction{Order: &order}}
+	}
+	return cloned
+}
+
+// compareViews checks that two executions of the same block produced the
+// same results and the same books
+func compareViews(a, b *vm.BlockView) error {
+	for i := range a.Results {
+		if fmt.Sprint(a.Results[i]) != fmt.Sprint(b.Results[i]) {
+			return fmt.Errorf("tx %d: result %v != %v", i, a.Results[i], b.Results[i])
+		}
+	}
+	for market, bookA := range a.Books {
+		snapA := bookA.Snapshot(a.ID, a.Height, a.Timestamp)
+		snapB := b.Books[market].Snapshot(a.ID, a.Height, a.Timestamp)
+		if err := compareLevels(snapA.Bids, snapB.Bids); err != nil {
+			return fmt.Errorf("market %s bids: %w", market, err)
+		}
+		if err := compareLevels(snapA.Asks, snapB.Asks); err != nil {
+			return fmt.Errorf("market %s asks: %w", market, err)
+		}
+	}
+	return nil
+}
+
+func compareLevels(a, b []storage.LevelSnapshot) error {
+	if len(a) != len(b) {
+		return fmt.Errorf("%d levels != %d levels", len(a), len(b))
+	}
+	for i := range a {
+		if a[i].Price != b[i].Price || len(a[i].Orders) != len(b[i].Orders) {
+			return fmt.Errorf("level %d differs", i)
+		}
+		for j := range a[i].Orders {
+			oa, ob := a[i].Orders[j], b[i].Orders[j]
+			if oa.ID != ob.ID || oa.Quantity != ob.Quantity {
+				return fmt.Errorf("level %d order %d: %s/%v != %s/%v", i, j, oa.ID, oa.Quantity, ob.ID, ob.Quantity)
+			}
+		}
+	}
+	return nil
+}
@@ -15,9 +15,21 @@ import (
 	"CLOB/genesis"
 	"CLOB/storage"
 	"CLOB/vm"
+
+	"github.com/ava-labs/avalanchego/ids"
 )
 
 func main() {
+	// Dispatch subcommands before parsing the default flags
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate-genesis":
+			os.Exit(validateGenesis(os.Args[2:]))
+		case "bench-markets":
+			os.Exit(benchMarkets(os.Args[2:]))
+		}
+	}
+
 	// Define a flag for the genesis configuration file
 	genesisFile := flag.String("genesis", "", "Path to genesis JSON configuration file")
 	flag.Parse()
@@ -50,6 +62,7 @@ func main() {
 	// Example: Adding a new order via CLI (can be extended to accept user inputs)
 	buyOrder := &storage.Order{
 		ID:        "cli_buy_1",
+		Market:    genesis.DefaultMarket,
 		Side:      storage.Buy,
 		Price:     102.0,
 		Quantity:  20,
@@ -57,8 +70,10 @@ func main() {
 		OrderType: storage.Limit,
 	}
 
+	// Orders only reach the books through accepted blocks, so the order is
+	// placed in a block of its own on top of genesis
 	addBuyOrderAction := &actions.AddOrderAction{Order: buyOrder}
-	if err := engine.ExecuteAction(addBuyOrderAction); err != nil {
+	if err := acceptAction(engine, addBuyOrderAction); err != nil {
 		fmt.Printf("Error adding buy order: %v\n", err)
 	} else {
 		fmt.Println("Buy order added successfully!")
@@ -69,12 +84,34 @@ func main() {
 
 	// Example: Display remaining orders
 	fmt.Println("Current Orders in the Order Book:")
-	for id, order := range engine.OrderBook.OrderMap {
-		fmt.Printf("Order ID: %s, Side: %s, Quantity: %.2f, Price: %.2f, Type: %s\n",
-			id, order.Side, order.Quantity, order.Price, order.OrderType)
+	for market, book := range engine.Books {
+		for id, order := range book.OrderMap {
+			fmt.Printf("Market: %s, Order ID: %s, Side: %s, Quantity: %.2f, Price: %.2f, Type: %s\n",
+				market, id, order.Side, order.Quantity, order.Price, order.OrderType)
+		}
 	}
 
 	// Prevent the CLI from exiting immediately (for demonstration)
 	// In a real application, you'd implement a loop to accept user commands
 	os.Exit(0)
 }
+
+// acceptAction executes [action] in a new block on top of the last accepted
+// one and accepts it
+func acceptAction(engine *vm.MatchingEngineVM, action actions.Action) error {
+	state := engine.State()
+	blk := vm.BlockInfo{
+		ID:        ids.GenerateTestID(),
+		Parent:    state.BlockID,
+		Height:    state.Height + 1,
+		Timestamp: time.Now().Unix(),
+	}
+	view, err := engine.VerifyBlock(blk, []vm.BlockTx{{TxID: ids.GenerateTestID(), Action: action}})
+	if err != nil {
+		return err
+	}
+	if _, err := engine.AcceptBlock(blk.ID, nil); err != nil {
+		return err
+	}
+	return view.Results[0]
+}
@@ -0,0 +1,59 @@
+// CLOB/cli/validate_genesis.go
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"CLOB/genesis"
+)
+
+// validateGenesis implements the `validate-genesis` subcommand. It runs the
+// same validation pass as genesis.New and prints every problem found, one per
+// line, with the JSON path of the offending field.
+// It returns the process exit code.
+func validateGenesis(args []string) int {
+	fs := flag.NewFlagSet("validate-genesis", flag.ExitOnError)
+	genesisFile := fs.String("genesis", "", "Path to genesis JSON configuration file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: cli validate-genesis [-genesis] <file>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	// Allow the file to be passed positionally as well
+	path := *genesisFile
+	if path == "" && fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	if path == "" {
+		fs.Usage()
+		return 2
+	}
+
+	genesisConfig, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read genesis file: %v\n", err)
+		return 1
+	}
+
+	if _, err := genesis.New(genesisConfig); err != nil {
+		problems := genesis.AsValidationErrors(err)
+		if problems == nil {
+			// Not a validation problem (e.g. malformed JSON)
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "%s: found %d problem(s)\n", path, len(problems))
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "  %s\n", p.Error())
+		}
+		return 1
+	}
+
+	fmt.Printf("%s: genesis is valid\n", path)
+	return 0
+}
@@ -10,27 +10,28 @@ import (
 )
 
 const (
-	HRP="ClbVM"
+	HRP  = "ClbVM"
 	Name = "CLOB-vm"
+
+	// Native asset, created with the allocations of genesis
+	Symbol   = "CLB"
+	Decimals = 9
 )
 
 var ID ids.ID
 
 func init() {
 	b := make([]byte, ids.IDLen) // Create a byte slice of length `ids.IDLen`.
-	copy(b, []byte(Name))       // Copy the VM name into the byte slice.
-	vmID, err := ids.ToID(b)    // Convert the byte slice to an `ids.ID`.
+	copy(b, []byte(Name))        // Copy the VM name into the byte slice.
+	vmID, err := ids.ToID(b)     // Convert the byte slice to an `ids.ID`.
 	if err != nil {
-		panic(err)             // If an error occurs, panic to signal a critical issue.
+		panic(err) // If an error occurs, panic to signal a critical issue.
 	}
-	ID = vmID                   // Assign the computed `ids.ID` to the `ID` variable.
+	ID = vmID // Assign the computed `ids.ID` to the `ID` variable.
 }
 
-
 var Version = &version.Semantic{
 	Major: 0,
 	Minor: 0,
 	Patch: 1,
 }
-
-
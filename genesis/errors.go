@@ -13,4 +13,7 @@ var (
 
 	// ErrDuplicateInitialOrder is returned when an initial order ID is duplicated
 	ErrDuplicateInitialOrder = errors.New("duplicate initial order ID found")
+
+	// ErrCrossedInitialBook is returned when the initial bids cross the initial asks
+	ErrCrossedInitialBook = errors.New("initial order book is crossed")
 )
@@ -8,43 +8,120 @@ import (
 	"fmt"
 	"time"
 
+	"CLOB/consts"
 	"CLOB/storage"
 	"CLOB/utils"
 
-	"github.com/rafael-abuawad/tokenvm/consts" // Adjust the import path as needed
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/trace"
+	smath "github.com/ava-labs/avalanchego/utils/math"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/crypto"
+	"github.com/ava-labs/hypersdk/vm"
 )
 
-// Ensure Genesis implements any required interfaces (if applicable)
-// var _ SomeInterface = (*Genesis)(nil)
+// Ensure Genesis implements the interface the VM seeds state with
+var _ vm.Genesis = (*Genesis)(nil)
+
+// DefaultMarket is the ID of the market created by the default genesis
+const DefaultMarket = "default"
+
+// CustomMarket defines a market: an order book trading BaseAsset against QuoteAsset
+type CustomMarket struct {
+	ID         string `json:"id"`
+	BaseAsset  string `json:"base_asset"`  // Asset being traded (empty means the native asset)
+	QuoteAsset string `json:"quote_asset"` // Asset prices are quoted in (empty means the native asset)
+
+	// Pre-trade limits on the orders of the market. 0 means unbounded.
+	MinQuantity float64 `json:"min_quantity,omitempty"`
+	MaxQuantity float64 `json:"max_quantity,omitempty"`
+	MinPrice    float64 `json:"min_price,omitempty"` // Price band of limit orders
+	MaxPrice    float64 `json:"max_price,omitempty"`
+}
 
 // CustomInitialOrder represents an initial order to be loaded into the order book
 type CustomInitialOrder struct {
 	ID        string  `json:"id"`
-	Side      string  `json:"side"`      // "buy" or "sell"
-	Price     float64 `json:"price"`     // 0 for market orders
+	Market    string  `json:"market"` // ID of the market the order rests in
+	Side      string  `json:"side"`   // "buy" or "sell"
+	Price     float64 `json:"price"`  // 0 for market orders
 	Quantity  float64 `json:"quantity"`
-	Timestamp string  `json:"timestamp"` // ISO8601 format
+	Timestamp string  `json:"timestamp"`  // ISO8601 format
 	OrderType string  `json:"order_type"` // "limit" or "market"
 }
 
+// CustomAllocation assigns an initial native asset balance to an address
+type CustomAllocation struct {
+	Address string `json:"address"`
+	Balance uint64 `json:"balance"`
+}
+
 // Genesis defines the structure for initializing the order book
 type Genesis struct {
-	// Configuration Parameters
-	MaxBlockTxs   int `json:"max_block_txs"`
-	MaxBlockUnits int `json:"max_block_units"`
+	// Address Prefix
+	HRP string `json:"hrp"`
+
+	// Operator is the address that settles what the matching engine did with
+	// orders into chain state (see actions.Settle). Empty disables
+	// settlement, so reservations are only released by their owners.
+	Operator string `json:"operator,omitempty"`
+
+	// Configuration Parameters and Markets (active from genesis until the
+	// first upgrade)
+	Params
+
+	// Scheduled Rule Changes
+	Upgrades []Upgrade `json:"upgrades,omitempty"`
+
+	// Initial Balances
+	Allocations []CustomAllocation `json:"allocations"`
 
 	// Initial Orders
 	InitialOrders []CustomInitialOrder `json:"initial_orders"`
+
+	schedule []*scheduledParams // Params active at each upgrade, sorted by timestamp
 }
 
 // Default returns a Genesis instance with default configurations
 func Default() *Genesis {
 	return &Genesis{
-		MaxBlockTxs:   1000,
-		MaxBlockUnits: 1000000,
+		HRP: consts.HRP,
+		Params: Params{
+			// Block Limits
+			MaxBlockTxs:   1000,
+			MaxBlockUnits: 1_000_000,
+
+			// Tx Parameters
+			BaseUnits:      48,
+			ValidityWindow: 60,
+
+			// Unit Pricing
+			MinUnitPrice:               1,
+			UnitPriceChangeDenominator: 48,
+			WindowTargetUnits:          20_000_000,
+
+			// Block Pricing
+			MinBlockCost:               0,
+			BlockCostChangeDenominator: 48,
+			WindowTargetBlocks:         20,
+
+			// Order Limits
+			MaxBatchInstructions:     64,
+			MaxExpiredOrdersPerBlock: 1000,
+			MaxOpenOrders:            1000,
+			MaxOrderToTradeRatio:     100,
+			OrderToTradeWindow:       3600,
+			OrderToTradeMinOrders:    1000,
+
+			// Markets
+			Markets: []CustomMarket{
+				{ID: DefaultMarket},
+			},
+		},
 		InitialOrders: []CustomInitialOrder{
 			{
 				ID:        "init_buy_1",
+				Market:    DefaultMarket,
 				Side:      "buy",
 				Price:     100.0,
 				Quantity:  50,
@@ -53,6 +130,7 @@ func Default() *Genesis {
 			},
 			{
 				ID:        "init_sell_1",
+				Market:    DefaultMarket,
 				Side:      "sell",
 				Price:     101.0,
 				Quantity:  50,
@@ -73,53 +151,53 @@ func New(configBytes []byte) (*Genesis, error) {
 		}
 	}
 
-	// Validate Genesis Configuration
-	if genesis.MaxBlockTxs <= 0 {
-		return nil, fmt.Errorf("%w: MaxBlockTxs must be positive", ErrInvalidGenesisConfig)
-	}
-	if genesis.MaxBlockUnits <= 0 {
-		return nil, fmt.Errorf("%w: MaxBlockUnits must be positive", ErrInvalidGenesisConfig)
-	}
-
-	// Validate Initial Orders
-	orderIDs := make(map[string]struct{})
-	for _, order := range genesis.InitialOrders {
-		// Validate order side
-		if order.Side != "buy" && order.Side != "sell" {
-			return nil, fmt.Errorf("%w: invalid side '%s' for order ID '%s'", ErrInvalidGenesisConfig, order.Side, order.ID)
-		}
+	return NewFromGenesis(genesis)
+}
 
-		// Validate order type
-		if order.OrderType != "limit" && order.OrderType != "market" {
-			return nil, fmt.Errorf("%w: invalid order type '%s' for order ID '%s'", ErrInvalidGenesisConfig, order.OrderType, order.ID)
-		}
+// NewFromGenesis validates [g] and resolves its upgrade schedule so Rules(t)
+// returns the parameters active at t. A Genesis decoded straight from JSON,
+// such as the one a node serves, has no schedule until it goes through here.
+func NewFromGenesis(g *Genesis) (*Genesis, error) {
+	// Validate Genesis Configuration, reporting every problem at once
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
 
-		// Check for duplicate order IDs
-		if _, exists := orderIDs[order.ID]; exists {
-			return nil, fmt.Errorf("%w: duplicate order ID '%s'", ErrDuplicateInitialOrder, order.ID)
-		}
-		orderIDs[order.ID] = struct{}{}
+	// Resolve the upgrade schedule so Rules(t) is a lookup
+	if err := g.buildSchedule(); err != nil {
+		return nil, err
 	}
 
-	return genesis, nil
+	return g, nil
 }
 
-// Load initializes the order book with the genesis configuration
-func (g *Genesis) Load(ctx context.Context, db storage.Database) error {
-	// Set up any required configurations in the storage layer
-	// For example, setting block parameters if applicable
-	// This depends on how your storage layer utilizes these parameters
+// LoadOrderBooks creates the order book of every market and fills it with
+// the initial orders of the genesis configuration
+func (g *Genesis) LoadOrderBooks() (map[string]*storage.OrderBook, error) {
+	markets, err := g.GetMarkets()
+	if err != nil {
+		return nil, err
+	}
+	books := make(map[string]*storage.OrderBook, len(markets))
+	for _, market := range markets {
+		books[market.ID] = storage.NewMarketOrderBook(market)
+	}
 
 	// Initialize initial orders
 	for _, order := range g.InitialOrders {
 		parsedTimestamp, err := time.Parse(time.RFC3339, order.Timestamp)
 		if err != nil {
-			return fmt.Errorf("invalid timestamp for order ID '%s': %w", order.ID, err)
+			return nil, fmt.Errorf("invalid timestamp for order ID '%s': %w", order.ID, err)
+		}
+		book, ok := books[order.Market]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown market '%s' for order ID '%s'", ErrInitialOrderSetupFailed, order.Market, order.ID)
 		}
 
 		// Create Order struct
 		storageOrder := &storage.Order{
 			ID:        order.ID,
+			Market:    order.Market,
 			Side:      storage.Side(order.Side),
 			Price:     order.Price,
 			Quantity:  order.Quantity,
@@ -128,10 +206,70 @@ func (g *Genesis) Load(ctx context.Context, db storage.Database) error {
 		}
 
 		// Add order to the order book
-		if err := db.AddOrder(ctx, storageOrder); err != nil {
-			return fmt.Errorf("failed to add initial order ID '%s': %w", order.ID, err)
+		if err := book.AddLimitOrder(storageOrder); err != nil {
+			return nil, fmt.Errorf("failed to add initial order ID '%s': %w", order.ID, err)
 		}
 	}
 
-	return nil
+	return books, nil
+}
+
+// GetHRP returns the Human-Readable Part for addresses
+func (g *Genesis) GetHRP() string {
+	return g.HRP
+}
+
+// Load seeds the genesis state: the allocations are credited in the native
+// asset, which is only ever minted here, and its metadata records the total
+// supply. Native balances pay the fees of every transaction.
+func (g *Genesis) Load(ctx context.Context, tracer trace.Tracer, db chain.Database) error {
+	ctx, span := tracer.Start(ctx, "Genesis.Load")
+	defer span.End()
+
+	var supply uint64
+	for _, alloc := range g.Allocations {
+		pk, err := utils.ParseAddress(alloc.Address)
+		if err != nil {
+			return fmt.Errorf("invalid allocation address '%s': %w", alloc.Address, err)
+		}
+		supply, err = smath.Add64(supply, alloc.Balance)
+		if err != nil {
+			return fmt.Errorf("native supply overflows at allocation for '%s': %w", alloc.Address, err)
+		}
+		if err := storage.SetBalance(ctx, db, pk, storage.NativeAsset, alloc.Balance); err != nil {
+			return fmt.Errorf("failed to set allocation for '%s': %w", alloc.Address, err)
+		}
+	}
+	return storage.SetAsset(ctx, db, storage.NativeAsset, &storage.Asset{
+		Symbol:   []byte(consts.Symbol),
+		Decimals: consts.Decimals,
+		Metadata: []byte(consts.Name),
+		Supply:   supply,
+		Owner:    crypto.EmptyPublicKey, // Nobody may mint more
+	})
+}
+
+// GetMarkets returns the markets defined in genesis, with parsed asset IDs
+func (g *Genesis) GetMarkets() ([]storage.MarketInfo, error) {
+	markets := make([]storage.MarketInfo, len(g.Markets))
+	for i, m := range g.Markets {
+		base, err := parseAsset(m.BaseAsset)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base asset of market '%s': %w", m.ID, err)
+		}
+		quote, err := parseAsset(m.QuoteAsset)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quote asset of market '%s': %w", m.ID, err)
+		}
+		markets[i] = storage.MarketInfo{ID: m.ID, BaseAsset: base, QuoteAsset: quote}
+	}
+	return markets, nil
+}
+
+// parseAsset parses an asset ID, treating the empty string as the native asset
+func parseAsset(s string) (ids.ID, error) {
+	if s == "" {
+		return storage.NativeAsset, nil
+	}
+	return ids.FromString(s)
 }
@@ -3,66 +3,137 @@
 package genesis
 
 import (
+	"CLOB/utils"
+
 	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/crypto"
 	// Import other necessary packages if needed
 )
 
 var _ chain.Rules = (*Rules)(nil)
 
 // Rules defines the interface for accessing genesis configuration parameters.
-// It wraps the Genesis struct and provides getter methods.
+// It wraps the Genesis struct and the Params active at a given timestamp,
+// and provides getter methods.
 type Rules struct {
 	g *Genesis
+	p *Params
 }
 
 // GetMaxBlockTxs returns the maximum number of transactions allowed per block.
 func (r *Rules) GetMaxBlockTxs() int {
-	return r.g.MaxBlockTxs
+	return r.p.MaxBlockTxs
 }
 
 // GetMaxBlockUnits returns the maximum number of units allowed per block.
 func (r *Rules) GetMaxBlockUnits() uint64 {
-	return r.g.MaxBlockUnits
+	return r.p.MaxBlockUnits
+}
+
+// GetTickSize returns the price increment limit orders must be placed on.
+// 0 means any price is allowed.
+func (r *Rules) GetTickSize() float64 {
+	return r.p.TickSize
+}
+
+// GetMaxBatchInstructions returns the most instructions a batch order
+// transaction may carry.
+func (r *Rules) GetMaxBatchInstructions() int {
+	return r.p.MaxBatchInstructions
+}
+
+// GetMaxExpiredOrdersPerBlock returns how many orders dead-man's switches may
+// expire in one block.
+func (r *Rules) GetMaxExpiredOrdersPerBlock() int {
+	return r.p.MaxExpiredOrdersPerBlock
+}
+
+// GetMaxOpenOrders returns the most orders one account may rest in a
+// market. 0 means unlimited.
+func (r *Rules) GetMaxOpenOrders() int {
+	return r.p.MaxOpenOrders
+}
+
+// GetMinNotional returns the smallest value of a limit order. 0 allows any
+// value.
+func (r *Rules) GetMinNotional() float64 {
+	return r.p.MinNotional
+}
+
+// GetOrderToTradeLimit returns the most orders per trade an account may
+// place, the length in seconds of the windows the ratio is measured over,
+// and how many orders an account may place before the ratio applies. A
+// ratio of 0 means there is no limit.
+func (r *Rules) GetOrderToTradeLimit() (float64, int64, uint64) {
+	return r.p.MaxOrderToTradeRatio, r.p.OrderToTradeWindow, r.p.OrderToTradeMinOrders
+}
+
+// GetMarket returns the configuration of a market as of the latest upgrade,
+// or false if it does not exist.
+func (r *Rules) GetMarket(id string) (*CustomMarket, bool) {
+	i := findMarket(r.p.Markets, id)
+	if i < 0 {
+		return nil, false
+	}
+	return &r.p.Markets[i], true
+}
+
+// GetOperator returns the account allowed to settle the outcome of the
+// matching engine, or false if there is none.
+func (r *Rules) GetOperator() (crypto.PublicKey, bool) {
+	if r.g.Operator == "" {
+		return crypto.EmptyPublicKey, false
+	}
+	pk, err := utils.ParseAddress(r.g.Operator) // Checked by Validate
+	if err != nil {
+		return crypto.EmptyPublicKey, false
+	}
+	return pk, true
+}
+
+// FeatureEnabled reports whether the named feature flag is active.
+func (r *Rules) FeatureEnabled(name string) bool {
+	return r.p.Features[name]
 }
 
 // GetBaseUnits returns the base units used in transactions.
 func (r *Rules) GetBaseUnits() uint64 {
-	return r.g.BaseUnits
+	return r.p.BaseUnits
 }
 
 // GetValidityWindow returns the validity window for transactions.
 func (r *Rules) GetValidityWindow() int64 {
-	return r.g.ValidityWindow
+	return r.p.ValidityWindow
 }
 
 // GetMinUnitPrice returns the minimum unit price for orders.
 func (r *Rules) GetMinUnitPrice() uint64 {
-	return r.g.MinUnitPrice
+	return r.p.MinUnitPrice
 }
 
 // GetUnitPriceChangeDenominator returns the denominator for unit price changes.
 func (r *Rules) GetUnitPriceChangeDenominator() uint64 {
-	return r.g.UnitPriceChangeDenominator
+	return r.p.UnitPriceChangeDenominator
 }
 
 // GetWindowTargetUnits returns the target units per window.
 func (r *Rules) GetWindowTargetUnits() uint64 {
-	return r.g.WindowTargetUnits
+	return r.p.WindowTargetUnits
 }
 
 // GetMinBlockCost returns the minimum cost per block.
 func (r *Rules) GetMinBlockCost() uint64 {
-	return r.g.MinBlockCost
+	return r.p.MinBlockCost
 }
 
 // GetBlockCostChangeDenominator returns the denominator for block cost changes.
 func (r *Rules) GetBlockCostChangeDenominator() uint64 {
-	return r.g.BlockCostChangeDenominator
+	return r.p.BlockCostChangeDenominator
 }
 
 // GetWindowTargetBlocks returns the target number of blocks per window.
 func (r *Rules) GetWindowTargetBlocks() uint64 {
-	return r.g.WindowTargetBlocks
+	return r.p.WindowTargetBlocks
 }
 
 // GetHRP returns the Human-Readable Part for addresses.
@@ -70,10 +141,30 @@ func (r *Rules) GetHRP() string {
 	return r.g.HRP
 }
 
-// FetchCustom allows fetching custom configuration parameters.
-// Modify this method based on your project's requirements.
+// Keys of the order limits exposed through FetchCustom
+const (
+	CustomMaxOpenOrders         = "max_open_orders"
+	CustomMinNotional           = "min_notional"
+	CustomMaxOrderToTradeRatio  = "max_order_to_trade_ratio"
+	CustomOrderToTradeWindow    = "order_to_trade_window"
+	CustomOrderToTradeMinOrders = "order_to_trade_min_orders"
+)
+
+// FetchCustom exposes the order limits to code that only holds a
+// chain.Rules, by the key of their JSON parameter.
 func (r *Rules) FetchCustom(key string) (any, bool) {
-	// Implement fetching of custom parameters if needed.
+	switch key {
+	case CustomMaxOpenOrders:
+		return r.p.MaxOpenOrders, true
+	case CustomMinNotional:
+		return r.p.MinNotional, true
+	case CustomMaxOrderToTradeRatio:
+		return r.p.MaxOrderToTradeRatio, true
+	case CustomOrderToTradeWindow:
+		return r.p.OrderToTradeWindow, true
+	case CustomOrderToTradeMinOrders:
+		return r.p.OrderToTradeMinOrders, true
+	}
 	return nil, false
 }
 
@@ -96,7 +187,8 @@ func (r *Rules) GetWarpFeePerSigner() uint64 {
 	return 0
 }
 
-// Add the Rules getter to the Genesis struct.
-func (g *Genesis) Rules(int64) *Rules {
-	return &Rules{g}
+// Rules returns the rules active at timestamp [t], taking every upgrade that
+// activated at or before [t] into account.
+func (g *Genesis) Rules(t int64) *Rules {
+	return &Rules{g, g.paramsAt(t)}
 }
@@ -0,0 +1,216 @@
+// CLOB/genesis/upgrades.go
+
+package genesis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Params are the rule parameters that may change over the life of the chain.
+// The genesis values apply from timestamp 0, and every Upgrade overrides a
+// subset of them from its activation timestamp onward.
+type Params struct {
+	// Block Limits
+	MaxBlockTxs   int    `json:"max_block_txs"`
+	MaxBlockUnits uint64 `json:"max_block_units"` // Must be reasonable or block can't be processed
+
+	// Tx Parameters
+	BaseUnits      uint64 `json:"base_units"`      // Units charged to every tx on top of its actions
+	ValidityWindow int64  `json:"validity_window"` // Max age of a tx before it expires, in block timestamp units
+
+	// Unit Pricing
+	MinUnitPrice               uint64 `json:"min_unit_price"`
+	UnitPriceChangeDenominator uint64 `json:"unit_price_change_denominator"` // Max change per window is 1/denominator
+	WindowTargetUnits          uint64 `json:"window_target_units"`           // Units per window above which the price rises
+
+	// Block Pricing
+	MinBlockCost               uint64 `json:"min_block_cost"`
+	BlockCostChangeDenominator uint64 `json:"block_cost_change_denominator"` // Max change per window is 1/denominator
+	WindowTargetBlocks         uint64 `json:"window_target_blocks"`          // Blocks per window above which the cost rises
+
+	// TickSize is the price increment limit orders must be placed on.
+	// 0 allows any price.
+	TickSize float64 `json:"tick_size"`
+
+	// MaxBatchInstructions is the most place/cancel/amend instructions a
+	// single batch order transaction may carry.
+	MaxBatchInstructions int `json:"max_batch_instructions"`
+
+	// MaxExpiredOrdersPerBlock bounds how many orders dead-man's switches
+	// may expire in one block. Orders over the limit expire in later blocks.
+	MaxExpiredOrdersPerBlock int `json:"max_expired_orders_per_block"`
+
+	// MaxOpenOrders is the most orders one account may rest in a market.
+	// 0 means unlimited.
+	MaxOpenOrders int `json:"max_open_orders"`
+
+	// MinNotional is the smallest value (price times quantity) of a limit
+	// order. 0 allows any value.
+	MinNotional float64 `json:"min_notional"`
+
+	// Order-to-trade throttle: once an account placed at least
+	// OrderToTradeMinOrders orders in a market over the last two windows of
+	// OrderToTradeWindow seconds, it may only place more while it placed at
+	// most MaxOrderToTradeRatio orders per trade. A ratio of 0 disables it.
+	// Accounts over the ratio are only throttled, no fee surcharge applies.
+	MaxOrderToTradeRatio  float64 `json:"max_order_to_trade_ratio"`
+	OrderToTradeWindow    int64   `json:"order_to_trade_window"`
+	OrderToTradeMinOrders uint64  `json:"order_to_trade_min_orders"`
+
+	// Features toggles optional behaviour by name. Flags that are not set are
+	// disabled.
+	Features map[string]bool `json:"features,omitempty"`
+
+	// Markets are the markets of the chain and their pre-trade limits. An
+	// upgrade lists only the markets it changes, each replacing the market
+	// with the same ID; it cannot add markets or change their assets.
+	Markets []CustomMarket `json:"markets"`
+}
+
+// clone returns a deep copy of the parameters
+func (p *Params) clone() *Params {
+	c := *p
+	if p.Features != nil {
+		c.Features = make(map[string]bool, len(p.Features))
+		for name, enabled := range p.Features {
+			c.Features[name] = enabled
+		}
+	}
+	c.Markets = append([]CustomMarket(nil), p.Markets...)
+	return &c
+}
+
+// Upgrade is a scheduled rule change. Rules holds a partial Params object:
+// only the fields it sets are changed, everything else carries over from the
+// previous upgrade (or from genesis).
+type Upgrade struct {
+	Name      string          `json:"name"`
+	Timestamp int64           `json:"timestamp"` // Activation time, in block timestamp units
+	Rules     json.RawMessage `json:"rules"`
+}
+
+// scheduledParams are the parameters active from [timestamp] onward
+type scheduledParams struct {
+	timestamp int64
+	name      string
+	params    *Params
+}
+
+// apply returns a copy of [p] with the overrides of the upgrade applied.
+// Unknown fields are rejected so a typo can't silently become a no-op.
+func (u *Upgrade) apply(p *Params) (*Params, error) {
+	next := p.clone()
+	if len(u.Rules) == 0 {
+		return next, nil
+	}
+	// Markets are replaced one by one, so they are decoded apart from the
+	// markets they override
+	next.Markets = nil
+	dec := json.NewDecoder(bytes.NewReader(u.Rules))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(next); err != nil {
+		return nil, err
+	}
+	overrides := next.Markets
+	next.Markets = append([]CustomMarket(nil), p.Markets...)
+	for _, m := range overrides {
+		i := findMarket(next.Markets, m.ID)
+		if i < 0 {
+			return nil, fmt.Errorf("unknown market '%s'", m.ID)
+		}
+		if !sameAsset(m.BaseAsset, next.Markets[i].BaseAsset) || !sameAsset(m.QuoteAsset, next.Markets[i].QuoteAsset) {
+			return nil, fmt.Errorf("cannot change the assets of market '%s'", m.ID)
+		}
+		next.Markets[i] = m
+	}
+	return next, nil
+}
+
+// sameAsset reports whether [a] and [b] name the same asset
+func sameAsset(a string, b string) bool {
+	x, err := parseAsset(a)
+	if err != nil {
+		return false
+	}
+	y, err := parseAsset(b)
+	return err == nil && x == y
+}
+
+// findMarket returns the index of the market with [id] in [markets], or -1
+func findMarket(markets []CustomMarket, id string) int {
+	for i := range markets {
+		if markets[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// buildSchedule resolves the upgrades into the full set of parameters active
+// at each activation timestamp. It assumes the upgrades have been validated.
+func (g *Genesis) buildSchedule() error {
+	upgrades := make([]Upgrade, len(g.Upgrades))
+	copy(upgrades, g.Upgrades)
+	sort.SliceStable(upgrades, func(i, j int) bool {
+		return upgrades[i].Timestamp < upgrades[j].Timestamp
+	})
+
+	schedule := []*scheduledParams{{timestamp: 0, name: "genesis", params: g.Params.clone()}}
+	for _, u := range upgrades {
+		params, err := u.apply(schedule[len(schedule)-1].params)
+		if err != nil {
+			return fmt.Errorf("%w: upgrade '%s': %v", ErrInvalidGenesisConfig, u.Name, err)
+		}
+		schedule = append(schedule, &scheduledParams{timestamp: u.Timestamp, name: u.Name, params: params})
+	}
+	g.schedule = schedule
+	return nil
+}
+
+// paramsAt returns the parameters active at timestamp [t]
+func (g *Genesis) paramsAt(t int64) *Params {
+	if len(g.schedule) == 0 {
+		return &g.Params
+	}
+	// Find the first entry activating after t; the one before it is active
+	i := sort.Search(len(g.schedule), func(i int) bool {
+		return g.schedule[i].timestamp > t
+	})
+	if i == 0 {
+		return g.schedule[0].params
+	}
+	return g.schedule[i-1].params
+}
+
+// ActiveUpgrade returns the name of the latest upgrade active at timestamp
+// [t], or "genesis" if none has activated yet.
+func (g *Genesis) ActiveUpgrade(t int64) string {
+	i := sort.Search(len(g.schedule), func(i int) bool {
+		return g.schedule[i].timestamp > t
+	})
+	if i == 0 {
+		return "genesis"
+	}
+	return g.schedule[i-1].name
+}
+
+// ApplyUpgrades adds the upgrades in [upgradeBytes] (a JSON array of Upgrade)
+// to the schedule. This lets nodes roll out rule changes through the VM's
+// upgrade file without regenerating genesis.
+func (g *Genesis) ApplyUpgrades(upgradeBytes []byte) error {
+	if len(upgradeBytes) == 0 {
+		return nil
+	}
+	var upgrades []Upgrade
+	if err := json.Unmarshal(upgradeBytes, &upgrades); err != nil {
+		return fmt.Errorf("failed to unmarshal upgrades: %w", err)
+	}
+	g.Upgrades = append(g.Upgrades, upgrades...)
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	return g.buildSchedule()
+}
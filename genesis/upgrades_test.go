@@ -0,0 +1,116 @@
+// CLOB/genesis/upgrades_test.go
+
+package genesis
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+// scheduled returns the default genesis with [upgrades], listed in the order
+// given, and its schedule resolved
+func scheduled(t *testing.T, upgrades ...Upgrade) *Genesis {
+	t.Helper()
+	g := Default()
+	g.Upgrades = upgrades
+	g, err := NewFromGenesis(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestParamsAt(t *testing.T) {
+	g := scheduled(t,
+		Upgrade{Name: "second", Timestamp: 200, Rules: json.RawMessage(`{"max_block_txs": 30}`)},
+		Upgrade{Name: "first", Timestamp: 100, Rules: json.RawMessage(`{"max_block_txs": 20, "tick_size": 0.5}`)},
+	)
+	if len(g.schedule) != 3 {
+		t.Fatalf("%d scheduled entries, want 3", len(g.schedule))
+	}
+
+	tests := []struct {
+		t        int64
+		txs      int
+		tickSize float64
+		upgrade  string
+	}{
+		{-1, 1000, 0, "genesis"},
+		{0, 1000, 0, "genesis"},
+		{99, 1000, 0, "genesis"},
+		{100, 20, 0.5, "first"},
+		{199, 20, 0.5, "first"},
+		{200, 30, 0.5, "second"}, // Carries over what it does not override
+		{1 << 40, 30, 0.5, "second"},
+	}
+	for _, tt := range tests {
+		rules := g.Rules(tt.t)
+		if rules.GetMaxBlockTxs() != tt.txs || rules.GetTickSize() != tt.tickSize {
+			t.Fatalf("at %d: max block txs %d and tick size %v, want %d and %v", tt.t, rules.GetMaxBlockTxs(), rules.GetTickSize(), tt.txs, tt.tickSize)
+		}
+		if name := g.ActiveUpgrade(tt.t); name != tt.upgrade {
+			t.Fatalf("at %d: active upgrade %s, want %s", tt.t, name, tt.upgrade)
+		}
+	}
+
+	// Upgrades never change the parameters before them
+	if g.Params.MaxBlockTxs != 1000 || g.Params.TickSize != 0 {
+		t.Fatal("upgrades changed the genesis parameters")
+	}
+}
+
+func TestScheduleServedGenesis(t *testing.T) {
+	g := scheduled(t, Upgrade{Name: "first", Timestamp: 100, Rules: json.RawMessage(`{"max_block_txs": 20}`)})
+	b, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A genesis decoded from JSON only has the genesis rules until its
+	// schedule is resolved
+	var served Genesis
+	if err := json.Unmarshal(b, &served); err != nil {
+		t.Fatal(err)
+	}
+	if txs := served.Rules(100).GetMaxBlockTxs(); txs != 1000 {
+		t.Fatalf("max block txs %d before resolving the schedule, want 1000", txs)
+	}
+	resolved, err := NewFromGenesis(&served)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txs := resolved.Rules(100).GetMaxBlockTxs(); txs != 20 {
+		t.Fatalf("max block txs %d, want 20", txs)
+	}
+}
+
+func TestUpgradeMarkets(t *testing.T) {
+	g := scheduled(t, Upgrade{
+		Name:      "limits",
+		Timestamp: 100,
+		Rules:     json.RawMessage(`{"markets": [{"id": "default", "min_quantity": 2}]}`),
+	})
+	if m, ok := g.Rules(99).GetMarket(DefaultMarket); !ok || m.MinQuantity != 0 {
+		t.Fatalf("market %+v before the upgrade, want no minimum", m)
+	}
+	if m, ok := g.Rules(100).GetMarket(DefaultMarket); !ok || m.MinQuantity != 2 {
+		t.Fatalf("market %+v after the upgrade, want a minimum of 2", m)
+	}
+	if _, ok := g.Rules(100).GetMarket("missing"); ok {
+		t.Fatal("found a market that does not exist")
+	}
+
+	for name, rules := range map[string]string{
+		"an unknown market": `{"markets": [{"id": "missing"}]}`,
+		"changed assets":    `{"markets": [{"id": "default", "base_asset": "` + ids.GenerateTestID().String() + `"}]}`,
+		"invalid limits":    `{"markets": [{"id": "default", "min_price": 2, "max_price": 1}]}`,
+	} {
+		g := Default()
+		g.Upgrades = []Upgrade{{Name: "bad", Timestamp: 100, Rules: json.RawMessage(rules)}}
+		if _, err := NewFromGenesis(g); err == nil {
+			t.Fatalf("accepted an upgrade with %s", name)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+// CLOB/genesis/validate.go
+
+package genesis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"CLOB/storage"
+	"CLOB/utils"
+)
+
+// ValidationError describes a single problem found in a genesis configuration.
+// Path is the JSON path of the offending field, e.g. "initial_orders[2].price".
+type ValidationError struct {
+	Path    string
+	Message string
+	Err     error // Sentinel the problem maps to, for errors.Is
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors collects every problem found by Validate so that they can
+// be reported at once instead of failing on the first one.
+type ValidationErrors []*ValidationError
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Error()
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidGenesisConfig, strings.Join(msgs, "; "))
+}
+
+// Unwrap exposes the sentinel of every collected problem, so errors.Is works
+// for both ErrInvalidGenesisConfig and more specific errors such as
+// ErrDuplicateInitialOrder.
+func (v ValidationErrors) Unwrap() []error {
+	errs := []error{ErrInvalidGenesisConfig}
+	for _, e := range v {
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+// add records a problem at the given JSON path.
+func (v *ValidationErrors) add(err error, path string, format string, args ...any) {
+	*v = append(*v, &ValidationError{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+		Err:     err,
+	})
+}
+
+// Validate checks the genesis configuration and returns a ValidationErrors
+// listing every problem found, or nil if the configuration is valid.
+func (g *Genesis) Validate() error {
+	var errs ValidationErrors
+
+	// Validate configuration parameters
+	if g.HRP == "" {
+		errs.add(ErrInvalidGenesisConfig, "hrp", "must not be empty")
+	}
+	if g.Operator != "" {
+		if _, err := utils.ParseAddress(g.Operator); err != nil {
+			errs.add(ErrInvalidGenesisConfig, "operator", "invalid address '%s': %v", g.Operator, err)
+		}
+	}
+	errs.validateParams("", &g.Params)
+
+	// Validate upgrades, checking the parameters that result from each one
+	errs.validateUpgrades(g)
+
+	// Validate markets
+	markets := make(map[string]int)
+	if len(g.Markets) == 0 {
+		errs.add(ErrInvalidGenesisConfig, "markets", "at least one market must be defined")
+	}
+	for i, m := range g.Markets {
+		path := fmt.Sprintf("markets[%d]", i)
+		if m.ID == "" {
+			errs.add(ErrInvalidGenesisConfig, path+".id", "must not be empty")
+		} else if first, exists := markets[m.ID]; exists {
+			errs.add(ErrInvalidGenesisConfig, path+".id", "duplicate market ID '%s' (first defined at markets[%d])", m.ID, first)
+		} else {
+			markets[m.ID] = i
+		}
+		base, baseErr := parseAsset(m.BaseAsset)
+		if baseErr != nil {
+			errs.add(ErrInvalidGenesisConfig, path+".base_asset", "invalid asset ID '%s': %v", m.BaseAsset, baseErr)
+		}
+		quote, quoteErr := parseAsset(m.QuoteAsset)
+		if quoteErr != nil {
+			errs.add(ErrInvalidGenesisConfig, path+".quote_asset", "invalid asset ID '%s': %v", m.QuoteAsset, quoteErr)
+		}
+		if m.BaseAsset != "" && m.QuoteAsset != "" && baseErr == nil && quoteErr == nil && base == quote {
+			errs.add(ErrInvalidGenesisConfig, path+".quote_asset", "must differ from base_asset")
+		}
+	}
+
+	// Validate allocations
+	addresses := make(map[string]int)
+	for i, alloc := range g.Allocations {
+		path := fmt.Sprintf("allocations[%d]", i)
+		if _, err := utils.ParseAddress(alloc.Address); err != nil {
+			errs.add(ErrInvalidGenesisConfig, path+".address", "invalid address '%s': %v", alloc.Address, err)
+		} else if first, exists := addresses[alloc.Address]; exists {
+			errs.add(ErrInvalidGenesisConfig, path+".address", "duplicate allocation for '%s' (first defined at allocations[%d])", alloc.Address, first)
+		} else {
+			addresses[alloc.Address] = i
+		}
+	}
+
+	// Validate initial orders
+	var (
+		orderIDs = make(map[string]int)
+		bestBid  = make(map[string]int) // Market to index of its highest bid
+		bestAsk  = make(map[string]int) // Market to index of its lowest ask
+	)
+	for i, order := range g.InitialOrders {
+		path := fmt.Sprintf("initial_orders[%d]", i)
+
+		_, validMarket := markets[order.Market]
+		if !validMarket {
+			errs.add(ErrInvalidGenesisConfig, path+".market", "unknown market '%s'", order.Market)
+		}
+
+		if order.ID == "" {
+			errs.add(ErrInvalidGenesisConfig, path+".id", "must not be empty")
+		} else if first, exists := orderIDs[order.ID]; exists {
+			errs.add(ErrDuplicateInitialOrder, path+".id", "duplicate order ID '%s' (first defined at initial_orders[%d])", order.ID, first)
+		} else {
+			orderIDs[order.ID] = i
+		}
+
+		validSide := order.Side == "buy" || order.Side == "sell"
+		if !validSide {
+			errs.add(ErrInvalidGenesisConfig, path+".side", "invalid side '%s', expected \"buy\" or \"sell\"", order.Side)
+		}
+
+		validType := false
+		switch order.OrderType {
+		case "limit":
+			validType = true
+		case "market":
+			// Market orders execute immediately and can never rest in a book
+			errs.add(ErrInvalidGenesisConfig, path+".order_type", "market orders cannot rest in the initial order book")
+		default:
+			errs.add(ErrInvalidGenesisConfig, path+".order_type", "invalid order type '%s', expected \"limit\"", order.OrderType)
+		}
+
+		validPrice := order.Price > 0 && !math.IsInf(order.Price, 0)
+		if !validPrice {
+			errs.add(ErrInvalidGenesisConfig, path+".price", "must be a positive finite number, got %v", order.Price)
+		} else if g.TickSize > 0 && !math.IsInf(g.TickSize, 0) && !storage.IsOnTick(order.Price, g.TickSize) {
+			// Initial orders rest under the genesis rules, so they must be
+			// prices AddOrderAction would accept at genesis
+			errs.add(ErrInvalidGenesisConfig, path+".price", "must be a multiple of tick_size %v, got %v", g.TickSize, order.Price)
+		}
+		if !(order.Quantity > 0) || math.IsInf(order.Quantity, 0) {
+			errs.add(ErrInvalidGenesisConfig, path+".quantity", "must be a positive finite number, got %v", order.Quantity)
+		}
+
+		if _, err := time.Parse(time.RFC3339, order.Timestamp); err != nil {
+			errs.add(ErrInvalidGenesisConfig, path+".timestamp", "must be an RFC3339 timestamp, got '%s'", order.Timestamp)
+		}
+
+		// Track the top of each book, ignoring orders that are already invalid
+		if !validMarket || !validSide || !validType || !validPrice {
+			continue
+		}
+		if order.Side == "buy" {
+			if best, ok := bestBid[order.Market]; !ok || order.Price > g.InitialOrders[best].Price {
+				bestBid[order.Market] = i
+			}
+		} else {
+			if best, ok := bestAsk[order.Market]; !ok || order.Price < g.InitialOrders[best].Price {
+				bestAsk[order.Market] = i
+			}
+		}
+	}
+
+	// A valid initial book must not be crossed, otherwise it would have
+	// matched before it was ever loaded
+	for i, m := range g.Markets {
+		if first, ok := markets[m.ID]; !ok || first != i {
+			continue // Invalid or duplicate market, already reported
+		}
+		bidIdx, hasBid := bestBid[m.ID]
+		askIdx, hasAsk := bestAsk[m.ID]
+		if !hasBid || !hasAsk {
+			continue
+		}
+		bid, ask := g.InitialOrders[bidIdx], g.InitialOrders[askIdx]
+		if bid.Price >= ask.Price {
+			errs.add(
+				ErrCrossedInitialBook,
+				fmt.Sprintf("initial_orders[%d].price", bidIdx),
+				"best bid %v crosses best ask %v at initial_orders[%d].price in market '%s'",
+				bid.Price, ask.Price, askIdx, m.ID,
+			)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// validateParams checks a set of rule parameters. [prefix] is prepended to
+// the JSON path of every problem.
+func (v *ValidationErrors) validateParams(prefix string, p *Params) {
+	if p.MaxBlockTxs <= 0 {
+		v.add(ErrInvalidGenesisConfig, prefix+"max_block_txs", "must be positive, got %d", p.MaxBlockTxs)
+	}
+	if p.MaxBlockUnits == 0 {
+		v.add(ErrInvalidGenesisConfig, prefix+"max_block_units", "must be positive")
+	}
+	if p.BaseUnits > p.MaxBlockUnits {
+		v.add(ErrInvalidGenesisConfig, prefix+"base_units", "must not exceed max_block_units (%d), got %d", p.MaxBlockUnits, p.BaseUnits)
+	}
+	if p.ValidityWindow <= 0 {
+		v.add(ErrInvalidGenesisConfig, prefix+"validity_window", "must be positive, got %d", p.ValidityWindow)
+	}
+
+	// Fee market parameters; denominators are divided by and targets of
+	// zero would make the price change on every window
+	if p.MinUnitPrice == 0 {
+		v.add(ErrInvalidGenesisConfig, prefix+"min_unit_price", "must be positive")
+	}
+	if p.UnitPriceChangeDenominator == 0 {
+		v.add(ErrInvalidGenesisConfig, prefix+"unit_price_change_denominator", "must be positive")
+	}
+	if p.WindowTargetUnits == 0 {
+		v.add(ErrInvalidGenesisConfig, prefix+"window_target_units", "must be positive")
+	}
+	if p.BlockCostChangeDenominator == 0 {
+		v.add(ErrInvalidGenesisConfig, prefix+"block_cost_change_denominator", "must be positive")
+	}
+	if p.WindowTargetBlocks == 0 {
+		v.add(ErrInvalidGenesisConfig, prefix+"window_target_blocks", "must be positive")
+	}
+	if p.TickSize < 0 || math.IsNaN(p.TickSize) || math.IsInf(p.TickSize, 0) {
+		v.add(ErrInvalidGenesisConfig, prefix+"tick_size", "must be zero or a positive finite number, got %v", p.TickSize)
+	}
+	if p.MaxBatchInstructions <= 0 {
+		v.add(ErrInvalidGenesisConfig, prefix+"max_batch_instructions", "must be positive, got %d", p.MaxBatchInstructions)
+	}
+	if p.MaxExpiredOrdersPerBlock <= 0 {
+		v.add(ErrInvalidGenesisConfig, prefix+"max_expired_orders_per_block", "must be positive, got %d", p.MaxExpiredOrdersPerBlock)
+	}
+	if p.MaxOpenOrders < 0 {
+		v.add(ErrInvalidGenesisConfig, prefix+"max_open_orders", "must not be negative, got %d", p.MaxOpenOrders)
+	}
+	if p.MinNotional < 0 || math.IsNaN(p.MinNotional) || math.IsInf(p.MinNotional, 0) {
+		v.add(ErrInvalidGenesisConfig, prefix+"min_notional", "must be zero or a positive finite number, got %v", p.MinNotional)
+	}
+	if p.MaxOrderToTradeRatio < 0 || math.IsNaN(p.MaxOrderToTradeRatio) || math.IsInf(p.MaxOrderToTradeRatio, 0) {
+		v.add(ErrInvalidGenesisConfig, prefix+"max_order_to_trade_ratio", "must be zero or a positive finite number, got %v", p.MaxOrderToTradeRatio)
+	}
+	if p.MaxOrderToTradeRatio > 0 && p.OrderToTradeWindow <= 0 {
+		v.add(ErrInvalidGenesisConfig, prefix+"order_to_trade_window", "must be positive when max_order_to_trade_ratio is set, got %d", p.OrderToTradeWindow)
+	}
+
+	// Upgrades may change the limits of markets, so they are checked with
+	// the rest of the parameters
+	for i, m := range p.Markets {
+		path := fmt.Sprintf("%smarkets[%d]", prefix, i)
+		v.validateBounds(path, "quantity", m.MinQuantity, m.MaxQuantity)
+		v.validateBounds(path, "price", m.MinPrice, m.MaxPrice)
+	}
+}
+
+// validateBounds checks the min_[name] and max_[name] limits of a market:
+// both must be zero (unbounded) or positive and finite, and the minimum must
+// not exceed the maximum
+func (v *ValidationErrors) validateBounds(path string, name string, min float64, max float64) {
+	for _, b := range []struct {
+		field string
+		value float64
+	}{{"min_" + name, min}, {"max_" + name, max}} {
+		if b.value < 0 || math.IsNaN(b.value) || math.IsInf(b.value, 0) {
+			v.add(ErrInvalidGenesisConfig, path+"."+b.field, "must be zero or a positive finite number, got %v", b.value)
+		}
+	}
+	if min > 0 && max > 0 && min > max {
+		v.add(ErrInvalidGenesisConfig, path+".max_"+name, "must not be below min_%s (%v < %v)", name, max, min)
+	}
+}
+
+// validateUpgrades checks the upgrade schedule: activation timestamps must be
+// positive and unique, and the parameters in effect after each upgrade must
+// themselves be valid.
+func (v *ValidationErrors) validateUpgrades(g *Genesis) {
+	order := make([]int, len(g.Upgrades))
+	names := make(map[string]int)
+	timestamps := make(map[int64]int)
+	for i, u := range g.Upgrades {
+		order[i] = i
+		path := fmt.Sprintf("upgrades[%d]", i)
+		if u.Name == "" {
+			v.add(ErrInvalidGenesisConfig, path+".name", "must not be empty")
+		} else if first, exists := names[u.Name]; exists {
+			v.add(ErrInvalidGenesisConfig, path+".name", "duplicate upgrade name '%s' (first defined at upgrades[%d])", u.Name, first)
+		} else {
+			names[u.Name] = i
+		}
+		if u.Timestamp <= 0 {
+			v.add(ErrInvalidGenesisConfig, path+".timestamp", "must be positive, got %d", u.Timestamp)
+		} else if first, exists := timestamps[u.Timestamp]; exists {
+			v.add(ErrInvalidGenesisConfig, path+".timestamp", "activates at the same time as upgrades[%d]", first)
+		} else {
+			timestamps[u.Timestamp] = i
+		}
+	}
+
+	// Apply upgrades in activation order so each is checked against the
+	// parameters it actually overrides
+	sort.SliceStable(order, func(a, b int) bool {
+		return g.Upgrades[order[a]].Timestamp < g.Upgrades[order[b]].Timestamp
+	})
+	params := &g.Params
+	for _, i := range order {
+		path := fmt.Sprintf("upgrades[%d].rules", i)
+		next, err := g.Upgrades[i].apply(params)
+		if err != nil {
+			v.add(ErrInvalidGenesisConfig, path, "invalid rule overrides: %v", err)
+			continue
+		}
+		v.validateParams(path+".", next)
+		params = next
+	}
+}
+
+// AsValidationErrors extracts the individual problems from an error returned
+// by New or Validate. It returns nil if err carries no validation problems.
+func AsValidationErrors(err error) ValidationErrors {
+	var errs ValidationErrors
+	if errors.As(err, &errs) {
+		return errs
+	}
+	return nil
+}
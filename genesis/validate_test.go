@@ -0,0 +1,93 @@
+// CLOB/genesis/validate_test.go
+
+package genesis
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(g *Genesis)
+		path   string // Path of the only problem expected, empty if valid
+		err    error
+	}{
+		{
+			name:   "default",
+			modify: func(*Genesis) {},
+		},
+		{
+			name: "crossed book",
+			modify: func(g *Genesis) {
+				g.InitialOrders[0].Price = 101
+			},
+			path: "initial_orders[0].price",
+			err:  ErrCrossedInitialBook,
+		},
+		{
+			name: "touching book",
+			modify: func(g *Genesis) {
+				g.InitialOrders[1].Price = 100
+			},
+			path: "initial_orders[0].price",
+			err:  ErrCrossedInitialBook,
+		},
+		{
+			name: "off-tick price",
+			modify: func(g *Genesis) {
+				g.TickSize = 0.5
+				g.InitialOrders[1].Price = 101.25
+			},
+			path: "initial_orders[1].price",
+			err:  ErrInvalidGenesisConfig,
+		},
+		{
+			name: "on-tick price",
+			modify: func(g *Genesis) {
+				g.TickSize = 0.5
+				g.InitialOrders[1].Price = 100.5
+			},
+		},
+		{
+			name: "duplicate ID",
+			modify: func(g *Genesis) {
+				g.InitialOrders[1].ID = g.InitialOrders[0].ID
+			},
+			path: "initial_orders[1].id",
+			err:  ErrDuplicateInitialOrder,
+		},
+		{
+			name: "bad timestamp",
+			modify: func(g *Genesis) {
+				g.InitialOrders[0].Timestamp = "2024-01-01 00:00:00"
+			},
+			path: "initial_orders[0].timestamp",
+			err:  ErrInvalidGenesisConfig,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := Default()
+			tt.modify(g)
+			err := g.Validate()
+			if tt.path == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			errs := AsValidationErrors(err)
+			if len(errs) != 1 {
+				t.Fatalf("got %v, want one problem at %s", err, tt.path)
+			}
+			if errs[0].Path != tt.path {
+				t.Fatalf("problem at %s, want %s", errs[0].Path, tt.path)
+			}
+			if !errors.Is(err, tt.err) || !errors.Is(err, ErrInvalidGenesisConfig) {
+				t.Fatalf("error %v does not match %v", err, tt.err)
+			}
+		})
+	}
+}
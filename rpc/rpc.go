@@ -5,8 +5,10 @@ package rpc
 import (
 	"context"
 	"strings"
+	"time"
 
 	"CLOB/actions"
+	"CLOB/auth"
 	"CLOB/consts"
 	"CLOB/genesis"
 	"CLOB/storage"
@@ -20,6 +22,7 @@ import (
 
 type JSONRPCClient struct {
 	requester *requester.EndpointRequester
+	hyper     *rpc.JSONRPCClient // hypersdk endpoint, for fees
 	chainID   ids.ID
 	genesis   *genesis.Genesis
 }
@@ -27,12 +30,14 @@ type JSONRPCClient struct {
 // NewJSONRPCClient creates a new client object.
 func NewJSONRPCClient(uri string, chainID ids.ID) *JSONRPCClient {
 	uri = strings.TrimSuffix(uri, "/")
+	hyper := rpc.NewJSONRPCClient(uri)
 	uri += "/rpc"
 	req := requester.New(uri, consts.Name)
-	return &JSONRPCClient{req, chainID, nil}
+	return &JSONRPCClient{req, hyper, chainID, nil}
 }
 
-// Genesis retrieves the genesis configuration.
+// Genesis retrieves the genesis configuration, with its upgrade schedule
+// resolved so Rules(t) matches the node's.
 func (cli *JSONRPCClient) Genesis(ctx context.Context) (*genesis.Genesis, error) {
 	if cli.genesis != nil {
 		return cli.genesis, nil
@@ -43,41 +48,124 @@ func (cli *JSONRPCClient) Genesis(ctx context.Context) (*genesis.Genesis, error)
 	if err != nil {
 		return nil, err
 	}
-	cli.genesis = resp.Genesis
-	return resp.Genesis, nil
+	g, err := genesis.NewFromGenesis(resp.Genesis)
+	if err != nil {
+		return nil, err
+	}
+	cli.genesis = g
+	return g, nil
 }
 
-// AddOrderArgs represents the arguments for adding an order.
+// AddOrderArgs represents the arguments for adding an order. Tx carries a
+// signed AddOrder transaction; without it the node signs the order described
+// by the other fields with its dev key, which only exists in test mode.
 type AddOrderArgs struct {
-	OrderID   string  `json:"order_id"`
-	Side      string  `json:"side"`       // "buy" or "sell"
-	Price     float64 `json:"price"`      // 0 for market orders
-	Quantity  float64 `json:"quantity"`
-	OrderType string  `json:"order_type"` // "limit" or "market"
+	Tx []byte `json:"tx,omitempty"`
+
+	ClientOrderID string  `json:"client_order_id,omitempty"`
+	Market        string  `json:"market"`
+	Side          string  `json:"side"`  // "buy" or "sell"
+	Price         float64 `json:"price"` // Worst price of a market order, 0 for none on a sell
+	Quantity      float64 `json:"quantity"`
+	OrderType     string  `json:"order_type"` // "limit" or "market"
 }
 
-// AddOrderReply represents the response after adding an order.
+// AddOrderReply represents the response after submitting an order.
 type AddOrderReply struct {
 	Success bool   `json:"success"`
 	Message string `json:"message,omitempty"`
+	TxID    ids.ID `json:"tx_id"`
+	OrderID string `json:"order_id,omitempty"` // Canonical ID the order will have
+
+	// Reject is set if the order failed a pre-trade check and was not
+	// submitted
+	Reject *actions.RejectError `json:"reject,omitempty"`
 }
 
-// AddOrder sends an AddOrder request to the server.
+// AddOrder sends an AddOrder request to the server. The order is only in the
+// mempool when this returns; see SubmitOrder to wait for it to be accepted.
+// An order failing a pre-trade check returns an *actions.RejectError, whose
+// Reason tells which check failed.
 func (cli *JSONRPCClient) AddOrder(ctx context.Context, args *AddOrderArgs) (*AddOrderReply, error) {
 	resp := new(AddOrderReply)
-	err := cli.requester.SendRequest(ctx, "addOrder", args, resp)
+	if err := cli.requester.SendRequest(ctx, "addOrder", args, resp); err != nil {
+		return resp, err
+	}
+	if resp.Reject != nil {
+		return resp, resp.Reject
+	}
+	return resp, nil
+}
+
+// BatchOrdersArgs represents the arguments for submitting many order
+// instructions on one market at once. Tx carries a signed BatchOrders
+// transaction, as in AddOrderArgs.
+type BatchOrdersArgs struct {
+	Tx []byte `json:"tx,omitempty"`
+
+	Market       string                     `json:"market"`
+	AllOrNothing bool                       `json:"all_or_nothing"`
+	Instructions []actions.BatchInstruction `json:"instructions"`
+}
+
+// BatchOrdersReply represents the response after submitting a batch.
+type BatchOrdersReply struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	TxID    ids.ID `json:"tx_id"`
+}
+
+// BatchOrders sends a BatchOrders request to the server. The batch is only
+// in the mempool when this returns; see SubmitBatchOrders to wait for the
+// outcome of its instructions.
+func (cli *JSONRPCClient) BatchOrders(ctx context.Context, args *BatchOrdersArgs) (*BatchOrdersReply, error) {
+	resp := new(BatchOrdersReply)
+	err := cli.requester.SendRequest(ctx, "batchOrders", args, resp)
 	return resp, err
 }
 
-// CancelOrderArgs represents the arguments for canceling an order.
+// GetBatchResultsArgs represents the arguments for retrieving the outcome of
+// a batch transaction.
+type GetBatchResultsArgs struct {
+	TxID ids.ID `json:"tx_id"`
+}
+
+// GetBatchResultsReply represents the response containing the outcome of
+// every instruction of a batch transaction.
+type GetBatchResultsReply struct {
+	Results []*storage.InstructionResult `json:"results"`
+}
+
+// GetBatchResults retrieves the outcome of every instruction of an accepted
+// batch transaction.
+func (cli *JSONRPCClient) GetBatchResults(ctx context.Context, txID ids.ID) ([]*storage.InstructionResult, error) {
+	resp := new(GetBatchResultsReply)
+	err := cli.requester.SendRequest(ctx, "getBatchResults", &GetBatchResultsArgs{TxID: txID}, resp)
+	if err != nil {
+		if strings.Contains(err.Error(), ErrBatchNotFound.Error()) {
+			return nil, ErrBatchNotFound
+		}
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// CancelOrderArgs represents the arguments for canceling an order, identified
+// by either its ID or its client order ID. Tx carries a signed CancelOrder
+// transaction, as in AddOrderArgs.
 type CancelOrderArgs struct {
-	OrderID string `json:"order_id"`
+	Tx []byte `json:"tx,omitempty"`
+
+	OrderID       string `json:"order_id,omitempty"`
+	ClientOrderID string `json:"client_order_id,omitempty"`
+	Market        string `json:"market"`
 }
 
-// CancelOrderReply represents the response after canceling an order.
+// CancelOrderReply represents the response after submitting a cancellation.
 type CancelOrderReply struct {
 	Success bool   `json:"success"`
 	Message string `json:"message,omitempty"`
+	TxID    ids.ID `json:"tx_id"`
 }
 
 // CancelOrder sends a CancelOrder request to the server.
@@ -87,23 +175,147 @@ func (cli *JSONRPCClient) CancelOrder(ctx context.Context, args *CancelOrderArgs
 	return resp, err
 }
 
-// GetOrderArgs represents the arguments for retrieving an order.
+// CancelAllArgs represents the arguments for canceling every resting order of
+// the sender. Tx carries a signed CancelAll transaction, as in
+// CancelOrderArgs.
+type CancelAllArgs struct {
+	Tx []byte `json:"tx,omitempty"`
+
+	Market string `json:"market,omitempty"` // Every market if empty
+	Side   string `json:"side,omitempty"`   // Both sides if empty
+}
+
+// CancelAllReply represents the response after submitting a cancel-all.
+type CancelAllReply struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	TxID    ids.ID `json:"tx_id"`
+}
+
+// CancelAll sends a CancelAll request to the server. The cancellation is
+// only in the mempool when this returns; see SubmitCancelAll to wait for the
+// cancelled orders.
+func (cli *JSONRPCClient) CancelAll(ctx context.Context, args *CancelAllArgs) (*CancelAllReply, error) {
+	resp := new(CancelAllReply)
+	err := cli.requester.SendRequest(ctx, "cancelAll", args, resp)
+	return resp, err
+}
+
+// GetCancelledArgs represents the arguments for retrieving the orders
+// cancelled by a cancel-all transaction.
+type GetCancelledArgs struct {
+	TxID ids.ID `json:"tx_id"`
+}
+
+// GetCancelledReply represents the response containing the cancelled orders.
+type GetCancelledReply struct {
+	OrderIDs []string `json:"order_ids"`
+}
+
+// GetCancelled retrieves the IDs of the orders cancelled by an accepted
+// cancel-all transaction.
+func (cli *JSONRPCClient) GetCancelled(ctx context.Context, txID ids.ID) ([]string, error) {
+	resp := new(GetCancelledReply)
+	err := cli.requester.SendRequest(ctx, "getCancelled", &GetCancelledArgs{TxID: txID}, resp)
+	if err != nil {
+		if strings.Contains(err.Error(), ErrCancelAllNotFound.Error()) {
+			return nil, ErrCancelAllNotFound
+		}
+		return nil, err
+	}
+	return resp.OrderIDs, nil
+}
+
+// SetCancelAfterArgs represents the arguments for setting the dead-man's
+// switch of the sender. Leaving both deadlines unset disarms it. Tx carries a
+// signed SetCancelAfter transaction, as in CancelOrderArgs.
+type SetCancelAfterArgs struct {
+	Tx []byte `json:"tx,omitempty"`
+
+	Height    uint64 `json:"height,omitempty"`    // Expire orders at this block height
+	Timestamp int64  `json:"timestamp,omitempty"` // Expire orders at this block time
+}
+
+// SetCancelAfterReply represents the response after submitting a change of
+// the dead-man's switch.
+type SetCancelAfterReply struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	TxID    ids.ID `json:"tx_id"`
+}
+
+// CancelAfterReply represents the response containing the dead-man's switch
+// of an address.
+type CancelAfterReply struct {
+	Armed     bool   `json:"armed"`
+	Height    uint64 `json:"height,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+}
+
+// SetCancelAfter sends a SetCancelAfter request to the server. The change is
+// only in the mempool when this returns; see SubmitSetCancelAfter to wait
+// for the accepted timer. Unless it is renewed before the deadline, every
+// resting order of the sender expires.
+func (cli *JSONRPCClient) SetCancelAfter(ctx context.Context, args *SetCancelAfterArgs) (*SetCancelAfterReply, error) {
+	resp := new(SetCancelAfterReply)
+	err := cli.requester.SendRequest(ctx, "setCancelAfter", args, resp)
+	if err != nil && strings.Contains(err.Error(), ErrDeadlinePassed.Error()) {
+		return nil, ErrDeadlinePassed
+	}
+	return resp, err
+}
+
+// GetCancelAfterArgs represents the arguments for retrieving the dead-man's
+// switch of an address.
+type GetCancelAfterArgs struct {
+	Address string `json:"address"`
+}
+
+// GetCancelAfter retrieves the accepted dead-man's switch of an address.
+func (cli *JSONRPCClient) GetCancelAfter(ctx context.Context, address string) (*CancelAfterReply, error) {
+	resp := new(CancelAfterReply)
+	err := cli.requester.SendRequest(ctx, "getCancelAfter", &GetCancelAfterArgs{Address: address}, resp)
+	return resp, err
+}
+
+// GetOrderArgs represents the arguments for retrieving an order. Client
+// order IDs are only unique per owner within a market, so a lookup by client
+// order ID needs its market.
 type GetOrderArgs struct {
-	OrderID string `json:"order_id"`
+	OrderID       string `json:"order_id,omitempty"`
+	ClientOrderID string `json:"client_order_id,omitempty"`
+	Address       string `json:"address,omitempty"` // Owner, required with ClientOrderID
+	Market        string `json:"market,omitempty"`  // Required with ClientOrderID, searches every market if empty otherwise
 }
 
 // GetOrderReply represents the response containing order details.
 type GetOrderReply struct {
-	OrderID   string  `json:"order_id"`
+	OrderID       string `json:"order_id"`
+	ClientOrderID string `json:"client_order_id,omitempty"`
+
+	Market    string  `json:"market"`
 	Side      string  `json:"side"`
 	Price     float64 `json:"price"`
 	Quantity  float64 `json:"quantity"`
 	OrderType string  `json:"order_type"`
 	Timestamp int64   `json:"timestamp"`
+	Height    uint64  `json:"height"` // Height the order was last read or updated at
+
+	// Lifecycle of the order once its block is accepted: open,
+	// partially_filled, filled, cancelled, expired or rejected, and why it
+	// last changed. Submitted orders are not found until then.
+	Status           string  `json:"status"`
+	Reason           string  `json:"reason"`
+	OriginalQuantity float64 `json:"original_quantity"`
+	FilledQuantity   float64 `json:"filled_quantity"`
+	AvgFillPrice     float64 `json:"avg_fill_price"` // 0 if never filled
 }
 
 // GetOrder retrieves the details of an order.
 func (cli *JSONRPCClient) GetOrder(ctx context.Context, args *GetOrderArgs) (*GetOrderReply, error) {
+	if args.ClientOrderID != "" && args.Market == "" {
+		return nil, ErrMarketRequired
+	}
 	resp := new(GetOrderReply)
 	err := cli.requester.SendRequest(ctx, "getOrder", args, resp)
 	if err != nil {
@@ -116,21 +328,406 @@ func (cli *JSONRPCClient) GetOrder(ctx context.Context, args *GetOrderArgs) (*Ge
 }
 
 // GetOrderBookArgs represents the arguments for retrieving the order book.
-type GetOrderBookArgs struct{}
+type GetOrderBookArgs struct {
+	Market string `json:"market"`
+}
 
 // GetOrderBookReply represents the response containing the order book.
 type GetOrderBookReply struct {
-	Bids []storage.Order `json:"bids"`
-	Asks []storage.Order `json:"asks"`
+	Bids   []storage.Order `json:"bids"`   // Best price first, then by time priority
+	Asks   []storage.Order `json:"asks"`   // Best price first, then by time priority
+	Height uint64          `json:"height"` // Height of the accepted block the book reflects
 }
 
-// GetOrderBook retrieves the current state of the order book.
-func (cli *JSONRPCClient) GetOrderBook(ctx context.Context) (*GetOrderBookReply, error) {
+// GetOrderBook retrieves the current state of a market's order book.
+func (cli *JSONRPCClient) GetOrderBook(ctx context.Context, market string) (*GetOrderBookReply, error) {
 	resp := new(GetOrderBookReply)
-	err := cli.requester.SendRequest(ctx, "getOrderBook", nil, resp)
+	err := cli.requester.SendRequest(ctx, "getOrderBook", &GetOrderBookArgs{Market: market}, resp)
 	return resp, err
 }
 
+// GetDepthArgs represents the arguments for retrieving the aggregated depth
+// of a market.
+type GetDepthArgs struct {
+	Market string  `json:"market"`
+	Levels int     `json:"levels,omitempty"` // Levels per side, 20 if 0
+	Bucket float64 `json:"bucket,omitempty"` // Price bucket size, 0 for exact prices
+}
+
+// GetDepthReply represents the response containing the aggregated depth of a
+// market.
+type GetDepthReply struct {
+	Bids      []storage.DepthLevel `json:"bids"` // Best (highest) price first
+	Asks      []storage.DepthLevel `json:"asks"` // Best (lowest) price first
+	Sequence  uint64               `json:"sequence"`
+	Checksum  uint32               `json:"checksum"` // Of the unbucketed top of the book, see storage.Checksum
+	Height    uint64               `json:"height"`
+	Timestamp int64                `json:"timestamp"`
+}
+
+// GetDepth retrieves the top [levels] levels of each side of a market,
+// aggregated by price or, if [bucket] is positive, by price bucket.
+func (cli *JSONRPCClient) GetDepth(ctx context.Context, market string, levels int, bucket float64) (*GetDepthReply, error) {
+	resp := new(GetDepthReply)
+	err := cli.requester.SendRequest(ctx, "getDepth", &GetDepthArgs{Market: market, Levels: levels, Bucket: bucket}, resp)
+	return resp, err
+}
+
+// GetChecksumArgs represents the arguments for retrieving the book checksum
+// of a market at a height.
+type GetChecksumArgs struct {
+	Market string `json:"market"`
+	Height uint64 `json:"height"`
+}
+
+// GetChecksum retrieves the book checksum of a market as of an accepted
+// height: that of the last block at or below it that changed the book.
+func (cli *JSONRPCClient) GetChecksum(ctx context.Context, market string, height uint64) (*storage.BookChecksum, error) {
+	resp := new(storage.BookChecksum)
+	err := cli.requester.SendRequest(ctx, "getChecksum", &GetChecksumArgs{Market: market, Height: height}, resp)
+	if err != nil {
+		if strings.Contains(err.Error(), ErrChecksumNotFound.Error()) {
+			return nil, ErrChecksumNotFound
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// VerifyDepth reports whether the levels of a depth reply, read without
+// bucketing, match its checksum.
+func VerifyDepth(depth *GetDepthReply) bool {
+	return storage.Checksum(depth.Bids, depth.Asks) == depth.Checksum
+}
+
+// GetOrderBookAtArgs represents the arguments for rebuilding the book of a
+// market as of a past height.
+type GetOrderBookAtArgs struct {
+	Market string `json:"market"`
+	Height uint64 `json:"height"`
+}
+
+// GetOrderBookAtReply represents the book of a market as of a past height.
+// Verified reports whether Checksum, computed from the rebuilt book, matches
+// the one indexed when the block was accepted.
+type GetOrderBookAtReply struct {
+	*storage.HistoricalBook
+	Checksum uint32 `json:"checksum"`
+	Verified bool   `json:"verified"`
+}
+
+// GetOrderBookAt rebuilds the book of a market as of an accepted height. It
+// fails with ErrHeightNotRetained once the node pruned that height.
+func (cli *JSONRPCClient) GetOrderBookAt(ctx context.Context, market string, height uint64) (*GetOrderBookAtReply, error) {
+	resp := new(GetOrderBookAtReply)
+	err := cli.requester.SendRequest(ctx, "getOrderBookAt", &GetOrderBookAtArgs{Market: market, Height: height}, resp)
+	if err != nil {
+		if strings.Contains(err.Error(), ErrHeightNotRetained.Error()) {
+			return nil, ErrHeightNotRetained
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetL3Args represents the arguments for listing the resting orders of a
+// market. Cursor is empty for the first page and the NextCursor of the
+// previous reply for the others. Nodes only keep the book of the last 128
+// accepted heights, so a cursor expires once the chain moves past the
+// CursorValidThrough height of its reply.
+type GetL3Args struct {
+	Market string `json:"market"`
+	Cursor string `json:"cursor,omitempty"`
+	Limit  int    `json:"limit,omitempty"` // 100 if 0
+}
+
+// L3Order represents a resting order and its place in the queue.
+type L3Order struct {
+	OrderID   string  `json:"order_id"`
+	Owner     string  `json:"owner"`
+	Side      string  `json:"side"`
+	Price     float64 `json:"price"`
+	Quantity  float64 `json:"quantity"` // Remaining quantity
+	Timestamp int64   `json:"timestamp"`
+	Level     int     `json:"level"`    // Index of the price level on its side, best first
+	Position  int     `json:"position"` // Orders ahead of it at its price level
+}
+
+// GetL3Reply represents a page of the resting orders of a market. Every page
+// of a listing reads the book at the same Height.
+type GetL3Reply struct {
+	Orders             []L3Order `json:"orders"`                // Bids then asks, best price and time priority first
+	NextCursor         string    `json:"next_cursor,omitempty"` // Empty on the last page
+	Total              int       `json:"total"`                 // Resting orders at Height
+	Sequence           uint64    `json:"sequence"`
+	Height             uint64    `json:"height"`
+	CursorValidThrough uint64    `json:"cursor_valid_through"` // Last accepted height NextCursor can be read at
+}
+
+// GetL3 retrieves a page of the resting orders of a market, order by order.
+// It returns ErrSnapshotExpired for a cursor read past its
+// CursorValidThrough height.
+func (cli *JSONRPCClient) GetL3(ctx context.Context, args *GetL3Args) (*GetL3Reply, error) {
+	resp := new(GetL3Reply)
+	err := cli.requester.SendRequest(ctx, "getL3", args, resp)
+	if err != nil && strings.Contains(err.Error(), ErrSnapshotExpired.Error()) {
+		return nil, ErrSnapshotExpired
+	}
+	return resp, err
+}
+
+// GetFullL3 pages through every resting order of a market. All pages are read
+// at the height of the first one, so it fails with ErrSnapshotExpired if the
+// chain accepts more than 128 blocks before the last page is read.
+func (cli *JSONRPCClient) GetFullL3(ctx context.Context, market string) ([]L3Order, uint64, error) {
+	var (
+		orders []L3Order
+		args   = &GetL3Args{Market: market, Limit: 1000}
+	)
+	for {
+		resp, err := cli.GetL3(ctx, args)
+		if err != nil {
+			return nil, 0, err
+		}
+		orders = append(orders, resp.Orders...)
+		if resp.NextCursor == "" {
+			return orders, resp.Height, nil
+		}
+		args.Cursor = resp.NextCursor
+	}
+}
+
+// TradeRange selects trades by block timestamp if FromTime or ToTime is set,
+// and by block height otherwise. Bounds are inclusive and an unset upper
+// bound is open.
+type TradeRange struct {
+	FromTime   int64  `json:"from_time,omitempty"`
+	ToTime     int64  `json:"to_time,omitempty"`
+	FromHeight uint64 `json:"from_height,omitempty"`
+	ToHeight   uint64 `json:"to_height,omitempty"`
+	Cursor     string `json:"cursor,omitempty"` // NextCursor of the previous page
+	Limit      int    `json:"limit,omitempty"`
+}
+
+// GetTradesArgs represents the arguments for listing the trades of a market.
+type GetTradesArgs struct {
+	Market string `json:"market"`
+	TradeRange
+}
+
+// GetTradesReply represents a page of the trades of a market, oldest first.
+type GetTradesReply struct {
+	Trades     []Trade `json:"trades"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// GetTrades retrieves a page of the past trades of a market.
+func (cli *JSONRPCClient) GetTrades(ctx context.Context, args *GetTradesArgs) (*GetTradesReply, error) {
+	resp := new(GetTradesReply)
+	err := cli.requester.SendRequest(ctx, "getTrades", args, resp)
+	return resp, err
+}
+
+// GetAccountTradesArgs represents the arguments for listing the trades of an
+// address.
+type GetAccountTradesArgs struct {
+	Address string `json:"address"`
+	TradeRange
+}
+
+// GetAccountTradesReply represents a page of the fills an address took part
+// in, oldest first.
+type GetAccountTradesReply struct {
+	Fills      []*storage.Fill `json:"fills"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// GetAccountTrades retrieves a page of the fills an address took part in, as
+// taker or maker, across markets.
+func (cli *JSONRPCClient) GetAccountTrades(ctx context.Context, args *GetAccountTradesArgs) (*GetAccountTradesReply, error) {
+	resp := new(GetAccountTradesReply)
+	err := cli.requester.SendRequest(ctx, "getAccountTrades", args, resp)
+	return resp, err
+}
+
+// GetCandlesArgs represents the arguments for retrieving the candles of a
+// market starting in [From, To]. An unset To is open.
+type GetCandlesArgs struct {
+	Market     string `json:"market"`
+	Resolution string `json:"resolution"` // "1m", "5m", "1h" or "1d"
+	From       int64  `json:"from"`
+	To         int64  `json:"to,omitempty"`
+}
+
+// GetCandlesReply represents the candles of a market, oldest first.
+type GetCandlesReply struct {
+	Candles []*storage.Candle `json:"candles"`
+}
+
+// GetCandles retrieves the OHLCV candles of a market. Periods without
+// trades have no candle, and long ranges are truncated: ask again from after
+// the last candle for more.
+func (cli *JSONRPCClient) GetCandles(
+	ctx context.Context,
+	market string,
+	resolution string,
+	from int64,
+	to int64,
+) ([]*storage.Candle, error) {
+	resp := new(GetCandlesReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"getCandles",
+		&GetCandlesArgs{Market: market, Resolution: resolution, From: from, To: to},
+		resp,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Candles, nil
+}
+
+// MarketStats are the rolling 24h trading statistics of a market and its
+// top of book. Prices are 0 when there is nothing to report.
+type MarketStats struct {
+	Market    string  `json:"market"`
+	LastPrice float64 `json:"last_price"`
+
+	Open        float64 `json:"open"`
+	High        float64 `json:"high"`
+	Low         float64 `json:"low"`
+	Volume      float64 `json:"volume"`
+	QuoteVolume float64 `json:"quote_volume"`
+	Trades      uint64  `json:"trades"`
+
+	BestBid  float64 `json:"best_bid"`
+	BestAsk  float64 `json:"best_ask"`
+	Spread   float64 `json:"spread"`
+	MidPrice float64 `json:"mid_price"`
+
+	Height    uint64 `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// GetMarketStatsArgs represents the arguments for retrieving the statistics
+// of a market.
+type GetMarketStatsArgs struct {
+	Market string `json:"market"`
+}
+
+// GetMarketStats retrieves the rolling 24h statistics of a market.
+func (cli *JSONRPCClient) GetMarketStats(ctx context.Context, market string) (*MarketStats, error) {
+	resp := new(MarketStats)
+	err := cli.requester.SendRequest(ctx, "getMarketStats", &GetMarketStatsArgs{Market: market}, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetAllMarketStatsReply represents the statistics of every market.
+type GetAllMarketStatsReply struct {
+	Markets []MarketStats `json:"markets"`
+}
+
+// GetAllMarketStats retrieves the statistics of every market, sorted by
+// market.
+func (cli *JSONRPCClient) GetAllMarketStats(ctx context.Context) ([]MarketStats, error) {
+	resp := new(GetAllMarketStatsReply)
+	err := cli.requester.SendRequest(ctx, "getAllMarketStats", nil, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Markets, nil
+}
+
+// ListOrdersArgs represents the arguments for listing the orders of an address.
+type ListOrdersArgs struct {
+	Address string `json:"address"`
+}
+
+// ListOrdersReply represents the response containing the orders of an address.
+type ListOrdersReply struct {
+	Orders []*storage.OrderRecord `json:"orders"`
+}
+
+// ListOrders retrieves every order placed by an address, including those no
+// longer in the book.
+func (cli *JSONRPCClient) ListOrders(ctx context.Context, address string) ([]*storage.OrderRecord, error) {
+	resp := new(ListOrdersReply)
+	err := cli.requester.SendRequest(ctx, "listOrders", &ListOrdersArgs{Address: address}, resp)
+	return resp.Orders, err
+}
+
+// GetBalanceArgs represents the arguments for retrieving a balance.
+type GetBalanceArgs struct {
+	Address string `json:"address"`
+	Asset   ids.ID `json:"asset"` // Empty ID is the native asset
+}
+
+// GetBalanceReply represents the response containing a balance.
+type GetBalanceReply struct {
+	Amount   uint64 `json:"amount"`
+	Reserved uint64 `json:"reserved"` // Part of Amount held by resting orders
+}
+
+// GetBalance retrieves the balance of an address in an asset.
+func (cli *JSONRPCClient) GetBalance(ctx context.Context, address string, asset ids.ID) (uint64, error) {
+	resp := new(GetBalanceReply)
+	err := cli.requester.SendRequest(ctx, "getBalance", &GetBalanceArgs{Address: address, Asset: asset}, resp)
+	return resp.Amount, err
+}
+
+// GetAvailableBalance retrieves the balance of an address in an asset that
+// its resting orders have not reserved.
+func (cli *JSONRPCClient) GetAvailableBalance(ctx context.Context, address string, asset ids.ID) (uint64, error) {
+	resp := new(GetBalanceReply)
+	err := cli.requester.SendRequest(ctx, "getBalance", &GetBalanceArgs{Address: address, Asset: asset}, resp)
+	if err != nil || resp.Reserved >= resp.Amount {
+		return 0, err
+	}
+	return resp.Amount - resp.Reserved, nil
+}
+
+// GetAssetArgs represents the arguments for retrieving an asset.
+type GetAssetArgs struct {
+	Asset ids.ID `json:"asset"`
+}
+
+// GetAssetReply represents the response containing asset metadata.
+type GetAssetReply struct {
+	Symbol   []byte `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+	Metadata []byte `json:"metadata"`
+	Supply   uint64 `json:"supply"`
+	Owner    string `json:"owner"`
+}
+
+// GetAsset retrieves the metadata of an asset.
+func (cli *JSONRPCClient) GetAsset(ctx context.Context, asset ids.ID) (*GetAssetReply, error) {
+	resp := new(GetAssetReply)
+	err := cli.requester.SendRequest(ctx, "getAsset", &GetAssetArgs{Asset: asset}, resp)
+	if err != nil {
+		if strings.Contains(err.Error(), ErrAssetNotFound.Error()) {
+			return nil, ErrAssetNotFound
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// WaitForBalance waits until the balance of an address in an asset is at
+// least min or a timeout occurs.
+func (cli *JSONRPCClient) WaitForBalance(ctx context.Context, address string, asset ids.ID, min uint64) error {
+	return rpc.Wait(ctx, func(ctx context.Context) (bool, error) {
+		balance, err := cli.GetBalance(ctx, address, asset)
+		if err != nil {
+			return false, err
+		}
+		return balance >= min, nil
+	})
+}
+
 // WaitForOrder waits until the order is available or a timeout occurs.
 func (cli *JSONRPCClient) WaitForOrder(ctx context.Context, orderID string) (*GetOrderReply, error) {
 	var order *GetOrderReply
@@ -153,6 +750,231 @@ func (cli *JSONRPCClient) WaitForOrder(ctx context.Context, orderID string) (*Ge
 	return order, nil
 }
 
+// TxArgs represents the arguments for retrieving the result of a transaction.
+type TxArgs struct {
+	TxID ids.ID `json:"tx_id"`
+}
+
+// TxReply represents the response containing the result of a transaction.
+type TxReply struct {
+	Timestamp int64  `json:"timestamp"`
+	Success   bool   `json:"success"`
+	Units     uint64 `json:"units"`
+}
+
+// GetTx retrieves the result of a transaction. It returns false if the
+// transaction has not been accepted.
+func (cli *JSONRPCClient) GetTx(ctx context.Context, txID ids.ID) (bool, *TxReply, error) {
+	resp := new(TxReply)
+	err := cli.requester.SendRequest(ctx, "tx", &TxArgs{TxID: txID}, resp)
+	if err != nil {
+		if strings.Contains(err.Error(), ErrTxNotFound.Error()) {
+			return false, nil, nil
+		}
+		return false, nil, err
+	}
+	return true, resp, nil
+}
+
+// WaitForTransaction waits until a transaction is accepted or a timeout
+// occurs, and returns whether it succeeded.
+func (cli *JSONRPCClient) WaitForTransaction(ctx context.Context, txID ids.ID) (bool, error) {
+	var success bool
+	err := rpc.Wait(ctx, func(ctx context.Context) (bool, error) {
+		found, resp, err := cli.GetTx(ctx, txID)
+		if err != nil || !found {
+			return false, err
+		}
+		success = resp.Success
+		return true, nil
+	})
+	return success, err
+}
+
+// GenerateTransaction builds [action] into a transaction signed by
+// [factory], paying the fee the node currently suggests.
+func (cli *JSONRPCClient) GenerateTransaction(
+	ctx context.Context,
+	action chain.Action,
+	factory *auth.ED25519Factory,
+) (*chain.Transaction, error) {
+	parser, err := cli.Parser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unitPrice, _, err := cli.hyper.SuggestedRawFee(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	base := &chain.Base{
+		Timestamp: now + parser.Rules(now).GetValidityWindow(),
+		ChainID:   cli.chainID,
+		UnitPrice: unitPrice,
+	}
+	actionRegistry, authRegistry := parser.Registry()
+	return chain.NewTx(base, nil, action).Sign(factory, actionRegistry, authRegistry)
+}
+
+// SubmitOrder signs the order described by [args] with [factory], submits it
+// and waits until its transaction is accepted. The order is in the book (or
+// filled) once this returns without error.
+func (cli *JSONRPCClient) SubmitOrder(ctx context.Context, factory *auth.ED25519Factory, args *AddOrderArgs) (*AddOrderReply, error) {
+	tx, err := cli.GenerateTransaction(ctx, &actions.AddOrder{
+		ClientOrderID: args.ClientOrderID,
+		Market:        args.Market,
+		Side:          args.Side,
+		Price:         args.Price,
+		Quantity:      args.Quantity,
+		OrderType:     args.OrderType,
+	}, factory)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cli.AddOrder(ctx, &AddOrderArgs{Tx: tx.Bytes()})
+	if err != nil {
+		return resp, err
+	}
+	success, err := cli.WaitForTransaction(ctx, resp.TxID)
+	if err != nil {
+		return resp, err
+	}
+
+	// Checks failing on chain or in the engine leave the order rejected, and
+	// the former fail the transaction
+	order, err := cli.GetOrder(ctx, &GetOrderArgs{OrderID: resp.OrderID})
+	if err != nil && err != ErrOrderNotFound {
+		return resp, err
+	}
+	rejected := order != nil && order.Status == string(storage.StatusRejected)
+	if !success || rejected {
+		resp.Success = false
+		if rejected {
+			if reason, ok := actions.ParseRejectReason(order.Reason); ok {
+				resp.Reject = &actions.RejectError{Reason: reason, Detail: "rejected on chain"}
+				return resp, resp.Reject
+			}
+		}
+		return resp, ErrTxFailed
+	}
+	return resp, nil
+}
+
+// SubmitCancelOrder signs the cancellation described by [args] with
+// [factory], submits it and waits until its transaction is accepted.
+func (cli *JSONRPCClient) SubmitCancelOrder(ctx context.Context, factory *auth.ED25519Factory, args *CancelOrderArgs) (*CancelOrderReply, error) {
+	tx, err := cli.GenerateTransaction(ctx, &actions.CancelOrder{
+		OrderID:       args.OrderID,
+		ClientOrderID: args.ClientOrderID,
+		Market:        args.Market,
+	}, factory)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cli.CancelOrder(ctx, &CancelOrderArgs{Tx: tx.Bytes()})
+	if err != nil {
+		return nil, err
+	}
+	if err := cli.waitForSuccess(ctx, resp.TxID); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// SubmitBatchOrders signs the batch described by [args] with [factory],
+// submits it, waits until its transaction is accepted and returns the
+// outcome of every instruction, in order. A batch failing on chain returns
+// ErrTxFailed but still reports why each instruction was not applied.
+func (cli *JSONRPCClient) SubmitBatchOrders(
+	ctx context.Context,
+	factory *auth.ED25519Factory,
+	args *BatchOrdersArgs,
+) (*BatchOrdersReply, []*storage.InstructionResult, error) {
+	tx, err := cli.GenerateTransaction(ctx, &actions.BatchOrders{
+		Market:       args.Market,
+		AllOrNothing: args.AllOrNothing,
+		Instructions: args.Instructions,
+	}, factory)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := cli.BatchOrders(ctx, &BatchOrdersArgs{Tx: tx.Bytes()})
+	if err != nil {
+		return resp, nil, err
+	}
+	success, err := cli.WaitForTransaction(ctx, resp.TxID)
+	if err != nil {
+		return resp, nil, err
+	}
+	results, err := cli.GetBatchResults(ctx, resp.TxID)
+	if err != nil {
+		return resp, nil, err
+	}
+	if !success {
+		return resp, results, ErrTxFailed
+	}
+	return resp, results, nil
+}
+
+// SubmitCancelAll signs the cancel-all described by [args] with [factory],
+// submits it, waits until its transaction is accepted and returns the IDs of
+// the cancelled orders.
+func (cli *JSONRPCClient) SubmitCancelAll(ctx context.Context, factory *auth.ED25519Factory, args *CancelAllArgs) ([]string, error) {
+	tx, err := cli.GenerateTransaction(ctx, &actions.CancelAll{
+		Market: args.Market,
+		Side:   args.Side,
+	}, factory)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cli.CancelAll(ctx, &CancelAllArgs{Tx: tx.Bytes()})
+	if err != nil {
+		return nil, err
+	}
+	if err := cli.waitForSuccess(ctx, resp.TxID); err != nil {
+		return nil, err
+	}
+	return cli.GetCancelled(ctx, resp.TxID)
+}
+
+// SubmitSetCancelAfter signs the change of the dead-man's switch described
+// by [args] with [factory], submits it, waits until its transaction is
+// accepted and returns the timer of the signer.
+func (cli *JSONRPCClient) SubmitSetCancelAfter(
+	ctx context.Context,
+	factory *auth.ED25519Factory,
+	args *SetCancelAfterArgs,
+) (*CancelAfterReply, error) {
+	tx, err := cli.GenerateTransaction(ctx, &actions.SetCancelAfter{
+		Height:    args.Height,
+		Timestamp: args.Timestamp,
+	}, factory)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cli.SetCancelAfter(ctx, &SetCancelAfterArgs{Tx: tx.Bytes()})
+	if err != nil {
+		return nil, err
+	}
+	if err := cli.waitForSuccess(ctx, resp.TxID); err != nil {
+		return nil, err
+	}
+	return cli.GetCancelAfter(ctx, utils.Address(auth.GetActor(tx.Auth)))
+}
+
+// waitForSuccess waits until a transaction is accepted and fails if it did
+// not succeed on chain
+func (cli *JSONRPCClient) waitForSuccess(ctx context.Context, txID ids.ID) error {
+	success, err := cli.WaitForTransaction(ctx, txID)
+	if err != nil {
+		return err
+	}
+	if !success {
+		return ErrTxFailed
+	}
+	return nil
+}
+
 // Parser implements chain.Parser for parsing actions and authentication.
 type Parser struct {
 	chainID ids.ID
@@ -182,5 +1004,15 @@ func (cli *JSONRPCClient) Parser(ctx context.Context) (chain.Parser, error) {
 
 // Error definitions.
 var (
-	ErrOrderNotFound = utils.NewError("order not found")
+	ErrOrderNotFound     = utils.NewError("order not found")
+	ErrAssetNotFound     = utils.NewError("asset not found")
+	ErrBatchNotFound     = utils.NewError("batch not found")
+	ErrCancelAllNotFound = utils.NewError("cancel-all not found")
+	ErrDeadlinePassed    = utils.NewError("cancel-after deadline has already passed")
+	ErrTxNotFound        = utils.NewError("transaction not found")
+	ErrTxFailed          = utils.NewError("transaction failed")
+	ErrSnapshotExpired   = utils.NewError("snapshot is no longer retained")
+	ErrChecksumNotFound  = utils.NewError("checksum not found")
+	ErrHeightNotRetained = utils.NewError("height is no longer retained")
+	ErrMarketRequired    = utils.NewError("market is required with a client order ID")
 )
@@ -0,0 +1,235 @@
+// CLOB/rpc/stream.go
+
+package rpc
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+
+	"CLOB/auth"
+	"CLOB/storage"
+	"CLOB/utils"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/crypto"
+	"github.com/gorilla/websocket"
+)
+
+// Stream channels, see controller.StreamMessage
+const (
+	ChannelTrades = "trades"
+	ChannelDepth  = "depth"
+	ChannelOrders = "orders"
+)
+
+// Stream message types other than the channels
+const (
+	TypeSubscribed    = "subscribed"
+	TypeUnsubscribed  = "unsubscribed"
+	TypeChallenge     = "challenge"
+	TypeAuthenticated = "authenticated"
+	TypeError         = "error"
+)
+
+var (
+	ErrSequenceGap      = errors.New("depth message does not follow the local book")
+	ErrChecksumMismatch = errors.New("local book does not match the checksum")
+	ErrNoChallenge      = errors.New("stream did not open with a challenge")
+	ErrNonceUsed        = errors.New("auth nonce already used, wait for the next challenge")
+)
+
+// StreamRequest is a message to the stream.
+type StreamRequest struct {
+	Op      string `json:"op"`
+	Channel string `json:"channel,omitempty"`
+	Market  string `json:"market,omitempty"`
+
+	Address   string `json:"address,omitempty"`
+	Nonce     []byte `json:"nonce,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// Trade is a public fill, without the accounts that traded.
+type Trade struct {
+	Price     float64      `json:"price"`
+	Quantity  float64      `json:"quantity"`
+	TakerSide storage.Side `json:"taker_side"`
+	TxID      ids.ID       `json:"tx_id"`
+	Height    uint64       `json:"height"`
+	Timestamp int64        `json:"timestamp"`
+	Index     uint32       `json:"index"`
+}
+
+// StreamMessage is a message from the stream.
+type StreamMessage struct {
+	Type      string `json:"type"`
+	Channel   string `json:"channel,omitempty"`
+	Market    string `json:"market,omitempty"`
+	Height    uint64 `json:"height,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+
+	Snapshot     bool                 `json:"snapshot,omitempty"`
+	Sequence     uint64               `json:"sequence,omitempty"`
+	PrevSequence uint64               `json:"prev_sequence,omitempty"`
+	Checksum     uint32               `json:"checksum,omitempty"`
+	Bids         []storage.DepthLevel `json:"bids,omitempty"`
+	Asks         []storage.DepthLevel `json:"asks,omitempty"`
+
+	Trades  []Trade                `json:"trades,omitempty"`
+	Updates []*storage.OrderRecord `json:"updates,omitempty"`
+	Fills   []storage.Fill         `json:"fills,omitempty"`
+
+	Nonce []byte `json:"nonce,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// StreamClient is a WebSocket client of the stream. Requests may be sent
+// from any goroutine; Listen must be called from a single one.
+type StreamClient struct {
+	conn *websocket.Conn
+	wmu  sync.Mutex
+
+	nmu   sync.Mutex
+	nonce []byte // Nonce of the last challenge, nil once used
+}
+
+// NewStreamClient connects to the stream of the chain served at [uri], the
+// same URI given to NewJSONRPCClient.
+func NewStreamClient(uri string) (*StreamClient, error) {
+	u, err := url.Parse(strings.TrimSuffix(uri, "/") + "/stream")
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &StreamClient{conn: conn}
+	msg, err := c.Listen()
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if msg.Type != TypeChallenge {
+		_ = conn.Close()
+		return nil, ErrNoChallenge
+	}
+	return c, nil
+}
+
+func (c *StreamClient) send(req *StreamRequest) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.conn.WriteJSON(req)
+}
+
+// Subscribe subscribes to [channel] of [market]. The orders channel takes no
+// market and requires Authenticate first.
+func (c *StreamClient) Subscribe(channel string, market string) error {
+	return c.send(&StreamRequest{Op: "subscribe", Channel: channel, Market: market})
+}
+
+// Unsubscribe stops the messages of [channel] of [market].
+func (c *StreamClient) Unsubscribe(channel string, market string) error {
+	return c.send(&StreamRequest{Op: "unsubscribe", Channel: channel, Market: market})
+}
+
+// Authenticate proves ownership of [priv]'s account on chain [chainID], so
+// the orders channel streams its order updates and fills. It signs the nonce
+// of the last challenge, which the attempt uses up: to retry, wait for Listen
+// to return the next challenge.
+func (c *StreamClient) Authenticate(priv crypto.PrivateKey, chainID ids.ID) error {
+	c.nmu.Lock()
+	nonce := c.nonce
+	c.nonce = nil
+	c.nmu.Unlock()
+	if nonce == nil {
+		return ErrNonceUsed
+	}
+
+	sig := crypto.Sign(auth.StreamAuthMessage(chainID, nonce), priv)
+	return c.send(&StreamRequest{
+		Op:        "auth",
+		Address:   utils.Address(priv.PublicKey()),
+		Nonce:     nonce,
+		Signature: sig[:],
+	})
+}
+
+// Listen blocks until the next message, and fails once the connection is
+// closed. Challenges are also kept for the next Authenticate.
+func (c *StreamClient) Listen() (*StreamMessage, error) {
+	msg := new(StreamMessage)
+	if err := c.conn.ReadJSON(msg); err != nil {
+		return nil, err
+	}
+	if msg.Type == TypeChallenge {
+		c.nmu.Lock()
+		c.nonce = msg.Nonce
+		c.nmu.Unlock()
+	}
+	return msg, nil
+}
+
+// Close closes the connection.
+func (c *StreamClient) Close() error {
+	return c.conn.Close()
+}
+
+// DepthBook is a local L2 copy of a market's book kept from its depth
+// messages.
+type DepthBook struct {
+	Market   string
+	Sequence uint64
+	Bids     []storage.DepthLevel // Best (highest) price first
+	Asks     []storage.DepthLevel // Best (lowest) price first
+}
+
+// Apply applies a depth message to the book and checks the result against
+// its checksum. On ErrSequenceGap or ErrChecksumMismatch the book is stale:
+// resubscribe to get a new snapshot.
+func (b *DepthBook) Apply(msg *StreamMessage) error {
+	if msg.Snapshot {
+		b.Market, b.Sequence = msg.Market, msg.Sequence
+		b.Bids, b.Asks = msg.Bids, msg.Asks
+	} else {
+		if msg.PrevSequence != b.Sequence {
+			return ErrSequenceGap
+		}
+		b.Sequence = msg.Sequence
+		b.Bids = applyLevels(b.Bids, msg.Bids, func(a, b float64) bool { return a > b })
+		b.Asks = applyLevels(b.Asks, msg.Asks, func(a, b float64) bool { return a < b })
+	}
+	if storage.Checksum(b.Bids, b.Asks) != msg.Checksum {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// applyLevels merges [changes] into [levels], both sorted with [better].
+// Changes without quantity remove their level.
+func applyLevels(levels []storage.DepthLevel, changes []storage.DepthLevel, better func(a, b float64) bool) []storage.DepthLevel {
+	merged := make([]storage.DepthLevel, 0, len(levels)+len(changes))
+	i := 0
+	for _, change := range changes {
+		for i < len(levels) && better(levels[i].Price, change.Price) {
+			merged = append(merged, levels[i])
+			i++
+		}
+		if i < len(levels) && levels[i].Price == change.Price {
+			i++
+		}
+		if change.Quantity > 0 {
+			merged = append(merged, change)
+		}
+	}
+	return append(merged, levels[i:]...)
+}
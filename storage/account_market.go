@@ -0,0 +1,133 @@
+// CLOB/storage/account_market.go
+package storage
+
+import (
+    "context"
+    "errors"
+
+    "github.com/ava-labs/avalanchego/database"
+    "github.com/ava-labs/hypersdk/chain"
+    "github.com/ava-labs/hypersdk/codec"
+    "github.com/ava-labs/hypersdk/consts"
+    "github.com/ava-labs/hypersdk/crypto"
+)
+
+// Reservation is the balance a limit order reserved when its transaction
+// executed, in the payment asset of its side (see MarketInfo.PaymentAsset)
+type Reservation struct {
+    OrderID       string
+    ClientOrderID string
+    Side          Side
+    Amount        uint64
+}
+
+// AccountMarket is the chain state an account keeps in a market, stored
+// under AccountMarketKey
+type AccountMarket struct {
+    Reservations []Reservation   // In the order they were made
+    Activity     AccountActivity // Counted while the order-to-trade throttle is enabled
+}
+
+// Empty reports whether the record holds nothing worth storing
+func (a *AccountMarket) Empty() bool {
+    return len(a.Reservations) == 0 && a.Activity == AccountActivity{}
+}
+
+// Find returns the index of the reservation of the order with [orderID] or,
+// if it is empty, [clientOrderID], or -1 if there is none. A client order ID
+// can be reused once its order left the book, so the latest one is found.
+func (a *AccountMarket) Find(orderID string, clientOrderID string) int {
+    for i := len(a.Reservations) - 1; i >= 0; i-- {
+        r := a.Reservations[i]
+        if (orderID != "" && r.OrderID == orderID) || (orderID == "" && r.ClientOrderID == clientOrderID) {
+            return i
+        }
+    }
+    return -1
+}
+
+// Remove deletes the reservation at index [i] and returns it
+func (a *AccountMarket) Remove(i int) Reservation {
+    r := a.Reservations[i]
+    a.Reservations = append(a.Reservations[:i], a.Reservations[i+1:]...)
+    return r
+}
+
+// Marshal encodes the record for storage
+func (a *AccountMarket) Marshal() ([]byte, error) {
+    p := codec.NewWriter(consts.MaxInt)
+    p.PackInt(len(a.Reservations))
+    for _, r := range a.Reservations {
+        p.PackString(r.OrderID)
+        p.PackString(r.ClientOrderID)
+        p.PackString(string(r.Side))
+        p.PackUint64(r.Amount)
+    }
+    p.PackInt64(a.Activity.Window)
+    p.PackUint64(a.Activity.Orders)
+    p.PackUint64(a.Activity.Trades)
+    p.PackUint64(a.Activity.PrevOrders)
+    p.PackUint64(a.Activity.PrevTrades)
+    return p.Bytes(), p.Err()
+}
+
+// UnmarshalAccountMarket decodes a record previously encoded with Marshal
+func UnmarshalAccountMarket(b []byte) (*AccountMarket, error) {
+    var a AccountMarket
+    p := codec.NewReader(b, consts.MaxInt)
+    count := p.UnpackInt(false)
+    for i := 0; i < count && p.Err() == nil; i++ {
+        var r Reservation
+        r.OrderID = p.UnpackString(true)
+        r.ClientOrderID = p.UnpackString(false)
+        r.Side = Side(p.UnpackString(true))
+        r.Amount = p.UnpackUint64(false)
+        a.Reservations = append(a.Reservations, r)
+    }
+    a.Activity.Window = p.UnpackInt64(false)
+    a.Activity.Orders = p.UnpackUint64(false)
+    a.Activity.Trades = p.UnpackUint64(false)
+    a.Activity.PrevOrders = p.UnpackUint64(false)
+    a.Activity.PrevTrades = p.UnpackUint64(false)
+    return &a, p.Err()
+}
+
+// GetAccountMarket returns the record of [pk] in [market], empty if it has
+// none
+func GetAccountMarket(ctx context.Context, db chain.Database, pk crypto.PublicKey, market string) (*AccountMarket, error) {
+    v, err := db.GetValue(ctx, AccountMarketKey(pk, market))
+    if errors.Is(err, database.ErrNotFound) {
+        return &AccountMarket{}, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return UnmarshalAccountMarket(v)
+}
+
+// GetAccountMarketFromState returns the record of [pk] in [market] from
+// accepted state, empty if it has none
+func GetAccountMarketFromState(ctx context.Context, f ReadState, pk crypto.PublicKey, market string) (*AccountMarket, error) {
+    values, errs := f(ctx, [][]byte{AccountMarketKey(pk, market)})
+    if errors.Is(errs[0], database.ErrNotFound) {
+        return &AccountMarket{}, nil
+    }
+    if errs[0] != nil {
+        return nil, errs[0]
+    }
+    return UnmarshalAccountMarket(values[0])
+}
+
+// SetAccountMarket stores the record of [pk] in [market]. An empty record
+// removes the key so that idle accounts don't take up state.
+func SetAccountMarket(ctx context.Context, db chain.Database, pk crypto.PublicKey, market string, a *AccountMarket) error {
+    k := AccountMarketKey(pk, market)
+    if a.Empty() {
+        return db.Remove(ctx, k)
+    }
+    v, err := a.Marshal()
+    if err != nil {
+        return err
+    }
+    return db.Insert(ctx, k, v)
+}
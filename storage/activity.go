@@ -0,0 +1,88 @@
+// CLOB/storage/activity.go
+package storage
+
+// AccountActivity counts the orders an account placed and the trades it took
+// part in on a market, over two consecutive fixed windows: the current one
+// and the one before it. Together they make a rolling order-to-trade ratio
+// that does not forget an account's history all at once. Orders are counted
+// when their transaction executes and trades once the operator settles the
+// block they happened in, so the counters are kept in chain state with the
+// reservations of the account (see AccountMarket).
+type AccountActivity struct {
+    Window     int64  `json:"window"`      // Index of the current window (timestamp / window length)
+    Orders     uint64 `json:"orders"`      // Orders placed in the current window
+    Trades     uint64 `json:"trades"`      // Trades taken part in during the current window
+    PrevOrders uint64 `json:"prev_orders"` // Orders placed in the previous window
+    PrevTrades uint64 `json:"prev_trades"` // Trades taken part in during the previous window
+}
+
+// roll moves the counters to the window containing [timestamp]
+func (a *AccountActivity) roll(timestamp int64, length int64) {
+    if length <= 0 {
+        return
+    }
+    window := timestamp / length
+    switch {
+    case window <= a.Window:
+    case window == a.Window+1:
+        a.PrevOrders, a.PrevTrades = a.Orders, a.Trades
+        a.Orders, a.Trades = 0, 0
+    default:
+        a.PrevOrders, a.PrevTrades, a.Orders, a.Trades = 0, 0, 0, 0
+    }
+    if window > a.Window {
+        a.Window = window
+    }
+}
+
+// RollingOrders returns the orders placed over both windows
+func (a AccountActivity) RollingOrders() uint64 {
+    return a.Orders + a.PrevOrders
+}
+
+// RollingTrades returns the trades taken part in over both windows
+func (a AccountActivity) RollingTrades() uint64 {
+    return a.Trades + a.PrevTrades
+}
+
+// OrderToTradeRatio returns the rolling orders per trade, counting an
+// account that never traded as having traded once
+func (a AccountActivity) OrderToTradeRatio() float64 {
+    trades := a.RollingTrades()
+    if trades == 0 {
+        trades = 1
+    }
+    return float64(a.RollingOrders()) / float64(trades)
+}
+
+// At returns the activity as of [timestamp], with windows of [length]
+// seconds
+func (a AccountActivity) At(timestamp int64, length int64) AccountActivity {
+    a.roll(timestamp, length)
+    return a
+}
+
+// RecordOrder counts an order placed at [timestamp]
+func (a *AccountActivity) RecordOrder(timestamp int64, length int64) {
+    a.roll(timestamp, length)
+    a.Orders++
+}
+
+// RecordTrades counts [n] trades taken part in at [timestamp]. Trades are
+// settled after the block they happened in, once orders of later blocks may
+// have moved the counters on, so they land in the window of [timestamp]:
+// the previous one if it is still counted, and nowhere otherwise.
+func (a *AccountActivity) RecordTrades(n uint64, timestamp int64, length int64) {
+    if length <= 0 {
+        return
+    }
+    switch window := timestamp / length; {
+    case window > a.Window:
+        a.roll(timestamp, length)
+        a.Trades += n
+    case window == a.Window:
+        a.Trades += n
+    case window == a.Window-1:
+        a.PrevTrades += n
+    }
+}
@@ -0,0 +1,72 @@
+// CLOB/storage/activity_test.go
+package storage
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestAccountActivityWindows(t *testing.T) {
+    const length = 10
+    var a AccountActivity
+    a.RecordOrder(5, length)
+    a.RecordOrder(15, length)
+    if a.Window != 1 || a.Orders != 1 || a.PrevOrders != 1 {
+        t.Fatalf("activity %+v, want one order in each window", a)
+    }
+
+    // Trades settled late land in the window they happened in
+    a.RecordTrades(2, 8, length)
+    a.RecordTrades(3, 12, length)
+    if a.PrevTrades != 2 || a.Trades != 3 {
+        t.Fatalf("activity %+v, want 2 previous and 3 current trades", a)
+    }
+    if got := a.At(25, length); got.RollingOrders() != 1 || got.RollingTrades() != 3 {
+        t.Fatalf("activity a window later %+v, want the current window only", got)
+    }
+    if got := a.At(35, length); got.RollingOrders() != 0 || got.RollingTrades() != 0 {
+        t.Fatalf("activity two windows later %+v, want nothing", got)
+    }
+    if a.Window != 1 {
+        t.Fatal("At moved the counters")
+    }
+
+    // Trades of a later window roll the counters first
+    a.RecordTrades(1, 21, length)
+    if a.Window != 2 || a.Trades != 1 || a.PrevOrders != 1 || a.PrevTrades != 3 {
+        t.Fatalf("activity %+v after a later trade", a)
+    }
+    // and trades of a window no longer counted are dropped
+    a.RecordTrades(4, 5, length)
+    if a.RollingTrades() != 4 {
+        t.Fatalf("rolling trades %d, want 4", a.RollingTrades())
+    }
+}
+
+func TestAccountMarketMarshal(t *testing.T) {
+    want := &AccountMarket{
+        Reservations: []Reservation{
+            {OrderID: "a", ClientOrderID: "client", Side: Buy, Amount: 30},
+            {OrderID: "b", Side: Sell, Amount: 2},
+        },
+        Activity: AccountActivity{Window: 7, Orders: 3, Trades: 1, PrevOrders: 9, PrevTrades: 2},
+    }
+    b, err := want.Marshal()
+    if err != nil {
+        t.Fatal(err)
+    }
+    got, err := UnmarshalAccountMarket(b)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Fatalf("unmarshalled %+v, want %+v", got, want)
+    }
+
+    if !(&AccountMarket{}).Empty() || want.Empty() {
+        t.Fatal("wrong Empty")
+    }
+    if (&AccountMarket{Activity: AccountActivity{Orders: 1}}).Empty() {
+        t.Fatal("a record with activity is empty")
+    }
+}
@@ -0,0 +1,92 @@
+// CLOB/storage/assets.go
+package storage
+
+import (
+    "context"
+    "errors"
+
+    "github.com/ava-labs/avalanchego/database"
+    "github.com/ava-labs/avalanchego/ids"
+    "github.com/ava-labs/hypersdk/chain"
+    "github.com/ava-labs/hypersdk/codec"
+    "github.com/ava-labs/hypersdk/consts"
+    "github.com/ava-labs/hypersdk/crypto"
+)
+
+// Errors
+var (
+    ErrAssetNotFound = errors.New("asset not found")
+)
+
+// Asset holds the metadata of a native asset. The asset ID is the ID of the
+// transaction that created it.
+type Asset struct {
+    Symbol   []byte           `json:"symbol"`
+    Decimals uint8            `json:"decimals"`
+    Metadata []byte           `json:"metadata"`
+    Supply   uint64           `json:"supply"`
+    Owner    crypto.PublicKey `json:"owner"` // Only the owner may mint
+}
+
+// Marshal encodes the asset for storage
+func (a *Asset) Marshal() ([]byte, error) {
+    p := codec.NewWriter(consts.MaxInt)
+    p.PackBytes(a.Symbol)
+    p.PackByte(a.Decimals)
+    p.PackBytes(a.Metadata)
+    p.PackUint64(a.Supply)
+    p.PackPublicKey(a.Owner)
+    return p.Bytes(), p.Err()
+}
+
+// UnmarshalAsset decodes an asset previously encoded with Marshal
+func UnmarshalAsset(b []byte) (*Asset, error) {
+    var a Asset
+    p := codec.NewReader(b, consts.MaxInt)
+    p.UnpackBytes(MaxSymbolSize, true, &a.Symbol)
+    a.Decimals = p.UnpackByte()
+    p.UnpackBytes(MaxAssetMetadataSize, false, &a.Metadata)
+    a.Supply = p.UnpackUint64(false)
+    p.UnpackPublicKey(false, &a.Owner)
+    return &a, p.Err()
+}
+
+// Asset limits
+const (
+    MaxSymbolSize        = 8
+    MaxAssetMetadataSize = 256
+    MaxDecimals          = 18
+)
+
+// GetAsset returns the metadata of [asset], or ErrAssetNotFound
+func GetAsset(ctx context.Context, db chain.Database, asset ids.ID) (*Asset, error) {
+    v, err := db.GetValue(ctx, AssetKey(asset))
+    if errors.Is(err, database.ErrNotFound) {
+        return nil, ErrAssetNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+    return UnmarshalAsset(v)
+}
+
+// GetAssetFromState returns the metadata of [asset] from accepted state
+func GetAssetFromState(ctx context.Context, f ReadState, asset ids.ID) (*Asset, error) {
+    values, errs := f(ctx, [][]byte{AssetKey(asset)})
+    if errors.Is(errs[0], database.ErrNotFound) {
+        return nil, ErrAssetNotFound
+    }
+    if errs[0] != nil {
+        return nil, errs[0]
+    }
+    return UnmarshalAsset(values[0])
+}
+
+// SetAsset stores the metadata of [asset]
+func SetAsset(ctx context.Context, db chain.Database, asset ids.ID, a *Asset) error {
+    v, err := a.Marshal()
+    if err != nil {
+        return err
+    }
+    return db.Insert(ctx, AssetKey(asset), v)
+}
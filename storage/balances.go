@@ -0,0 +1,243 @@
+// CLOB/storage/balances.go
+package storage
+
+import (
+    "context"
+    "encoding/binary"
+    "errors"
+    "fmt"
+
+    "github.com/ava-labs/avalanchego/database"
+    "github.com/ava-labs/avalanchego/ids"
+    smath "github.com/ava-labs/avalanchego/utils/math"
+    "github.com/ava-labs/hypersdk/chain"
+    "github.com/ava-labs/hypersdk/consts"
+    "github.com/ava-labs/hypersdk/crypto"
+)
+
+// NativeAsset is the asset used to pay transaction fees
+var NativeAsset = ids.Empty
+
+// Errors
+var (
+    ErrInsufficientBalance = errors.New("insufficient balance")
+)
+
+// ReadState reads a batch of keys from the latest accepted state. It matches
+// the signature of the VM's ReadState so RPC handlers can query balances and
+// assets without a chain.Database.
+type ReadState func(context.Context, [][]byte) ([][]byte, []error)
+
+// GetBalance returns the balance of [pk] in [asset], or 0 if it has none
+func GetBalance(
+    ctx context.Context,
+    db chain.Database,
+    pk crypto.PublicKey,
+    asset ids.ID,
+) (uint64, error) {
+    v, err := db.GetValue(ctx, BalanceKey(pk, asset))
+    if errors.Is(err, database.ErrNotFound) {
+        return 0, nil
+    }
+    if err != nil {
+        return 0, err
+    }
+    return binary.BigEndian.Uint64(v), nil
+}
+
+// GetBalanceFromState returns the balance of [pk] in [asset] from accepted state
+func GetBalanceFromState(
+    ctx context.Context,
+    f ReadState,
+    pk crypto.PublicKey,
+    asset ids.ID,
+) (uint64, error) {
+    values, errs := f(ctx, [][]byte{BalanceKey(pk, asset)})
+    if errors.Is(errs[0], database.ErrNotFound) {
+        return 0, nil
+    }
+    if errs[0] != nil {
+        return 0, errs[0]
+    }
+    return binary.BigEndian.Uint64(values[0]), nil
+}
+
+// SetBalance stores the balance of [pk] in [asset]. A zero balance removes
+// the key so that empty accounts don't take up state.
+func SetBalance(
+    ctx context.Context,
+    db chain.Database,
+    pk crypto.PublicKey,
+    asset ids.ID,
+    balance uint64,
+) error {
+    k := BalanceKey(pk, asset)
+    if balance == 0 {
+        return db.Remove(ctx, k)
+    }
+    v := make([]byte, consts.Uint64Len)
+    binary.BigEndian.PutUint64(v, balance)
+    return db.Insert(ctx, k, v)
+}
+
+// AddBalance credits [amount] of [asset] to [pk]
+func AddBalance(
+    ctx context.Context,
+    db chain.Database,
+    pk crypto.PublicKey,
+    asset ids.ID,
+    amount uint64,
+) error {
+    bal, err := GetBalance(ctx, db, pk, asset)
+    if err != nil {
+        return err
+    }
+    nbal, err := smath.Add64(bal, amount)
+    if err != nil {
+        return fmt.Errorf(
+            "%w: could not add balance (asset=%s, bal=%d, amount=%d)",
+            err, asset, bal, amount,
+        )
+    }
+    return SetBalance(ctx, db, pk, asset, nbal)
+}
+
+// SubtractBalance debits [amount] of [asset] from [pk]
+func SubtractBalance(
+    ctx context.Context,
+    db chain.Database,
+    pk crypto.PublicKey,
+    asset ids.ID,
+    amount uint64,
+) error {
+    bal, err := GetBalance(ctx, db, pk, asset)
+    if err != nil {
+        return err
+    }
+    if bal < amount {
+        return fmt.Errorf(
+            "%w: could not subtract balance (asset=%s, bal=%d, amount=%d)",
+            ErrInsufficientBalance, asset, bal, amount,
+        )
+    }
+    return SetBalance(ctx, db, pk, asset, bal-amount)
+}
+
+// GetReserved returns the balance of [pk] in [asset] reserved by its orders
+func GetReserved(
+    ctx context.Context,
+    db chain.Database,
+    pk crypto.PublicKey,
+    asset ids.ID,
+) (uint64, error) {
+    v, err := db.GetValue(ctx, ReservedKey(pk, asset))
+    if errors.Is(err, database.ErrNotFound) {
+        return 0, nil
+    }
+    if err != nil {
+        return 0, err
+    }
+    return binary.BigEndian.Uint64(v), nil
+}
+
+// GetReservedFromState returns the balance of [pk] in [asset] reserved by
+// its orders, from accepted state
+func GetReservedFromState(
+    ctx context.Context,
+    f ReadState,
+    pk crypto.PublicKey,
+    asset ids.ID,
+) (uint64, error) {
+    values, errs := f(ctx, [][]byte{ReservedKey(pk, asset)})
+    if errors.Is(errs[0], database.ErrNotFound) {
+        return 0, nil
+    }
+    if errs[0] != nil {
+        return 0, errs[0]
+    }
+    return binary.BigEndian.Uint64(values[0]), nil
+}
+
+// GetAvailableBalance returns the balance of [pk] in [asset] that is not
+// reserved by its orders. Fees are paid out of the total balance, so a
+// reservation may come to exceed it, in which case nothing is available.
+func GetAvailableBalance(
+    ctx context.Context,
+    db chain.Database,
+    pk crypto.PublicKey,
+    asset ids.ID,
+) (uint64, error) {
+    bal, err := GetBalance(ctx, db, pk, asset)
+    if err != nil {
+        return 0, err
+    }
+    reserved, err := GetReserved(ctx, db, pk, asset)
+    if err != nil {
+        return 0, err
+    }
+    if reserved >= bal {
+        return 0, nil
+    }
+    return bal - reserved, nil
+}
+
+// setReserved stores the reserved balance of [pk] in [asset], removing the
+// key once nothing is reserved
+func setReserved(
+    ctx context.Context,
+    db chain.Database,
+    pk crypto.PublicKey,
+    asset ids.ID,
+    reserved uint64,
+) error {
+    k := ReservedKey(pk, asset)
+    if reserved == 0 {
+        return db.Remove(ctx, k)
+    }
+    v := make([]byte, consts.Uint64Len)
+    binary.BigEndian.PutUint64(v, reserved)
+    return db.Insert(ctx, k, v)
+}
+
+// AddReserved reserves [amount] more of the balance of [pk] in [asset]
+func AddReserved(
+    ctx context.Context,
+    db chain.Database,
+    pk crypto.PublicKey,
+    asset ids.ID,
+    amount uint64,
+) error {
+    reserved, err := GetReserved(ctx, db, pk, asset)
+    if err != nil {
+        return err
+    }
+    nreserved, err := smath.Add64(reserved, amount)
+    if err != nil {
+        return fmt.Errorf(
+            "%w: could not add reservation (asset=%s, reserved=%d, amount=%d)",
+            err, asset, reserved, amount,
+        )
+    }
+    return setReserved(ctx, db, pk, asset, nreserved)
+}
+
+// SubtractReserved releases [amount] of the balance of [pk] in [asset]
+func SubtractReserved(
+    ctx context.Context,
+    db chain.Database,
+    pk crypto.PublicKey,
+    asset ids.ID,
+    amount uint64,
+) error {
+    reserved, err := GetReserved(ctx, db, pk, asset)
+    if err != nil {
+        return err
+    }
+    if reserved < amount {
+        return fmt.Errorf(
+            "%w: could not release reservation (asset=%s, reserved=%d, amount=%d)",
+            ErrInsufficientBalance, asset, reserved, amount,
+        )
+    }
+    return setReserved(ctx, db, pk, asset, reserved-amount)
+}
@@ -0,0 +1,97 @@
+// CLOB/storage/cancel_timers.go
+package storage
+
+import (
+    "bytes"
+    "sort"
+
+    "github.com/ava-labs/hypersdk/crypto"
+)
+
+// CancelTimer is an account's dead-man's switch: unless it is renewed, every
+// resting order of Owner is expired by the first block at or past Height or
+// Timestamp, whichever is set (both may be).
+type CancelTimer struct {
+    Owner     crypto.PublicKey `json:"owner"`
+    Height    uint64           `json:"height"`    // 0 if unset
+    Timestamp int64            `json:"timestamp"` // 0 if unset
+}
+
+// Due reports whether the timer fires in a block at [height] and [timestamp]
+func (t *CancelTimer) Due(height uint64, timestamp int64) bool {
+    return (t.Height != 0 && height >= t.Height) || (t.Timestamp != 0 && timestamp >= t.Timestamp)
+}
+
+// CancelTimers holds the dead-man's switches of every account. Like the
+// books, it is copied on write: a block modifies a Clone, never the timers
+// of its parent.
+type CancelTimers struct {
+    timers map[crypto.PublicKey]CancelTimer
+}
+
+// NewCancelTimers creates an empty set of timers
+func NewCancelTimers() *CancelTimers {
+    return &CancelTimers{timers: make(map[crypto.PublicKey]CancelTimer)}
+}
+
+// Clone returns a copy of the timers that can be modified independently
+func (c *CancelTimers) Clone() *CancelTimers {
+    clone := &CancelTimers{timers: make(map[crypto.PublicKey]CancelTimer, len(c.timers))}
+    for owner, timer := range c.timers {
+        clone.timers[owner] = timer
+    }
+    return clone
+}
+
+// Get returns the timer of [owner], or false if none is set
+func (c *CancelTimers) Get(owner crypto.PublicKey) (CancelTimer, bool) {
+    timer, ok := c.timers[owner]
+    return timer, ok
+}
+
+// Set arms, renews or, when neither deadline is set, disarms the timer of
+// its owner
+func (c *CancelTimers) Set(timer CancelTimer) {
+    if timer.Height == 0 && timer.Timestamp == 0 {
+        delete(c.timers, timer.Owner)
+        return
+    }
+    c.timers[timer.Owner] = timer
+}
+
+// Remove disarms the timer of [owner]
+func (c *CancelTimers) Remove(owner crypto.PublicKey) {
+    delete(c.timers, owner)
+}
+
+// Len returns the number of armed timers
+func (c *CancelTimers) Len() int {
+    return len(c.timers)
+}
+
+// Due returns the timers that fire in a block at [height] and [timestamp],
+// sorted by owner so they are processed in the same order on every node
+func (c *CancelTimers) Due(height uint64, timestamp int64) []CancelTimer {
+    var due []CancelTimer
+    for _, timer := range c.timers {
+        if timer.Due(height, timestamp) {
+            due = append(due, timer)
+        }
+    }
+    sort.Slice(due, func(i, j int) bool {
+        return bytes.Compare(due[i].Owner[:], due[j].Owner[:]) < 0
+    })
+    return due
+}
+
+// All returns every armed timer, sorted by owner
+func (c *CancelTimers) All() []CancelTimer {
+    all := make([]CancelTimer, 0, len(c.timers))
+    for _, timer := range c.timers {
+        all = append(all, timer)
+    }
+    sort.Slice(all, func(i, j int) bool {
+        return bytes.Compare(all[i].Owner[:], all[j].Owner[:]) < 0
+    })
+    return all
+}
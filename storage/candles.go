@@ -0,0 +1,202 @@
+// CLOB/storage/candles.go
+package storage
+
+import (
+    "context"
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "fmt"
+
+    "github.com/ava-labs/avalanchego/database"
+    "github.com/ava-labs/hypersdk/consts"
+)
+
+// Resolution is the length of a candle in seconds
+type Resolution int64
+
+// Supported candle resolutions
+const (
+    Minute      Resolution = 60
+    FiveMinutes Resolution = 5 * 60
+    Hour        Resolution = 60 * 60
+    Day         Resolution = 24 * 60 * 60
+)
+
+// Resolutions are the resolutions candles are aggregated at
+var Resolutions = []Resolution{Minute, FiveMinutes, Hour, Day}
+
+var ErrUnknownResolution = errors.New("unknown resolution")
+
+// String returns the name of the resolution, e.g. "5m"
+func (r Resolution) String() string {
+    switch {
+    case r%Day == 0:
+        return fmt.Sprintf("%dd", r/Day)
+    case r%Hour == 0:
+        return fmt.Sprintf("%dh", r/Hour)
+    case r%Minute == 0:
+        return fmt.Sprintf("%dm", r/Minute)
+    default:
+        return fmt.Sprintf("%ds", r)
+    }
+}
+
+// ParseResolution parses the name of a supported resolution
+func ParseResolution(s string) (Resolution, error) {
+    for _, r := range Resolutions {
+        if r.String() == s {
+            return r, nil
+        }
+    }
+    return 0, fmt.Errorf("%w: %s", ErrUnknownResolution, s)
+}
+
+// Start returns the start of the candle of resolution [r] containing
+// [timestamp]
+func (r Resolution) Start(timestamp int64) int64 {
+    return timestamp - timestamp%int64(r)
+}
+
+// Candle is an OHLCV bar of the fills of a market over a period. Fills are
+// placed by the timestamp of their block.
+type Candle struct {
+    Market      string     `json:"market"`
+    Resolution  Resolution `json:"resolution"`
+    Start       int64      `json:"start"` // Inclusive, a multiple of Resolution
+    Open        float64    `json:"open"`
+    High        float64    `json:"high"`
+    Low         float64    `json:"low"`
+    Close       float64    `json:"close"`
+    Volume      float64    `json:"volume"`       // In the base asset
+    QuoteVolume float64    `json:"quote_volume"` // In the quote asset
+    Trades      uint64     `json:"trades"`
+}
+
+// NewCandle returns the empty candle of [market] at resolution [r] that
+// contains [timestamp]
+func NewCandle(market string, r Resolution, timestamp int64) *Candle {
+    return &Candle{Market: market, Resolution: r, Start: r.Start(timestamp)}
+}
+
+// Add adds [fill] to the candle. Fills must be added in block order.
+func (c *Candle) Add(fill *Fill) {
+    if c.Trades == 0 {
+        c.Open, c.High, c.Low = fill.Price, fill.Price, fill.Price
+    }
+    if fill.Price > c.High {
+        c.High = fill.Price
+    }
+    if fill.Price < c.Low {
+        c.Low = fill.Price
+    }
+    c.Close = fill.Price
+    c.Volume += fill.Quantity
+    c.QuoteVolume += fill.Quantity * fill.Price
+    c.Trades++
+}
+
+// CandleKey returns the metadata key of the candle of [market] at
+// resolution [r] starting at [start]
+// [candlePrefix] + [market] + [resolution] + [start]
+func CandleKey(market string, r Resolution, start int64) []byte {
+    return binary.BigEndian.AppendUint64(candleSeriesPrefix(market, r), uint64(start))
+}
+
+// candleSeriesPrefix returns the prefix of the candles of [market] at
+// resolution [r]
+func candleSeriesPrefix(market string, r Resolution) []byte {
+    k := make([]byte, 0, consts.ByteLen+consts.Uint16Len+len(market)+consts.Int64Len*2)
+    k = appendString(append(k, candlePrefix), market)
+    return binary.BigEndian.AppendUint64(k, uint64(r))
+}
+
+// candlesBuiltKey marks that the candle index covers every indexed fill. It
+// is shorter than any candle key, so it never shows up in their scans.
+var candlesBuiltKey = []byte{candlePrefix}
+
+// StoreCandle writes [c], replacing any previous version of it
+func StoreCandle(_ context.Context, db database.KeyValueWriter, c *Candle) error {
+    v, err := json.Marshal(c)
+    if err != nil {
+        return err
+    }
+    return db.Put(CandleKey(c.Market, c.Resolution, c.Start), v)
+}
+
+// GetCandle returns the candle of [market] at resolution [r] starting at
+// [start], or false if no fill happened in it
+func GetCandle(_ context.Context, db database.KeyValueReader, market string, r Resolution, start int64) (*Candle, bool, error) {
+    v, err := db.Get(CandleKey(market, r, start))
+    if errors.Is(err, database.ErrNotFound) {
+        return nil, false, nil
+    }
+    if err != nil {
+        return nil, false, err
+    }
+    var c Candle
+    if err := json.Unmarshal(v, &c); err != nil {
+        return nil, false, err
+    }
+    return &c, true, nil
+}
+
+// GetCandles returns up to [limit] candles of [market] at resolution [r]
+// starting in [from, to], oldest first. Periods without fills have no
+// candle. A [limit] of 0 returns every candle.
+func GetCandles(
+    _ context.Context,
+    db database.Iteratee,
+    market string,
+    r Resolution,
+    from int64,
+    to int64,
+    limit int,
+) ([]*Candle, error) {
+    it := db.NewIteratorWithStartAndPrefix(CandleKey(market, r, r.Start(from)), candleSeriesPrefix(market, r))
+    defer it.Release()
+
+    candles := []*Candle{}
+    for it.Next() {
+        if limit > 0 && len(candles) >= limit {
+            break
+        }
+        var c Candle
+        if err := json.Unmarshal(it.Value(), &c); err != nil {
+            return nil, err
+        }
+        if c.Start > to {
+            break
+        }
+        candles = append(candles, &c)
+    }
+    return candles, it.Error()
+}
+
+// CandlesBuilt reports whether the candle index covers every indexed fill
+func CandlesBuilt(_ context.Context, db database.KeyValueReader) (bool, error) {
+    return db.Has(candlesBuiltKey)
+}
+
+// SetCandlesBuilt records that the candle index covers every indexed fill
+func SetCandlesBuilt(_ context.Context, db database.KeyValueWriter) error {
+    return db.Put(candlesBuiltKey, []byte{})
+}
+
+// ForEachFill calls [f] on every indexed fill of every market, in block
+// order, stopping at the first error
+func ForEachFill(_ context.Context, db database.Iteratee, f func(*Fill) error) error {
+    it := db.NewIteratorWithPrefix([]byte{timeFillPrefix})
+    defer it.Release()
+
+    for it.Next() {
+        var fill Fill
+        if err := json.Unmarshal(it.Value(), &fill); err != nil {
+            return err
+        }
+        if err := f(&fill); err != nil {
+            return err
+        }
+    }
+    return it.Error()
+}
@@ -0,0 +1,103 @@
+// CLOB/storage/candles_test.go
+package storage
+
+import (
+    "context"
+    "testing"
+
+    "github.com/ava-labs/avalanchego/database/memdb"
+)
+
+func TestResolution(t *testing.T) {
+    tests := []struct {
+        r     Resolution
+        name  string
+        at    int64
+        start int64
+    }{
+        {Minute, "1m", 119, 60},
+        {Minute, "1m", 120, 120},
+        {FiveMinutes, "5m", 599, 300},
+        {Hour, "1h", 7_199, 3_600},
+        {Day, "1d", 86_400, 86_400},
+    }
+    for _, tt := range tests {
+        if tt.r.String() != tt.name {
+            t.Fatalf("resolution %d is named %s, want %s", tt.r, tt.r.String(), tt.name)
+        }
+        if r, err := ParseResolution(tt.name); err != nil || r != tt.r {
+            t.Fatalf("parsed %s as %d (%v), want %d", tt.name, r, err, tt.r)
+        }
+        if start := tt.r.Start(tt.at); start != tt.start {
+            t.Fatalf("%s candle of %d starts at %d, want %d", tt.name, tt.at, start, tt.start)
+        }
+    }
+    if _, err := ParseResolution("2m"); err == nil {
+        t.Fatal("parsed an unsupported resolution")
+    }
+}
+
+func TestCandleBucketing(t *testing.T) {
+    ctx := context.Background()
+    db := memdb.New()
+
+    // Fills of three minutes, the last one after a minute without trades
+    fills := []Fill{
+        {Market: "test", Price: 10, Quantity: 1, Timestamp: 60},
+        {Market: "test", Price: 12, Quantity: 2, Timestamp: 61},
+        {Market: "test", Price: 9, Quantity: 1, Timestamp: 100},
+        {Market: "test", Price: 11, Quantity: 3, Timestamp: 119},
+        {Market: "test", Price: 13, Quantity: 1, Timestamp: 120},
+        {Market: "test", Price: 8, Quantity: 1, Timestamp: 240},
+    }
+    candles := make(map[int64]*Candle)
+    var starts []int64
+    for i := range fills {
+        start := Minute.Start(fills[i].Timestamp)
+        if candles[start] == nil {
+            candles[start] = NewCandle("test", Minute, fills[i].Timestamp)
+            starts = append(starts, start)
+        }
+        candles[start].Add(&fills[i])
+    }
+    for _, start := range starts {
+        if err := StoreCandle(ctx, db, candles[start]); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    want := Candle{Market: "test", Resolution: Minute, Start: 60, Open: 10, High: 12, Low: 9, Close: 11, Volume: 7, QuoteVolume: 10 + 24 + 9 + 33, Trades: 4}
+    got, found, err := GetCandle(ctx, db, "test", Minute, 60)
+    if err != nil || !found {
+        t.Fatalf("candle not found: %v", err)
+    }
+    if *got != want {
+        t.Fatalf("candle %+v, want %+v", *got, want)
+    }
+
+    // Periods without fills have no candle, and ranges select by start
+    if _, found, _ := GetCandle(ctx, db, "test", Minute, 180); found {
+        t.Fatal("found a candle without fills")
+    }
+    all, err := GetCandles(ctx, db, "test", Minute, 0, 1_000, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(all) != 3 || all[0].Start != 60 || all[1].Start != 120 || all[2].Start != 240 {
+        t.Fatalf("got %d candles, want those starting at 60, 120 and 240", len(all))
+    }
+    // A range starting mid-candle includes that candle
+    some, err := GetCandles(ctx, db, "test", Minute, 130, 239, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(some) != 1 || some[0].Start != 120 {
+        t.Fatalf("got %d candles, want the one starting at 120", len(some))
+    }
+    if limited, _ := GetCandles(ctx, db, "test", Minute, 0, 1_000, 2); len(limited) != 2 {
+        t.Fatalf("got %d candles, want 2", len(limited))
+    }
+    if other, _ := GetCandles(ctx, db, "other", Minute, 0, 1_000, 0); len(other) != 0 {
+        t.Fatalf("got %d candles of another market", len(other))
+    }
+}
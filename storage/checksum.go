@@ -0,0 +1,102 @@
+// CLOB/storage/checksum.go
+package storage
+
+import (
+    "context"
+    "encoding/binary"
+    "errors"
+    "hash/crc32"
+    "math"
+    "strconv"
+
+    "github.com/ava-labs/avalanchego/database"
+    "github.com/ava-labs/hypersdk/consts"
+)
+
+// ChecksumLevels is how many levels of each side the book checksum covers
+const ChecksumLevels = 25
+
+// Checksum returns the CRC32 (IEEE) of the top ChecksumLevels [bids] and
+// [asks], best price first and not bucketed. Levels are written alternately
+// (bid 0, ask 0, bid 1, ask 1, ...) as "price:quantity", with both numbers
+// in the shortest decimal form that round-trips (Go's strconv 'f', -1), and
+// joined by ":". A side that runs out of levels is skipped. Clients keeping
+// a local book can compute the same value to check that they are in sync.
+//
+// The string is plain ASCII with no spaces, no leading or trailing ':' and
+// no exponent, whole numbers have no decimal point and fractions no trailing
+// zeros. For example, bids of 2 at 10.5, 0.001 at 10 and 4 at 9.75 and asks
+// of 1.25 at 11 and 3 at 11.5 give
+//
+//	10.5:2:11:1.25:10:0.001:11.5:3:9.75:4
+//
+// whose checksum is 3952356766. An empty book checksums the empty string, 0.
+func Checksum(bids []DepthLevel, asks []DepthLevel) uint32 {
+    var buf []byte
+    for i := 0; i < ChecksumLevels; i++ {
+        for _, side := range [][]DepthLevel{bids, asks} {
+            if i >= len(side) {
+                continue
+            }
+            if len(buf) > 0 {
+                buf = append(buf, ':')
+            }
+            buf = strconv.AppendFloat(buf, side[i].Price, 'f', -1, 64)
+            buf = append(buf, ':')
+            buf = strconv.AppendFloat(buf, side[i].Quantity, 'f', -1, 64)
+        }
+    }
+    return crc32.ChecksumIEEE(buf)
+}
+
+// BookChecksum is the checksum of a market's book after a block
+type BookChecksum struct {
+    Market   string `json:"market"`
+    Height   uint64 `json:"height"`   // Height of the last block that changed the book
+    Sequence uint64 `json:"sequence"` // Sequence of the book at Height
+    Checksum uint32 `json:"checksum"`
+}
+
+const checksumValueLen = consts.Uint64Len*2 + consts.Uint32Len
+
+// ChecksumKey returns the metadata key of the checksum of [market] at
+// [height]. Heights are stored inverted so that iterating from a height
+// finds the latest checksum at or below it first.
+// [checksumPrefix] + [market] + [^height]
+func ChecksumKey(market string, height uint64) []byte {
+    k := make([]byte, 0, consts.ByteLen+consts.Uint16Len+len(market)+consts.Uint64Len)
+    k = appendString(append(k, checksumPrefix), market)
+    return binary.BigEndian.AppendUint64(k, math.MaxUint64-height)
+}
+
+// StoreChecksum records the checksum of a book after a block that changed it
+func StoreChecksum(_ context.Context, db database.KeyValueWriter, c *BookChecksum) error {
+    v := make([]byte, 0, checksumValueLen)
+    v = binary.BigEndian.AppendUint64(v, c.Height)
+    v = binary.BigEndian.AppendUint64(v, c.Sequence)
+    v = binary.BigEndian.AppendUint32(v, c.Checksum)
+    return db.Put(ChecksumKey(c.Market, c.Height), v)
+}
+
+// GetChecksum returns the checksum of [market] as of [height]: that of the
+// last block at or below [height] that changed the book. It returns false if
+// the book never changed since genesis by then.
+func GetChecksum(_ context.Context, db database.Iteratee, market string, height uint64) (*BookChecksum, bool, error) {
+    prefix := appendString([]byte{checksumPrefix}, market)
+    it := db.NewIteratorWithStartAndPrefix(ChecksumKey(market, height), prefix)
+    defer it.Release()
+
+    if !it.Next() {
+        return nil, false, it.Error()
+    }
+    v := it.Value()
+    if len(v) != checksumValueLen {
+        return nil, false, errors.New("invalid checksum record")
+    }
+    return &BookChecksum{
+        Market:   market,
+        Height:   binary.BigEndian.Uint64(v),
+        Sequence: binary.BigEndian.Uint64(v[consts.Uint64Len:]),
+        Checksum: binary.BigEndian.Uint32(v[consts.Uint64Len*2:]),
+    }, true, nil
+}
@@ -0,0 +1,35 @@
+// CLOB/storage/checksum_test.go
+package storage
+
+import (
+    "hash/crc32"
+    "testing"
+)
+
+func TestChecksumGolden(t *testing.T) {
+    bids := []DepthLevel{{Price: 10.5, Quantity: 2}, {Price: 10, Quantity: 0.001}, {Price: 9.75, Quantity: 4}}
+    asks := []DepthLevel{{Price: 11, Quantity: 1.25}, {Price: 11.5, Quantity: 3}}
+
+    // The layout documented on Checksum
+    const layout = "10.5:2:11:1.25:10:0.001:11.5:3:9.75:4"
+    if want := crc32.ChecksumIEEE([]byte(layout)); Checksum(bids, asks) != want {
+        t.Fatalf("checksum %d, want %d of %q", Checksum(bids, asks), want, layout)
+    }
+    // and its value, which must never change as clients depend on it
+    if got := Checksum(bids, asks); got != 3952356766 {
+        t.Fatalf("checksum %d, want 3952356766", got)
+    }
+
+    if got := Checksum(nil, nil); got != 0 {
+        t.Fatalf("checksum of an empty book %d, want 0", got)
+    }
+    // Only the top ChecksumLevels levels count
+    deep := make([]DepthLevel, ChecksumLevels+1)
+    for i := range deep {
+        deep[i] = DepthLevel{Price: float64(100 - i), Quantity: 1}
+    }
+    top := Checksum(deep[:ChecksumLevels], nil)
+    if got := Checksum(deep, nil); got != top {
+        t.Fatalf("checksum %d counts levels past the top %d, want %d", got, ChecksumLevels, top)
+    }
+}
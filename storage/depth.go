@@ -0,0 +1,156 @@
+// CLOB/storage/depth.go
+package storage
+
+import (
+    "math"
+    "sort"
+)
+
+// DepthLevel is an aggregated price level of an L2 view of the book
+type DepthLevel struct {
+    Price    float64 `json:"price"`
+    Quantity float64 `json:"quantity"` // Total resting quantity
+    Orders   int     `json:"orders"`   // Number of resting orders
+}
+
+// Depth returns up to [levels] aggregated levels of each side, best price
+// first. A positive [bucket] groups prices into buckets of that size: bids
+// round down and asks round up, so a bucket never looks better than the
+// orders in it.
+func (s *BookSnapshot) Depth(levels int, bucket float64) ([]DepthLevel, []DepthLevel) {
+    return aggregate(s.Bids, levels, bucket, math.Floor), aggregate(s.Asks, levels, bucket, math.Ceil)
+}
+
+// aggregate merges [levels], sorted best-first, into at most [limit] depth
+// levels. With a positive [bucket], levels are grouped by the whole number
+// of buckets their price rounds to with [round], and each group is priced
+// once that number is known, so float error never splits a bucket.
+func aggregate(levels []LevelSnapshot, limit int, bucket float64, round func(float64) float64) []DepthLevel {
+    var (
+        depth   = make([]DepthLevel, 0, limit)
+        buckets = make([]int64, 0, limit) // Bucket of each depth level
+    )
+    for _, level := range levels {
+        if bucket <= 0 {
+            if len(depth) == limit {
+                break
+            }
+            depth = append(depth, DepthLevel{Price: level.Price, Quantity: level.Quantity, Orders: len(level.Orders)})
+            continue
+        }
+        // Levels are sorted, so a bucket's levels are consecutive
+        b := bucketOf(level.Price, bucket, round)
+        if n := len(depth); n > 0 && buckets[n-1] == b {
+            depth[n-1].Quantity += level.Quantity
+            depth[n-1].Orders += len(level.Orders)
+            continue
+        }
+        if len(depth) == limit {
+            break
+        }
+        depth = append(depth, DepthLevel{Quantity: level.Quantity, Orders: len(level.Orders)})
+        buckets = append(buckets, b)
+    }
+    for i, b := range buckets {
+        depth[i].Price = float64(b) * bucket
+    }
+    return depth
+}
+
+// bucketOf returns how many buckets of size [bucket] [price] rounds to with
+// [round]. A price within float error of a bucket boundary is on it, as
+// IsOnTick has it: 0.3 is 3 buckets of 0.1 although 0.3/0.1 is below 3.
+func bucketOf(price float64, bucket float64, round func(float64) float64) int64 {
+    ticks := price / bucket
+    if nearest := math.Round(ticks); math.Abs(ticks-nearest) < 1e-9 {
+        return int64(nearest)
+    }
+    return int64(round(ticks))
+}
+
+// DepthChanges returns the unbucketed levels of [next] that differ from those
+// of [prev], best price first. Levels that emptied are returned with no
+// quantity and no orders, so applying the changes to the depth of [prev]
+// yields that of [next].
+func DepthChanges(prev *BookSnapshot, next *BookSnapshot) ([]DepthLevel, []DepthLevel) {
+    bids := levelChanges(prev.Bids, next.Bids, func(a, b float64) bool { return a > b })
+    asks := levelChanges(prev.Asks, next.Asks, func(a, b float64) bool { return a < b })
+    return bids, asks
+}
+
+// levelChanges returns the levels of [next] that differ from [prev], and
+// the emptied levels of [prev], sorted with [better]
+func levelChanges(prev []LevelSnapshot, next []LevelSnapshot, better func(a, b float64) bool) []DepthLevel {
+    old := make(map[float64]DepthLevel, len(prev))
+    for _, level := range prev {
+        old[level.Price] = DepthLevel{Price: level.Price, Quantity: level.Quantity, Orders: len(level.Orders)}
+    }
+    var changes []DepthLevel
+    for _, level := range next {
+        d := DepthLevel{Price: level.Price, Quantity: level.Quantity, Orders: len(level.Orders)}
+        if o, ok := old[level.Price]; !ok || o != d {
+            changes = append(changes, d)
+        }
+        delete(old, level.Price)
+    }
+    for price := range old {
+        changes = append(changes, DepthLevel{Price: price})
+    }
+    sort.Slice(changes, func(i, j int) bool { return better(changes[i].Price, changes[j].Price) })
+    return changes
+}
+
+// DepthNearMid returns the resting quantity of each side priced within
+// [fraction] of the mid price, e.g. 0.01 for 1%. It is 0 for both sides
+// unless both have orders.
+func (s *BookSnapshot) DepthNearMid(fraction float64) (float64, float64) {
+    if len(s.Bids) == 0 || len(s.Asks) == 0 {
+        return 0, 0
+    }
+    mid := (s.Bids[0].Price + s.Asks[0].Price) / 2
+    var bid, ask float64
+    for _, level := range s.Bids {
+        if level.Price < mid*(1-fraction) {
+            break
+        }
+        bid += level.Quantity
+    }
+    for _, level := range s.Asks {
+        if level.Price > mid*(1+fraction) {
+            break
+        }
+        ask += level.Quantity
+    }
+    return bid, ask
+}
+
+// QueuedOrder is a resting order of an L3 view of the book, with its place
+// in the queue of its price level
+type QueuedOrder struct {
+    Order
+    Level    int // Index of the order's price level on its side, best first
+    Position int // Number of orders ahead of it at its price level
+}
+
+// L3 returns up to [limit] resting orders, skipping the first [offset]. Bids
+// come before asks, each side best price first and each level in time
+// priority, so consecutive calls page through the book in a stable order.
+func (s *BookSnapshot) L3(offset int, limit int) []QueuedOrder {
+    orders := make([]QueuedOrder, 0, limit)
+    for _, side := range [][]LevelSnapshot{s.Bids, s.Asks} {
+        for i, level := range side {
+            if offset >= len(level.Orders) {
+                offset -= len(level.Orders)
+                continue
+            }
+            for j := offset; j < len(level.Orders); j++ {
+                if len(orders) == limit {
+                    return orders
+                }
+                orders = append(orders, QueuedOrder{Order: level.Orders[j], Level: i, Position: j})
+            }
+            offset = 0
+        }
+    }
+    return orders
+}
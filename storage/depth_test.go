@@ -0,0 +1,64 @@
+// CLOB/storage/depth_test.go
+package storage
+
+import (
+    "math"
+    "testing"
+)
+
+// testLevels returns one level per price, each holding [orders] orders of
+// quantity 1
+func testLevels(orders int, prices ...float64) []LevelSnapshot {
+    levels := make([]LevelSnapshot, len(prices))
+    for i, price := range prices {
+        levels[i] = LevelSnapshot{Price: price, Quantity: float64(orders), Orders: make([]Order, orders)}
+    }
+    return levels
+}
+
+// checkDepth fails the test unless [got] matches [want], comparing prices
+// within float error
+func checkDepth(t *testing.T, side string, got []DepthLevel, want []DepthLevel) {
+    t.Helper()
+    if len(got) != len(want) {
+        t.Fatalf("%s: %d levels %+v, want %+v", side, len(got), got, want)
+    }
+    for i := range got {
+        if math.Abs(got[i].Price-want[i].Price) > 1e-9 || got[i].Quantity != want[i].Quantity || got[i].Orders != want[i].Orders {
+            t.Fatalf("%s: level %d is %+v, want %+v", side, i, got[i], want[i])
+        }
+    }
+}
+
+func TestDepthAggregation(t *testing.T) {
+    s := &BookSnapshot{
+        Bids: testLevels(1, 10.9, 10.5, 10, 9.99, 8),
+        Asks: testLevels(2, 11, 11.01, 11.5, 12.2),
+    }
+
+    // Unbucketed, every level stands alone up to the limit
+    bids, asks := s.Depth(3, 0)
+    checkDepth(t, "bids", bids, []DepthLevel{{10.9, 1, 1}, {10.5, 1, 1}, {10, 1, 1}})
+    checkDepth(t, "asks", asks, []DepthLevel{{11, 2, 2}, {11.01, 2, 2}, {11.5, 2, 2}})
+
+    // Bids round down and asks up, merging the levels of a bucket
+    bids, asks = s.Depth(10, 1)
+    checkDepth(t, "bids", bids, []DepthLevel{{10, 3, 3}, {9, 1, 1}, {8, 1, 1}})
+    checkDepth(t, "asks", asks, []DepthLevel{{11, 2, 2}, {12, 4, 4}, {13, 2, 2}})
+
+    // The limit counts buckets, not the levels in them
+    bids, _ = s.Depth(1, 1)
+    checkDepth(t, "bids", bids, []DepthLevel{{10, 3, 3}})
+}
+
+func TestDepthBucketsOnFloatBoundaries(t *testing.T) {
+    // 0.3/0.1 and 0.7/0.1 fall just short of 3 and 7, and 0.1+0.2 just past
+    // 0.3, but all of them are on a bucket boundary
+    s := &BookSnapshot{
+        Bids: testLevels(1, 0.1+0.2, 0.3, 0.2),
+        Asks: testLevels(1, 0.7, 0.71),
+    }
+    bids, asks := s.Depth(10, 0.1)
+    checkDepth(t, "bids", bids, []DepthLevel{{0.3, 2, 2}, {0.2, 1, 1}})
+    checkDepth(t, "asks", asks, []DepthLevel{{0.7, 1, 1}, {0.8, 1, 1}})
+}
@@ -0,0 +1,133 @@
+// CLOB/storage/engine_state.go
+package storage
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "sort"
+
+    "github.com/ava-labs/avalanchego/database"
+    "github.com/ava-labs/avalanchego/ids"
+)
+
+// EngineState is what the matching engine needs, besides the resting orders,
+// to resume at the last accepted block after a restart. It is written in the
+// same batch as the rest of the metadata of the block.
+type EngineState struct {
+    BlockID   ids.ID            `json:"block_id"`
+    Height    uint64            `json:"height"`
+    Timestamp int64             `json:"timestamp"`
+    Sequences map[string]uint64 `json:"sequences"` // Sequence of the book of each market
+    Timers    []CancelTimer     `json:"timers"`
+}
+
+// RestingOrderKey returns the metadata key of an order resting in [market]
+func RestingOrderKey(market string, orderID string) []byte {
+    k := appendString([]byte{restingOrderPrefix}, market)
+    return append(k, orderID...)
+}
+
+// StoreRestingUpdate applies [update] to the stored resting orders: the order
+// is written while it rests and deleted once its status is final
+func StoreRestingUpdate(_ context.Context, db database.KeyValueWriterDeleter, update *OrderUpdate) error {
+    k := RestingOrderKey(update.Order.Market, update.Order.ID)
+    if update.Status.Final() {
+        return db.Delete(k)
+    }
+    v, err := json.Marshal(&update.Order)
+    if err != nil {
+        return err
+    }
+    return db.Put(k, v)
+}
+
+// StoreOrderBook writes every resting order of [book], e.g. for the books
+// created at genesis
+func StoreOrderBook(_ context.Context, db database.KeyValueWriter, book *OrderBook) error {
+    for _, order := range book.OrderMap {
+        v, err := json.Marshal(order)
+        if err != nil {
+            return err
+        }
+        if err := db.Put(RestingOrderKey(order.Market, order.ID), v); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// StoreEngineState records the engine state at the last accepted block
+func StoreEngineState(_ context.Context, db database.KeyValueWriter, state *EngineState) error {
+    v, err := json.Marshal(state)
+    if err != nil {
+        return err
+    }
+    return db.Put([]byte{engineStatePrefix}, v)
+}
+
+// GetEngineState returns the engine state at the last accepted block, or
+// false if none was ever stored
+func GetEngineState(_ context.Context, db database.KeyValueReader) (*EngineState, bool, error) {
+    v, err := db.Get([]byte{engineStatePrefix})
+    if errors.Is(err, database.ErrNotFound) {
+        return nil, false, nil
+    }
+    if err != nil {
+        return nil, false, err
+    }
+    var state EngineState
+    if err := json.Unmarshal(v, &state); err != nil {
+        return nil, false, err
+    }
+    return &state, true, nil
+}
+
+// LoadOrderBooks rebuilds the book of every market from its stored resting
+// orders. Orders are queued by Priority, so time priority is the same as
+// before the restart, and no events are recorded.
+func LoadOrderBooks(
+    _ context.Context,
+    db database.Database,
+    markets []MarketInfo,
+    state *EngineState,
+) (map[string]*OrderBook, error) {
+    books := make(map[string]*OrderBook, len(markets))
+    for _, market := range markets {
+        book := NewMarketOrderBook(market)
+        book.Sequence = state.Sequences[market.ID]
+
+        var orders []*Order
+        it := db.NewIteratorWithPrefix(appendString([]byte{restingOrderPrefix}, market.ID))
+        for it.Next() {
+            var order Order
+            if err := json.Unmarshal(it.Value(), &order); err != nil {
+                it.Release()
+                return nil, fmt.Errorf("invalid resting order in market '%s': %w", market.ID, err)
+            }
+            orders = append(orders, &order)
+        }
+        err := it.Error()
+        it.Release()
+        if err != nil {
+            return nil, err
+        }
+
+        sort.Slice(orders, func(i, j int) bool { return orders[i].Priority < orders[j].Priority })
+        for _, order := range orders {
+            book.enqueue(order)
+        }
+        books[market.ID] = book
+    }
+    return books, nil
+}
+
+// LoadCancelTimers returns the timers recorded in [state]
+func LoadCancelTimers(state *EngineState) *CancelTimers {
+    timers := NewCancelTimers()
+    for _, timer := range state.Timers {
+        timers.Set(timer)
+    }
+    return timers
+}
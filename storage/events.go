@@ -0,0 +1,121 @@
+// CLOB/storage/events.go
+package storage
+
+import (
+    "github.com/ava-labs/avalanchego/ids"
+    "github.com/ava-labs/hypersdk/crypto"
+)
+
+// OrderStatus is the lifecycle state of an order
+type OrderStatus string
+
+// An order becomes open or partially filled while it rests in the book, and
+// ends filled, cancelled, expired or rejected. Orders are only indexed once
+// their block is accepted, so there is no status for a submitted order: until
+// then it is followed through its transaction (see the tx endpoint).
+const (
+    StatusOpen            OrderStatus = "open"             // Resting in the book, never matched
+    StatusPartiallyFilled OrderStatus = "partially_filled" // Resting in the book, partly matched
+    StatusFilled          OrderStatus = "filled"           // Fully matched
+    StatusCancelled       OrderStatus = "cancelled"        // Removed before being fully matched
+    StatusExpired         OrderStatus = "expired"          // Removed by the engine when it timed out
+    StatusRejected        OrderStatus = "rejected"         // Never entered the book
+)
+
+// Final reports whether no further transition can happen from the status
+func (s OrderStatus) Final() bool {
+    switch s {
+    case StatusFilled, StatusCancelled, StatusExpired, StatusRejected:
+        return true
+    default:
+        return false
+    }
+}
+
+// Reasons recorded with order updates
+const (
+    ReasonPlaced         = "placed in the book"
+    ReasonMatched        = "matched"
+    ReasonCancelled      = "cancelled by owner"
+    ReasonAmended        = "amended by owner"
+    ReasonCancelAfter    = "cancel-after deadline passed"
+    ReasonMarketUnfilled = "market order remainder had no liquidity"
+)
+
+// Fill is a trade between an incoming (taker) order and a resting (maker) order
+type Fill struct {
+    Market       string           `json:"market"`
+    TakerOrderID string           `json:"taker_order_id"`
+    MakerOrderID string           `json:"maker_order_id"`
+    Taker        crypto.PublicKey `json:"taker"`
+    Maker        crypto.PublicKey `json:"maker"`
+    TakerSide    Side             `json:"taker_side"`
+    Price        float64          `json:"price"` // Price of the maker order
+    Quantity     float64          `json:"quantity"`
+
+    // Set once the block containing the fill is accepted
+    TxID      ids.ID `json:"tx_id"`
+    Height    uint64 `json:"height"`
+    Timestamp int64  `json:"timestamp"`
+    Index     uint32 `json:"index"` // Position of the fill within its block
+}
+
+// OrderUpdate records the state of an order right after it changed
+type OrderUpdate struct {
+    Order    Order // Copy of the order; Quantity is what remains
+    Status   OrderStatus
+    Reason   string // Why the order moved to Status
+    Sequence uint64 // Sequence of the book after the update
+}
+
+// BookEvents are the fills and order updates produced by executing a tx
+type BookEvents struct {
+    Fills   []Fill
+    Updates []OrderUpdate
+}
+
+// Empty reports whether no event was recorded
+func (e *BookEvents) Empty() bool {
+    return len(e.Fills) == 0 && len(e.Updates) == 0
+}
+
+// Append adds the events of [other] after those of e
+func (e *BookEvents) Append(other BookEvents) {
+    e.Fills = append(e.Fills, other.Fills...)
+    e.Updates = append(e.Updates, other.Updates...)
+}
+
+// SettleTrade records a trade between the incoming [taker] order and the
+// resting [maker] order at the maker's price
+func (ob *OrderBook) SettleTrade(taker *Order, maker *Order, quantity float64) {
+    // In a real system, this would also update balances
+    for _, o := range []*Order{taker, maker} {
+        o.FilledQuantity += quantity
+        o.FilledValue += quantity * maker.Price
+    }
+    ob.events.Fills = append(ob.events.Fills, Fill{
+        Market:       ob.Market,
+        TakerOrderID: taker.ID,
+        MakerOrderID: maker.ID,
+        Taker:        taker.Owner,
+        Maker:        maker.Owner,
+        TakerSide:    taker.Side,
+        Price:        maker.Price,
+        Quantity:     quantity,
+    })
+}
+
+// RecordUpdate records the current state of [order]
+func (ob *OrderBook) RecordUpdate(order *Order, status OrderStatus, reason string) {
+    ob.Sequence++
+    update := OrderUpdate{Order: *order, Status: status, Reason: reason, Sequence: ob.Sequence}
+    update.Order.next, update.Order.prev = nil, nil
+    ob.events.Updates = append(ob.events.Updates, update)
+}
+
+// TakeEvents returns the events recorded since the last call and clears them
+func (ob *OrderBook) TakeEvents() BookEvents {
+    events := ob.events
+    ob.events = BookEvents{}
+    return events
+}
@@ -0,0 +1,278 @@
+// CLOB/storage/history.go
+package storage
+
+import (
+    "context"
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math"
+    "sort"
+
+    "github.com/ava-labs/avalanchego/database"
+    "github.com/ava-labs/hypersdk/consts"
+)
+
+var ErrHeightNotRetained = errors.New("height is no longer retained")
+
+// OrderEvent is an order update as indexed for replay: the record of the
+// order right after the update, and the sequence of its book
+type OrderEvent struct {
+    OrderRecord
+    Sequence uint64 `json:"sequence"`
+}
+
+// StoredBook is a stored copy of the resting orders of a market after a
+// block, the starting point to rebuild the book at later heights
+type StoredBook struct {
+    Market   string         `json:"market"`
+    Height   uint64         `json:"height"`
+    Sequence uint64         `json:"sequence"`
+    Orders   []*OrderRecord `json:"orders"` // Bids then asks, best price and time priority first
+}
+
+// NewStoredBook copies the resting orders of [s]
+func NewStoredBook(s *BookSnapshot) *StoredBook {
+    b := &StoredBook{Market: s.Market, Height: s.Height, Sequence: s.Sequence}
+    for _, o := range s.L3(0, s.NumOrders()) {
+        b.Orders = append(b.Orders, NewOrderRecord(&o.Order, o.RestingStatus(), ""))
+    }
+    return b
+}
+
+// OrderEventKey returns the metadata key of the [index]th order event of
+// the block at [height] in [market]
+// [orderEventPrefix] + [market] + [height] + [index]
+func OrderEventKey(market string, height uint64, index uint32) []byte {
+    k := make([]byte, 0, consts.ByteLen+consts.Uint16Len+len(market)+fillPosLen)
+    k = appendString(append(k, orderEventPrefix), market)
+    return appendFillPos(k, height, index)
+}
+
+// StoredBookKey returns the metadata key of the stored book of [market] at
+// [height]. Heights are inverted, like checksums, so the latest book at or
+// below a height is found first.
+// [storedBookPrefix] + [market] + [^height]
+func StoredBookKey(market string, height uint64) []byte {
+    k := make([]byte, 0, consts.ByteLen+consts.Uint16Len+len(market)+consts.Uint64Len)
+    k = appendString(append(k, storedBookPrefix), market)
+    return binary.BigEndian.AppendUint64(k, math.MaxUint64-height)
+}
+
+// StoreOrderEvent indexes [event] as the [index]th order event of its block
+func StoreOrderEvent(_ context.Context, db database.KeyValueWriter, event *OrderEvent, index uint32) error {
+    v, err := json.Marshal(event)
+    if err != nil {
+        return err
+    }
+    return db.Put(OrderEventKey(event.Market, event.Height, index), v)
+}
+
+// StoreStoredBook writes [b]
+func StoreStoredBook(_ context.Context, db database.KeyValueWriter, b *StoredBook) error {
+    v, err := json.Marshal(b)
+    if err != nil {
+        return err
+    }
+    return db.Put(StoredBookKey(b.Market, b.Height), v)
+}
+
+// GetStoredBook returns the latest stored book of [market] at or below
+// [height], or false if there is none
+func GetStoredBook(_ context.Context, db database.Iteratee, market string, height uint64) (*StoredBook, bool, error) {
+    prefix := appendString([]byte{storedBookPrefix}, market)
+    it := db.NewIteratorWithStartAndPrefix(StoredBookKey(market, height), prefix)
+    defer it.Release()
+
+    if !it.Next() {
+        return nil, false, it.Error()
+    }
+    var b StoredBook
+    if err := json.Unmarshal(it.Value(), &b); err != nil {
+        return nil, false, err
+    }
+    return &b, true, nil
+}
+
+// PruneOrderHistory deletes the stored books and order events of [market]
+// that are not needed to rebuild the book at [height] or later
+func PruneOrderHistory(ctx context.Context, db database.Iteratee, batch database.KeyValueDeleter, market string, height uint64) error {
+    base, found, err := GetStoredBook(ctx, db, market, height)
+    if err != nil || !found || base.Height == 0 {
+        return err
+    }
+
+    // Stored books older than base sort after it
+    prefix := appendString([]byte{storedBookPrefix}, market)
+    it := db.NewIteratorWithStartAndPrefix(StoredBookKey(market, base.Height-1), prefix)
+    for it.Next() {
+        if err := batch.Delete(it.Key()); err != nil {
+            it.Release()
+            return err
+        }
+    }
+    err = it.Error()
+    it.Release()
+    if err != nil {
+        return err
+    }
+
+    // Events up to base are part of it
+    prefix = appendString([]byte{orderEventPrefix}, market)
+    end := OrderEventKey(market, base.Height, math.MaxUint32)
+    it = db.NewIteratorWithPrefix(prefix)
+    defer it.Release()
+    for it.Next() {
+        if string(it.Key()) > string(end) {
+            break
+        }
+        if err := batch.Delete(it.Key()); err != nil {
+            return err
+        }
+    }
+    return it.Error()
+}
+
+// HistoricalLevel is a price level of a rebuilt book
+type HistoricalLevel struct {
+    Price    float64        `json:"price"`
+    Quantity float64        `json:"quantity"`
+    Orders   []*OrderRecord `json:"orders"` // In time priority
+}
+
+// HistoricalBook is the book of a market rebuilt as of a past height
+type HistoricalBook struct {
+    Market         string            `json:"market"`
+    Height         uint64            `json:"height"`
+    SnapshotHeight uint64            `json:"snapshot_height"` // Height of the stored book it was rebuilt from
+    Sequence       uint64            `json:"sequence"`
+    Bids           []HistoricalLevel `json:"bids"` // Best (highest) price first
+    Asks           []HistoricalLevel `json:"asks"` // Best (lowest) price first
+}
+
+// Depth returns the unbucketed depth of every level of the book
+func (b *HistoricalBook) Depth() ([]DepthLevel, []DepthLevel) {
+    depth := func(levels []HistoricalLevel) []DepthLevel {
+        d := make([]DepthLevel, len(levels))
+        for i, level := range levels {
+            d[i] = DepthLevel{Price: level.Price, Quantity: level.Quantity, Orders: len(level.Orders)}
+        }
+        return d
+    }
+    return depth(b.Bids), depth(b.Asks)
+}
+
+// RebuildOrderBook rebuilds the book of [market] as of [height] from the
+// latest stored book at or below it and the order events since. It fails
+// with ErrHeightNotRetained if the history was pruned.
+func RebuildOrderBook(ctx context.Context, db database.Iteratee, market string, height uint64) (*HistoricalBook, error) {
+    base, found, err := GetStoredBook(ctx, db, market, height)
+    if err != nil {
+        return nil, err
+    }
+    if !found {
+        return nil, fmt.Errorf("%w: %d", ErrHeightNotRetained, height)
+    }
+
+    r := &replay{
+        levels:   map[Side]map[float64][]*OrderRecord{Buy: {}, Sell: {}},
+        orders:   make(map[string]*OrderRecord),
+        sequence: base.Sequence,
+    }
+    for _, record := range base.Orders {
+        r.add(record)
+    }
+
+    prefix := appendString([]byte{orderEventPrefix}, market)
+    it := db.NewIteratorWithStartAndPrefix(OrderEventKey(market, base.Height+1, 0), prefix)
+    defer it.Release()
+    for it.Next() {
+        var event OrderEvent
+        if err := json.Unmarshal(it.Value(), &event); err != nil {
+            return nil, err
+        }
+        if event.Height > height {
+            break
+        }
+        r.apply(&event)
+    }
+    if err := it.Error(); err != nil {
+        return nil, err
+    }
+
+    return &HistoricalBook{
+        Market:         market,
+        Height:         height,
+        SnapshotHeight: base.Height,
+        Sequence:       r.sequence,
+        Bids:           r.side(Buy, func(a, b float64) bool { return a > b }),
+        Asks:           r.side(Sell, func(a, b float64) bool { return a < b }),
+    }, nil
+}
+
+// replay applies order events to the resting orders of a book, keeping the
+// time priority of each price level
+type replay struct {
+    levels   map[Side]map[float64][]*OrderRecord
+    orders   map[string]*OrderRecord
+    sequence uint64
+}
+
+// apply applies [event] the way the engine applied the update it records:
+// final orders leave the book, orders shrunk in place keep their priority
+// and orders (re)placed go to the back of their level
+func (r *replay) apply(event *OrderEvent) {
+    r.sequence = event.Sequence
+    prev, exists := r.orders[event.ID]
+    if exists {
+        if !event.Status.Final() && event.Price == prev.Price && event.Reason != ReasonPlaced {
+            *prev = event.OrderRecord
+            return
+        }
+        r.remove(prev)
+    }
+    if !event.Status.Final() {
+        record := event.OrderRecord
+        r.add(&record)
+    }
+}
+
+// add appends [record] to the back of its level
+func (r *replay) add(record *OrderRecord) {
+    level := r.levels[record.Side]
+    level[record.Price] = append(level[record.Price], record)
+    r.orders[record.ID] = record
+}
+
+// remove takes [record] out of its level
+func (r *replay) remove(record *OrderRecord) {
+    level := r.levels[record.Side]
+    queue := level[record.Price]
+    for i, o := range queue {
+        if o == record {
+            queue = append(queue[:i], queue[i+1:]...)
+            break
+        }
+    }
+    if len(queue) == 0 {
+        delete(level, record.Price)
+    } else {
+        level[record.Price] = queue
+    }
+    delete(r.orders, record.ID)
+}
+
+// side returns the levels of [side] sorted with [better]
+func (r *replay) side(side Side, better func(a, b float64) bool) []HistoricalLevel {
+    levels := make([]HistoricalLevel, 0, len(r.levels[side]))
+    for price, queue := range r.levels[side] {
+        level := HistoricalLevel{Price: price, Orders: queue}
+        for _, o := range queue {
+            level.Quantity += o.Quantity
+        }
+        levels = append(levels, level)
+    }
+    sort.Slice(levels, func(i, j int) bool { return better(levels[i].Price, levels[j].Price) })
+    return levels
+}
@@ -0,0 +1,113 @@
+// CLOB/storage/history_test.go
+package storage
+
+import (
+    "context"
+    "fmt"
+    "reflect"
+    "testing"
+    "time"
+
+    "github.com/ava-labs/avalanchego/database"
+    "github.com/ava-labs/avalanchego/database/memdb"
+    "github.com/ava-labs/avalanchego/ids"
+)
+
+// indexUpdates stores the updates recorded by [book] as the order events of
+// the block at [height], the same way the indexer does
+func indexUpdates(t *testing.T, db database.KeyValueWriter, book *OrderBook, height uint64) {
+    t.Helper()
+    for i, update := range book.TakeEvents().Updates {
+        record := NewOrderRecord(&update.Order, update.Status, update.Reason)
+        record.Height = height
+        event := &OrderEvent{OrderRecord: *record, Sequence: update.Sequence}
+        if err := StoreOrderEvent(context.Background(), db, event, uint32(i)); err != nil {
+            t.Fatal(err)
+        }
+    }
+}
+
+func TestRebuildOrderBookMatchesSnapshot(t *testing.T) {
+    ctx := context.Background()
+    db := memdb.New()
+    book := NewOrderBook()
+    book.Market = "test"
+    start := time.Unix(1_700_000_000, 0)
+
+    var orders []*Order
+    place := func(side Side, price float64, quantity float64) {
+        order := &Order{
+            ID:        fmt.Sprintf("order-%d", len(orders)),
+            Market:    book.Market,
+            Side:      side,
+            Price:     price,
+            Quantity:  quantity,
+            Timestamp: start.Add(time.Duration(len(orders)) * time.Second),
+            OrderType: Limit,
+        }
+        if err := book.AddLimitOrder(order); err != nil {
+            t.Fatal(err)
+        }
+        orders = append(orders, order)
+    }
+
+    // The book stored at height 0 is where every rebuild starts from
+    place(Buy, 99, 1)
+    place(Buy, 98, 2)
+    place(Sell, 101, 1)
+    book.TakeEvents()
+    snapshots := map[uint64]*BookSnapshot{0: book.Snapshot(ids.Empty, 0, 0)}
+    if err := StoreStoredBook(ctx, db, NewStoredBook(snapshots[0])); err != nil {
+        t.Fatal(err)
+    }
+
+    // Later blocks queue orders behind existing ones, so rebuilding must
+    // keep time priority, and take orders out of the middle of levels
+    for height := uint64(1); height <= 5; height++ {
+        place(Buy, 99, float64(height))
+        place(Sell, 101+float64(height%2), 1)
+        switch height {
+        case 2:
+            if err := book.CancelOrder(orders[0]); err != nil {
+                t.Fatal(err)
+            }
+        case 4:
+            if err := book.ExpireOrder(orders[2], ReasonCancelAfter); err != nil {
+                t.Fatal(err)
+            }
+        }
+        indexUpdates(t, db, book, height)
+        snapshots[height] = book.Snapshot(ids.Empty, height, 0)
+    }
+
+    for height, snapshot := range snapshots {
+        rebuilt, err := RebuildOrderBook(ctx, db, book.Market, height)
+        if err != nil {
+            t.Fatalf("height %d: %v", height, err)
+        }
+        if rebuilt.Sequence != snapshot.Sequence {
+            t.Errorf("height %d: rebuilt sequence %d, snapshot %d", height, rebuilt.Sequence, snapshot.Sequence)
+        }
+
+        bids, asks := snapshot.Depth(len(snapshot.Bids)+len(snapshot.Asks), 0)
+        rbids, rasks := rebuilt.Depth()
+        if !reflect.DeepEqual(rbids, bids) || !reflect.DeepEqual(rasks, asks) {
+            t.Errorf("height %d: rebuilt depth %v %v, snapshot %v %v", height, rbids, rasks, bids, asks)
+        }
+
+        var want, got []string
+        for _, o := range snapshot.L3(0, snapshot.NumOrders()) {
+            want = append(want, o.Order.ID)
+        }
+        for _, side := range [][]HistoricalLevel{rebuilt.Bids, rebuilt.Asks} {
+            for _, level := range side {
+                for _, record := range level.Orders {
+                    got = append(got, record.ID)
+                }
+            }
+        }
+        if !reflect.DeepEqual(got, want) {
+            t.Errorf("height %d: rebuilt queue %v, snapshot %v", height, got, want)
+        }
+    }
+}
@@ -0,0 +1,81 @@
+// CLOB/storage/keys.go
+package storage
+
+import (
+    "github.com/ava-labs/avalanchego/ids"
+    "github.com/ava-labs/hypersdk/consts"
+    "github.com/ava-labs/hypersdk/crypto"
+)
+
+// State key prefixes. Every key stored in chain state starts with one of
+// these bytes so that different kinds of records can never collide.
+const (
+    balancePrefix       byte = 0x0
+    assetPrefix         byte = 0x1
+    marketPrefix        byte = 0x2
+    accountMarketPrefix byte = 0x3
+    cancelAfterPrefix   byte = 0x4
+    reservedPrefix      byte = 0x5
+)
+
+// BalanceKey returns the state key of the balance of [pk] in [asset]
+// [balancePrefix] + [pk] + [asset]
+func BalanceKey(pk crypto.PublicKey, asset ids.ID) []byte {
+    k := make([]byte, consts.ByteLen+crypto.PublicKeyLen+consts.IDLen)
+    k[0] = balancePrefix
+    copy(k[consts.ByteLen:], pk[:])
+    copy(k[consts.ByteLen+crypto.PublicKeyLen:], asset[:])
+    return k
+}
+
+// ReservedKey returns the state key of the balance of [pk] in [asset] that
+// its resting orders have reserved
+// [reservedPrefix] + [pk] + [asset]
+func ReservedKey(pk crypto.PublicKey, asset ids.ID) []byte {
+    k := make([]byte, consts.ByteLen+crypto.PublicKeyLen+consts.IDLen)
+    k[0] = reservedPrefix
+    copy(k[consts.ByteLen:], pk[:])
+    copy(k[consts.ByteLen+crypto.PublicKeyLen:], asset[:])
+    return k
+}
+
+// AssetKey returns the state key of the metadata of [asset]
+// [assetPrefix] + [asset]
+func AssetKey(asset ids.ID) []byte {
+    k := make([]byte, consts.ByteLen+consts.IDLen)
+    k[0] = assetPrefix
+    copy(k[consts.ByteLen:], asset[:])
+    return k
+}
+
+// MarketKey returns the state key of [market]. Order actions declare it so
+// that transactions on the same market are ordered with respect to each
+// other, while different markets can execute in parallel.
+// [marketPrefix] + [market]
+func MarketKey(market string) []byte {
+    k := make([]byte, consts.ByteLen+len(market))
+    k[0] = marketPrefix
+    copy(k[consts.ByteLen:], market)
+    return k
+}
+
+// AccountMarketKey returns the state key of the per-market record of [pk]
+// [accountMarketPrefix] + [pk] + [market]
+func AccountMarketKey(pk crypto.PublicKey, market string) []byte {
+    k := make([]byte, consts.ByteLen+crypto.PublicKeyLen+len(market))
+    k[0] = accountMarketPrefix
+    copy(k[consts.ByteLen:], pk[:])
+    copy(k[consts.ByteLen+crypto.PublicKeyLen:], market)
+    return k
+}
+
+// CancelAfterKey returns the state key of the dead-man's switch of [pk]. The
+// timer itself lives in the matching engine; the key serializes the txs that
+// set it.
+// [cancelAfterPrefix] + [pk]
+func CancelAfterKey(pk crypto.PublicKey) []byte {
+    k := make([]byte, consts.ByteLen+crypto.PublicKeyLen)
+    k[0] = cancelAfterPrefix
+    copy(k[consts.ByteLen:], pk[:])
+    return k
+}
@@ -0,0 +1,60 @@
+// CLOB/storage/markets.go
+package storage
+
+import (
+    "sort"
+    "sync"
+
+    "github.com/ava-labs/avalanchego/ids"
+)
+
+// The markets of the chain are fixed at genesis. StateKeys can read neither
+// state nor rules, so order payloads look the assets of their market up here
+// to declare the balances they check and reserve.
+var (
+    marketsLock sync.RWMutex
+    markets     map[string]MarketInfo
+)
+
+// RegisterMarkets records the markets of the chain, replacing any registered
+// before
+func RegisterMarkets(ms []MarketInfo) {
+    marketsLock.Lock()
+    defer marketsLock.Unlock()
+
+    markets = make(map[string]MarketInfo, len(ms))
+    for _, m := range ms {
+        markets[m.ID] = m
+    }
+}
+
+// LookupMarket returns the registered market [id], or false if there is none
+func LookupMarket(id string) (MarketInfo, bool) {
+    marketsLock.RLock()
+    defer marketsLock.RUnlock()
+
+    m, ok := markets[id]
+    return m, ok
+}
+
+// RegisteredMarkets returns every registered market, sorted by ID
+func RegisteredMarkets() []MarketInfo {
+    marketsLock.RLock()
+    defer marketsLock.RUnlock()
+
+    ms := make([]MarketInfo, 0, len(markets))
+    for _, m := range markets {
+        ms = append(ms, m)
+    }
+    sort.Slice(ms, func(i, j int) bool { return ms[i].ID < ms[j].ID })
+    return ms
+}
+
+// PaymentAsset returns the asset an order on [side] of the market pays
+// with: the quote asset for a buy, the base asset for a sell
+func (m MarketInfo) PaymentAsset(side Side) ids.ID {
+    if side == Buy {
+        return m.QuoteAsset
+    }
+    return m.BaseAsset
+}
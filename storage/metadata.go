@@ -0,0 +1,642 @@
+// CLOB/storage/metadata.go
+package storage
+
+import (
+    "context"
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+
+    "github.com/ava-labs/avalanchego/database"
+    "github.com/ava-labs/avalanchego/ids"
+    "github.com/ava-labs/hypersdk/consts"
+    "github.com/ava-labs/hypersdk/crypto"
+)
+
+// Metadata key prefixes. Metadata is derived from accepted blocks and lives
+// in its own database, separate from chain state.
+const (
+    txPrefix              byte = 0x0  // [txPrefix] + [txID]
+    orderPrefix           byte = 0x1  // [orderPrefix] + [orderID]
+    ownerOrderPrefix      byte = 0x2  // [ownerOrderPrefix] + [owner] + [orderID]
+    statusOrderPrefix     byte = 0x3  // [statusOrderPrefix] + [status] + [orderID]
+    marketFillPrefix      byte = 0x4  // [marketFillPrefix] + [market] + [height] + [index]
+    accountFillPrefix     byte = 0x5  // [accountFillPrefix] + [account] + [height] + [index]
+    timeFillPrefix        byte = 0x6  // [timeFillPrefix] + [timestamp] + [height] + [index]
+    orderBookStatePrefix  byte = 0x7  // [orderBookStatePrefix] + [market]
+    clientOrderPrefix     byte = 0x8  // [clientOrderPrefix] + [owner] + [market] + [clientOrderID]
+    batchResultsPrefix    byte = 0x9  // [batchResultsPrefix] + [txID]
+    cancelAllPrefix       byte = 0xa  // [cancelAllPrefix] + [txID]
+    checksumPrefix        byte = 0xb  // [checksumPrefix] + [market] + [^height]
+    marketTimeFillPrefix  byte = 0xc  // [marketTimeFillPrefix] + [market] + [timestamp] + [height] + [index]
+    accountTimeFillPrefix byte = 0xd  // [accountTimeFillPrefix] + [account] + [timestamp] + [height] + [index]
+    candlePrefix          byte = 0xe  // [candlePrefix] + [market] + [resolution] + [start]
+    orderEventPrefix      byte = 0xf  // [orderEventPrefix] + [market] + [height] + [index]
+    storedBookPrefix      byte = 0x10 // [storedBookPrefix] + [market] + [^height]
+    restingOrderPrefix    byte = 0x11 // [restingOrderPrefix] + [market] + [orderID]
+    engineStatePrefix     byte = 0x12 // [engineStatePrefix]
+)
+
+const txValueLen = consts.Int64Len + consts.BoolLen + consts.Uint64Len
+
+// fillPosLen is the length of the [height] + [index] suffix of fill keys
+const fillPosLen = consts.Uint64Len + consts.IntLen
+
+// OrderRecord is the indexed state of an order, kept after it leaves the book
+type OrderRecord struct {
+    ID        string           `json:"id"`
+    Market    string           `json:"market"`
+    Owner     crypto.PublicKey `json:"owner"`
+    Side      Side             `json:"side"`
+    OrderType OrderType        `json:"order_type"`
+    Price     float64          `json:"price"`
+    Quantity  float64          `json:"quantity"`  // Remaining quantity
+    Timestamp int64            `json:"timestamp"` // When the order was placed
+
+    ClientOrderID    string      `json:"client_order_id,omitempty"`
+    OriginalQuantity float64     `json:"original_quantity"`
+    FilledQuantity   float64     `json:"filled_quantity"`
+    AvgFillPrice     float64     `json:"avg_fill_price"` // 0 if never filled
+    Status           OrderStatus `json:"status"`
+    Reason           string      `json:"reason"` // Why the order moved to Status
+
+    TxID   ids.ID `json:"tx_id"`  // Last tx that changed the order
+    Height uint64 `json:"height"` // Height of that tx's block
+}
+
+// NewOrderRecord returns the record of [order] in [status]
+func NewOrderRecord(order *Order, status OrderStatus, reason string) *OrderRecord {
+    return &OrderRecord{
+        ID:               order.ID,
+        ClientOrderID:    order.ClientOrderID,
+        Market:           order.Market,
+        Owner:            order.Owner,
+        Side:             order.Side,
+        OrderType:        order.OrderType,
+        Price:            order.Price,
+        Quantity:         order.Quantity,
+        Timestamp:        order.Timestamp.Unix(),
+        OriginalQuantity: order.OriginalQuantity,
+        FilledQuantity:   order.FilledQuantity,
+        AvgFillPrice:     order.AvgFillPrice(),
+        Status:           status,
+        Reason:           reason,
+    }
+}
+
+// InstructionResult is the outcome of one instruction of a batch order
+// transaction
+type InstructionResult struct {
+    Kind          string `json:"kind"` // "place", "cancel" or "amend"
+    OrderID       string `json:"order_id,omitempty"`
+    ClientOrderID string `json:"client_order_id,omitempty"`
+    Success       bool   `json:"success"`
+    Error         string `json:"error,omitempty"`
+}
+
+// OrderBookState summarizes the indexed activity of a market
+type OrderBookState struct {
+    Market    string  `json:"market"`
+    Height    uint64  `json:"height"`     // Last block that changed the market
+    Timestamp int64   `json:"timestamp"`  // Timestamp of that block
+    NumFills  uint64  `json:"num_fills"`  // Fills since genesis
+    Volume    float64 `json:"volume"`     // Base quantity traded since genesis
+    LastPrice float64 `json:"last_price"` // Price of the latest fill, 0 if none
+}
+
+// TxKey returns the metadata key of the result of [txID]
+func TxKey(txID ids.ID) []byte {
+    k := make([]byte, consts.ByteLen+consts.IDLen)
+    k[0] = txPrefix
+    copy(k[consts.ByteLen:], txID[:])
+    return k
+}
+
+// OrderKey returns the metadata key of the record of [orderID]
+func OrderKey(orderID string) []byte {
+    k := make([]byte, consts.ByteLen+len(orderID))
+    k[0] = orderPrefix
+    copy(k[consts.ByteLen:], orderID)
+    return k
+}
+
+// OwnerOrderKey returns the key indexing [orderID] under its owner [pk]
+func OwnerOrderKey(pk crypto.PublicKey, orderID string) []byte {
+    k := make([]byte, consts.ByteLen+crypto.PublicKeyLen+len(orderID))
+    k[0] = ownerOrderPrefix
+    copy(k[consts.ByteLen:], pk[:])
+    copy(k[consts.ByteLen+crypto.PublicKeyLen:], orderID)
+    return k
+}
+
+// ClientOrderIndexKey returns the key mapping the client order ID [owner]
+// used in [market] to the canonical ID of the latest order that carried it
+func ClientOrderIndexKey(pk crypto.PublicKey, market string, clientOrderID string) []byte {
+    k := make([]byte, 0, consts.ByteLen+crypto.PublicKeyLen+consts.Uint16Len+len(market)+len(clientOrderID))
+    k = append(append(k, clientOrderPrefix), pk[:]...)
+    k = appendString(k, market)
+    return append(k, clientOrderID...)
+}
+
+// StatusOrderKey returns the key indexing [orderID] under its [status]
+func StatusOrderKey(status OrderStatus, orderID string) []byte {
+    return append(statusPrefix(status), orderID...)
+}
+
+func statusPrefix(status OrderStatus) []byte {
+    k := make([]byte, 0, consts.ByteLen+consts.Uint16Len+len(status))
+    k = append(k, statusOrderPrefix)
+    return appendString(k, string(status))
+}
+
+// appendString appends [s] prefixed with its length, so that a string can
+// never be mistaken for the prefix of a longer one in a range scan
+func appendString(k []byte, s string) []byte {
+    k = binary.BigEndian.AppendUint16(k, uint16(len(s)))
+    return append(k, s...)
+}
+
+func appendFillPos(k []byte, height uint64, index uint32) []byte {
+    k = binary.BigEndian.AppendUint64(k, height)
+    return binary.BigEndian.AppendUint32(k, index)
+}
+
+// MarketFillKey returns the key of a fill in the index of [market]
+func MarketFillKey(market string, height uint64, index uint32) []byte {
+    k := make([]byte, 0, consts.ByteLen+consts.Uint16Len+len(market)+fillPosLen)
+    k = appendString(append(k, marketFillPrefix), market)
+    return appendFillPos(k, height, index)
+}
+
+// AccountFillKey returns the key of a fill in the index of [pk]
+func AccountFillKey(pk crypto.PublicKey, height uint64, index uint32) []byte {
+    k := make([]byte, 0, consts.ByteLen+crypto.PublicKeyLen+fillPosLen)
+    k = append(append(k, accountFillPrefix), pk[:]...)
+    return appendFillPos(k, height, index)
+}
+
+// TimeFillKey returns the key of a fill in the index by time
+func TimeFillKey(timestamp int64, height uint64, index uint32) []byte {
+    k := make([]byte, 0, consts.ByteLen+consts.Int64Len+fillPosLen)
+    k = binary.BigEndian.AppendUint64(append(k, timeFillPrefix), uint64(timestamp))
+    return appendFillPos(k, height, index)
+}
+
+// MarketTimeFillKey returns the key of a fill in the index of [market] by
+// time
+func MarketTimeFillKey(market string, timestamp int64, height uint64, index uint32) []byte {
+    k := make([]byte, 0, consts.ByteLen+consts.Uint16Len+len(market)+consts.Int64Len+fillPosLen)
+    k = appendString(append(k, marketTimeFillPrefix), market)
+    k = binary.BigEndian.AppendUint64(k, uint64(timestamp))
+    return appendFillPos(k, height, index)
+}
+
+// AccountTimeFillKey returns the key of a fill in the index of [pk] by time
+func AccountTimeFillKey(pk crypto.PublicKey, timestamp int64, height uint64, index uint32) []byte {
+    k := make([]byte, 0, consts.ByteLen+crypto.PublicKeyLen+consts.Int64Len+fillPosLen)
+    k = append(append(k, accountTimeFillPrefix), pk[:]...)
+    k = binary.BigEndian.AppendUint64(k, uint64(timestamp))
+    return appendFillPos(k, height, index)
+}
+
+// OrderBookStateKey returns the key of the indexed state of [market]
+func OrderBookStateKey(market string) []byte {
+    k := make([]byte, consts.ByteLen+len(market))
+    k[0] = orderBookStatePrefix
+    copy(k[consts.ByteLen:], market)
+    return k
+}
+
+// BatchResultsKey returns the metadata key of the instruction results of
+// batch transaction [txID]
+func BatchResultsKey(txID ids.ID) []byte {
+    k := make([]byte, consts.ByteLen+consts.IDLen)
+    k[0] = batchResultsPrefix
+    copy(k[consts.ByteLen:], txID[:])
+    return k
+}
+
+// CancelAllKey returns the metadata key of the orders cancelled by cancel-all
+// transaction [txID]
+func CancelAllKey(txID ids.ID) []byte {
+    k := make([]byte, consts.ByteLen+consts.IDLen)
+    k[0] = cancelAllPrefix
+    copy(k[consts.ByteLen:], txID[:])
+    return k
+}
+
+// StoreTransaction records the result of an accepted transaction
+func StoreTransaction(
+    _ context.Context,
+    db database.KeyValueWriter,
+    txID ids.ID,
+    t int64,
+    success bool,
+    units uint64,
+) error {
+    v := make([]byte, txValueLen)
+    binary.BigEndian.PutUint64(v, uint64(t))
+    if success {
+        v[consts.Int64Len] = 1
+    }
+    binary.BigEndian.PutUint64(v[consts.Int64Len+consts.BoolLen:], units)
+    return db.Put(TxKey(txID), v)
+}
+
+// GetTransaction returns the result of an accepted transaction
+func GetTransaction(
+    _ context.Context,
+    db database.KeyValueReader,
+    txID ids.ID,
+) (bool, int64, bool, uint64, error) {
+    v, err := db.Get(TxKey(txID))
+    if errors.Is(err, database.ErrNotFound) {
+        return false, 0, false, 0, nil
+    }
+    if err != nil {
+        return false, 0, false, 0, err
+    }
+    t := int64(binary.BigEndian.Uint64(v))
+    success := v[consts.Int64Len] == 1
+    units := binary.BigEndian.Uint64(v[consts.Int64Len+consts.BoolLen:])
+    return true, t, success, units, nil
+}
+
+// StoreBatchResults records the outcome of every instruction of an accepted
+// batch transaction
+func StoreBatchResults(
+    _ context.Context,
+    db database.KeyValueWriter,
+    txID ids.ID,
+    results []*InstructionResult,
+) error {
+    v, err := json.Marshal(results)
+    if err != nil {
+        return err
+    }
+    return db.Put(BatchResultsKey(txID), v)
+}
+
+// GetBatchResults returns the outcome of every instruction of an accepted
+// batch transaction, or false if it was not indexed
+func GetBatchResults(
+    _ context.Context,
+    db database.KeyValueReader,
+    txID ids.ID,
+) ([]*InstructionResult, bool, error) {
+    v, err := db.Get(BatchResultsKey(txID))
+    if errors.Is(err, database.ErrNotFound) {
+        return nil, false, nil
+    }
+    if err != nil {
+        return nil, false, err
+    }
+    var results []*InstructionResult
+    if err := json.Unmarshal(v, &results); err != nil {
+        return nil, false, err
+    }
+    return results, true, nil
+}
+
+// StoreCancelled records the IDs of the orders cancelled by an accepted
+// cancel-all transaction
+func StoreCancelled(_ context.Context, db database.KeyValueWriter, txID ids.ID, orderIDs []string) error {
+    v, err := json.Marshal(orderIDs)
+    if err != nil {
+        return err
+    }
+    return db.Put(CancelAllKey(txID), v)
+}
+
+// GetCancelled returns the IDs of the orders cancelled by an accepted
+// cancel-all transaction, or false if it was not indexed
+func GetCancelled(_ context.Context, db database.KeyValueReader, txID ids.ID) ([]string, bool, error) {
+    v, err := db.Get(CancelAllKey(txID))
+    if errors.Is(err, database.ErrNotFound) {
+        return nil, false, nil
+    }
+    if err != nil {
+        return nil, false, err
+    }
+    orderIDs := []string{}
+    if err := json.Unmarshal(v, &orderIDs); err != nil {
+        return nil, false, err
+    }
+    return orderIDs, true, nil
+}
+
+// StoreOrder writes the record of an order and its owner and status
+// indexes. [prev] is the record being replaced, nil if the order is new; its
+// status index entry is removed when the status changes.
+func StoreOrder(
+    _ context.Context,
+    db database.KeyValueWriterDeleter,
+    prev *OrderRecord,
+    record *OrderRecord,
+) error {
+    v, err := json.Marshal(record)
+    if err != nil {
+        return err
+    }
+    if err := db.Put(OrderKey(record.ID), v); err != nil {
+        return err
+    }
+    if prev == nil {
+        if err := db.Put(OwnerOrderKey(record.Owner, record.ID), nil); err != nil {
+            return err
+        }
+        if record.ClientOrderID != "" {
+            k := ClientOrderIndexKey(record.Owner, record.Market, record.ClientOrderID)
+            if err := db.Put(k, []byte(record.ID)); err != nil {
+                return err
+            }
+        }
+    } else if prev.Status != record.Status {
+        if err := db.Delete(StatusOrderKey(prev.Status, prev.ID)); err != nil {
+            return err
+        }
+    }
+    return db.Put(StatusOrderKey(record.Status, record.ID), nil)
+}
+
+// OrderExists reports whether an order has ever been indexed
+func OrderExists(_ context.Context, db database.KeyValueReader, orderID string) (bool, error) {
+    return db.Has(OrderKey(orderID))
+}
+
+// GetOrder returns the indexed record of an order
+func GetOrder(_ context.Context, db database.KeyValueReader, orderID string) (*OrderRecord, error) {
+    v, err := db.Get(OrderKey(orderID))
+    if errors.Is(err, database.ErrNotFound) {
+        return nil, ErrOrderNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+    var record OrderRecord
+    if err := json.Unmarshal(v, &record); err != nil {
+        return nil, err
+    }
+    return &record, nil
+}
+
+// GetOrderByClientID returns the record of the latest order [pk] placed in
+// [market] with [clientOrderID]
+func GetOrderByClientID(
+    ctx context.Context,
+    db database.KeyValueReader,
+    pk crypto.PublicKey,
+    market string,
+    clientOrderID string,
+) (*OrderRecord, error) {
+    orderID, err := db.Get(ClientOrderIndexKey(pk, market, clientOrderID))
+    if errors.Is(err, database.ErrNotFound) {
+        return nil, ErrOrderNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+    return GetOrder(ctx, db, string(orderID))
+}
+
+// GetOrdersByAddress returns the records of every order placed by [pk]
+func GetOrdersByAddress(ctx context.Context, db database.Database, pk crypto.PublicKey) ([]*OrderRecord, error) {
+    prefix := make([]byte, consts.ByteLen+crypto.PublicKeyLen)
+    prefix[0] = ownerOrderPrefix
+    copy(prefix[consts.ByteLen:], pk[:])
+    return getIndexedOrders(ctx, db, prefix, 0)
+}
+
+// GetOrdersByStatus returns the records of up to [limit] orders in [status],
+// in order ID order. A [limit] of 0 returns every order.
+func GetOrdersByStatus(ctx context.Context, db database.Database, status OrderStatus, limit int) ([]*OrderRecord, error) {
+    return getIndexedOrders(ctx, db, statusPrefix(status), limit)
+}
+
+// getIndexedOrders loads the records of the orders whose index keys start
+// with [prefix], which must be followed directly by the order ID
+func getIndexedOrders(ctx context.Context, db database.Database, prefix []byte, limit int) ([]*OrderRecord, error) {
+    it := db.NewIteratorWithPrefix(prefix)
+    defer it.Release()
+
+    records := []*OrderRecord{}
+    for it.Next() {
+        if limit > 0 && len(records) >= limit {
+            break
+        }
+        record, err := GetOrder(ctx, db, string(it.Key()[len(prefix):]))
+        if err != nil {
+            return nil, err
+        }
+        records = append(records, record)
+    }
+    return records, it.Error()
+}
+
+// StoreFill writes [fill] to the market, account and time indexes. The fill
+// must have its block position (Timestamp, Height, Index) set.
+func StoreFill(_ context.Context, db database.KeyValueWriter, fill *Fill) error {
+    v, err := json.Marshal(fill)
+    if err != nil {
+        return err
+    }
+    keys := [][]byte{
+        MarketFillKey(fill.Market, fill.Height, fill.Index),
+        MarketTimeFillKey(fill.Market, fill.Timestamp, fill.Height, fill.Index),
+        AccountFillKey(fill.Taker, fill.Height, fill.Index),
+        AccountTimeFillKey(fill.Taker, fill.Timestamp, fill.Height, fill.Index),
+        TimeFillKey(fill.Timestamp, fill.Height, fill.Index),
+    }
+    if fill.Maker != fill.Taker {
+        keys = append(keys,
+            AccountFillKey(fill.Maker, fill.Height, fill.Index),
+            AccountTimeFillKey(fill.Maker, fill.Timestamp, fill.Height, fill.Index),
+        )
+    }
+    for _, k := range keys {
+        if err := db.Put(k, v); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// GetFillsByMarket returns up to [limit] fills of [market] in blocks
+// [fromHeight, toHeight], oldest first. A [limit] of 0 returns every fill.
+func GetFillsByMarket(
+    _ context.Context,
+    db database.Iteratee,
+    market string,
+    fromHeight uint64,
+    toHeight uint64,
+    limit int,
+) ([]*Fill, error) {
+    prefix := appendString([]byte{marketFillPrefix}, market)
+    return iterateFills(db, prefix, fillPosBounds(prefix, fromHeight, toHeight), limit)
+}
+
+// GetFillsByAccount returns up to [limit] fills [pk] took part in, as taker
+// or maker, in blocks [fromHeight, toHeight], oldest first. A [limit] of 0
+// returns every fill.
+func GetFillsByAccount(
+    _ context.Context,
+    db database.Iteratee,
+    pk crypto.PublicKey,
+    fromHeight uint64,
+    toHeight uint64,
+    limit int,
+) ([]*Fill, error) {
+    prefix := append([]byte{accountFillPrefix}, pk[:]...)
+    return iterateFills(db, prefix, fillPosBounds(prefix, fromHeight, toHeight), limit)
+}
+
+// GetFillsByTime returns up to [limit] fills of every market in blocks with
+// a timestamp in [from, to], oldest first. A [limit] of 0 returns every fill.
+func GetFillsByTime(_ context.Context, db database.Iteratee, from int64, to int64, limit int) ([]*Fill, error) {
+    prefix := []byte{timeFillPrefix}
+    start := binary.BigEndian.AppendUint64([]byte{timeFillPrefix}, uint64(from))
+    end := binary.BigEndian.AppendUint64([]byte{timeFillPrefix}, uint64(to))
+    return iterateFills(db, prefix, [2][]byte{start, end}, limit)
+}
+
+// FillPos is the position of a fill in the fill indexes
+type FillPos struct {
+    Timestamp int64
+    Height    uint64
+    Index     uint32
+}
+
+// Pos returns the position of [f] in the fill indexes
+func (f *Fill) Pos() FillPos {
+    return FillPos{f.Timestamp, f.Height, f.Index}
+}
+
+// FillRange bounds a paged scan of a fill index. With ByTime it covers the
+// fills of blocks with a timestamp in [FromTime, ToTime], otherwise those of
+// blocks in [FromHeight, ToHeight]. A non-nil Start resumes a previous scan
+// at that fill.
+type FillRange struct {
+    ByTime     bool
+    FromTime   int64
+    ToTime     int64
+    FromHeight uint64
+    ToHeight   uint64
+    Start      *FillPos
+}
+
+// GetMarketFillsPage returns up to [limit] fills of [market] in [r], oldest
+// first, and the position of the next one, or nil if there is none
+func GetMarketFillsPage(
+    _ context.Context,
+    db database.Iteratee,
+    market string,
+    r FillRange,
+    limit int,
+) ([]*Fill, *FillPos, error) {
+    if r.ByTime {
+        return fillPage(db, appendString([]byte{marketTimeFillPrefix}, market), r, limit)
+    }
+    return fillPage(db, appendString([]byte{marketFillPrefix}, market), r, limit)
+}
+
+// GetAccountFillsPage returns up to [limit] fills [pk] took part in, as
+// taker or maker, in [r], oldest first, and the position of the next one, or
+// nil if there is none
+func GetAccountFillsPage(
+    _ context.Context,
+    db database.Iteratee,
+    pk crypto.PublicKey,
+    r FillRange,
+    limit int,
+) ([]*Fill, *FillPos, error) {
+    if r.ByTime {
+        return fillPage(db, append([]byte{accountTimeFillPrefix}, pk[:]...), r, limit)
+    }
+    return fillPage(db, append([]byte{accountFillPrefix}, pk[:]...), r, limit)
+}
+
+// fillPage scans [r] of the index under [prefix], which is ordered by time
+// if r.ByTime and by height otherwise
+func fillPage(db database.Iteratee, prefix []byte, r FillRange, limit int) ([]*Fill, *FillPos, error) {
+    var bounds [2][]byte
+    if r.ByTime {
+        bounds[0] = binary.BigEndian.AppendUint64(append([]byte{}, prefix...), uint64(r.FromTime))
+        bounds[1] = binary.BigEndian.AppendUint64(append([]byte{}, prefix...), uint64(r.ToTime))
+        if r.Start != nil {
+            k := binary.BigEndian.AppendUint64(append([]byte{}, prefix...), uint64(r.Start.Timestamp))
+            bounds[0] = appendFillPos(k, r.Start.Height, r.Start.Index)
+        }
+    } else {
+        bounds = fillPosBounds(prefix, r.FromHeight, r.ToHeight)
+        if r.Start != nil {
+            bounds[0] = appendFillPos(append([]byte{}, prefix...), r.Start.Height, r.Start.Index)
+        }
+    }
+
+    // Read one more fill to tell whether there is a next page
+    fills, err := iterateFills(db, prefix, bounds, limit+1)
+    if err != nil || len(fills) <= limit {
+        return fills, nil, err
+    }
+    next := fills[limit].Pos()
+    return fills[:limit], &next, nil
+}
+
+// fillPosBounds returns the keys bounding fills in blocks [from, to] under
+// [prefix]
+func fillPosBounds(prefix []byte, from uint64, to uint64) [2][]byte {
+    start := binary.BigEndian.AppendUint64(append([]byte{}, prefix...), from)
+    end := binary.BigEndian.AppendUint64(append([]byte{}, prefix...), to)
+    return [2][]byte{start, end}
+}
+
+// iterateFills decodes the fills under [prefix] from bounds[0] until keys no
+// longer start with bounds[1] or sort after it
+func iterateFills(db database.Iteratee, prefix []byte, bounds [2][]byte, limit int) ([]*Fill, error) {
+    it := db.NewIteratorWithStartAndPrefix(bounds[0], prefix)
+    defer it.Release()
+
+    end := bounds[1]
+    fills := []*Fill{}
+    for it.Next() {
+        if limit > 0 && len(fills) >= limit {
+            break
+        }
+        k := it.Key()
+        if len(k) >= len(end) && string(k[:len(end)]) > string(end) {
+            break
+        }
+        var fill Fill
+        if err := json.Unmarshal(it.Value(), &fill); err != nil {
+            return nil, err
+        }
+        fills = append(fills, &fill)
+    }
+    return fills, it.Error()
+}
+
+// StoreOrderBookState writes the indexed state of a market
+func StoreOrderBookState(_ context.Context, db database.KeyValueWriter, state *OrderBookState) error {
+    v, err := json.Marshal(state)
+    if err != nil {
+        return err
+    }
+    return db.Put(OrderBookStateKey(state.Market), v)
+}
+
+// GetOrderBookState returns the indexed state of a market, or an empty state
+// if nothing has happened in it since genesis
+func GetOrderBookState(_ context.Context, db database.KeyValueReader, market string) (*OrderBookState, error) {
+    v, err := db.Get(OrderBookStateKey(market))
+    if errors.Is(err, database.ErrNotFound) {
+        return &OrderBookState{Market: market}, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var state OrderBookState
+    if err := json.Unmarshal(v, &state); err != nil {
+        return nil, err
+    }
+    return &state, nil
+}
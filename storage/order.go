@@ -1,7 +1,15 @@
 // CLOB/storage/order.go
 package storage
 
-import "time"
+import (
+    "time"
+
+    "github.com/ava-labs/avalanchego/ids"
+    "github.com/ava-labs/hypersdk/crypto"
+)
+
+// MaxClientOrderIDSize is the longest client order ID an order may carry
+const MaxClientOrderIDSize = 64
 
 // Side represents the side of an order: Buy or Sell
 type Side string
@@ -21,12 +29,56 @@ const (
 
 // Order represents an individual order in the order book
 type Order struct {
-    ID        string
+    ID        string // Canonical ID assigned by the VM, see NewOrderID
+    Market    string // ID of the market the order belongs to
     Side      Side
-    Price     float64       // 0 for market orders
+    Price     float64 // Worst price of a market order, 0 for none on a sell
     Quantity  float64
     Timestamp time.Time
     OrderType OrderType
-    next      *Order        // For linked list 
-    prev      *Order        // For linked list 
+    Owner     crypto.PublicKey // Account that placed the order
+
+    ClientOrderID string // Optional ID chosen by the owner, see ClientOrderKey
+
+    OriginalQuantity float64 // Quantity when the order was placed
+    FilledQuantity   float64 // Quantity matched so far
+    FilledValue      float64 // Sum of price * quantity over every fill
+
+    // Sequence of the book when the order joined the back of its price
+    // level. Orders of a level are queued by it, so a book restored from
+    // its resting orders keeps time priority.
+    Priority uint64
+
+    next *Order // For linked list
+    prev *Order // For linked list
+}
+
+// NewOrderID returns the canonical ID of the order placed by the action at
+// [index] of transaction [txID]. It only depends on the transaction, so every
+// node assigns the same ID and a client can compute it before submitting.
+func NewOrderID(txID ids.ID, index int) string {
+    return txID.Prefix(uint64(index)).String()
+}
+
+// AvgFillPrice returns the volume-weighted price the order was filled at, or
+// 0 if it has not been filled
+func (o *Order) AvgFillPrice() float64 {
+    if o.FilledQuantity == 0 {
+        return 0
+    }
+    return o.FilledValue / o.FilledQuantity
+}
+
+// RestingStatus returns the status of the order while it rests in the book
+func (o *Order) RestingStatus() OrderStatus {
+    if o.FilledQuantity > 0 {
+        return StatusPartiallyFilled
+    }
+    return StatusOpen
+}
+
+// Next returns the order queued behind this one at the same price level,
+// or nil if this is the last one
+func (o *Order) Next() *Order {
+    return o.next
 }
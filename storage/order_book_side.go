@@ -6,9 +6,9 @@ import "container/heap"
 // It maintains a collection of price levels and provides methods to manipulate
 // these levels using a heap data structure for efficient retrieval of the best price level.
 type OrderBookSide struct {
-    Side        Side                // Indicates whether this side is for buying or selling
+    Side        Side                    // Indicates whether this side is for buying or selling
     PriceLevels map[float64]*PriceLevel // A mapping of price to corresponding PriceLevel objects
-    Prices      heap.Interface       // A heap interface that can be either a BuyHeap or SellHeap
+    Prices      heap.Interface          // A heap interface that can be either a BuyHeap or SellHeap
 }
 
 // NewOrderBookSide creates a new instance of OrderBookSide.
@@ -16,6 +16,7 @@ type OrderBookSide struct {
 // If the side is Buy, a BuyHeap is initialized; if Sell, a SellHeap is initialized.
 // Parameters:
 //   - side: The side of the order book (Buy or Sell).
+//
 // Returns:
 //   - A pointer to the newly created OrderBookSide instance.
 func NewOrderBookSide(side Side) *OrderBookSide {
@@ -45,13 +46,28 @@ func (obs *OrderBookSide) AddPriceLevel(priceLevel *PriceLevel) {
 }
 
 // RemovePriceLevel removes a price level from the heap.
-// This operation is complex due to the nature of Go's heap implementation.
-// In practice, additional logic may be required to maintain the integrity of the heap.
+// The level is found through the heap index it keeps, so it can be removed
+// from anywhere in the heap. Levels that are not in the heap (e.g. popped
+// while being matched) are left alone.
 // Parameters:
 //   - priceLevel: A pointer to the PriceLevel to be removed from the heap.
 func (obs *OrderBookSide) RemovePriceLevel(priceLevel *PriceLevel) {
-    // This is a complex operation in Go's heap; it's simplified here
-    // In practice, you might need to implement additional logic
+    i := priceLevel.index
+    if i < 0 || i >= obs.Prices.Len() || obs.levelAt(i) != priceLevel {
+        return
+    }
+    heap.Remove(obs.Prices, i)
+}
+
+// levelAt returns the price level at position [i] of the heap
+func (obs *OrderBookSide) levelAt(i int) *PriceLevel {
+    switch h := obs.Prices.(type) {
+    case *BuyHeap:
+        return (*h)[i]
+    case *SellHeap:
+        return (*h)[i]
+    }
+    return nil
 }
 
 // PeekBestPriceLevel returns the best price level without removing it from the heap.
@@ -62,5 +78,5 @@ func (obs *OrderBookSide) PeekBestPriceLevel() *PriceLevel {
     if obs.Prices.Len() == 0 {
         return nil
     }
-    return (*obs.Prices).([]*PriceLevel)[0]
+    return obs.levelAt(0)
 }
@@ -2,9 +2,9 @@ package storage
 
 // OrderQueue represents a doubly linked list of orders
 type OrderQueue struct {
-    head *Order 
-    tail *Order 
-    Size int    // Number of orders in the queue
+    head *Order
+    tail *Order
+    Size int // Number of orders in the queue
 }
 
 // NewOrderQueue creates a new empty order queue
@@ -13,6 +13,12 @@ func NewOrderQueue() *OrderQueue {
     return &OrderQueue{}
 }
 
+// Head returns the order at the front of the queue without removing it
+// Returns nil if the queue is empty
+func (oq *OrderQueue) Head() *Order {
+    return oq.head
+}
+
 // Enqueue adds an order to the end of the queue
 // Takes a pointer to an Order as input
 // If the queue is empty, it sets both head and tail to the new order
@@ -5,4 +5,6 @@ package storage
 type PriceLevel struct {
     Price  float64
     Orders *OrderQueue
+
+    index int // Position in the heap of its side, -1 once popped
 }
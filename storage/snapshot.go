@@ -0,0 +1,120 @@
+// CLOB/storage/snapshot.go
+package storage
+
+import (
+    "sort"
+
+    "github.com/ava-labs/avalanchego/ids"
+    "github.com/ava-labs/hypersdk/crypto"
+)
+
+// LevelSnapshot is a read-only copy of a price level
+type LevelSnapshot struct {
+    Price    float64
+    Quantity float64 // Total resting quantity at this price
+    Orders   []Order // Orders in priority order
+}
+
+// BookSnapshot is an immutable, point-in-time copy of an order book as of an
+// accepted block. It holds no pointers into the live book, so it can be read
+// concurrently with matching.
+type BookSnapshot struct {
+    BlockID   ids.ID
+    Height    uint64
+    Timestamp int64
+
+    Market     string
+    BaseAsset  ids.ID
+    QuoteAsset ids.ID
+    Sequence   uint64 // Sequence of the book, see OrderBook.Sequence
+    Checksum   uint32 // Checksum of the top of the book, see Checksum
+
+    Bids []LevelSnapshot // Best (highest) price first
+    Asks []LevelSnapshot // Best (lowest) price first
+
+    orders       map[string]*Order         // Order ID to its copy in Bids/Asks
+    clientOrders map[ClientOrderKey]*Order // Client order ID to its copy in Bids/Asks
+}
+
+// Snapshot copies the order book into a BookSnapshot for the given block
+func (ob *OrderBook) Snapshot(blockID ids.ID, height uint64, timestamp int64) *BookSnapshot {
+    s := &BookSnapshot{
+        BlockID:    blockID,
+        Height:     height,
+        Timestamp:  timestamp,
+        Market:     ob.Market,
+        BaseAsset:  ob.BaseAsset,
+        QuoteAsset: ob.QuoteAsset,
+        Sequence:   ob.Sequence,
+        orders:     make(map[string]*Order, len(ob.OrderMap)),
+
+        clientOrders: make(map[ClientOrderKey]*Order, len(ob.ClientOrders)),
+    }
+    s.Bids = ob.Bids.snapshotLevels()
+    s.Asks = ob.Asks.snapshotLevels()
+    s.Checksum = Checksum(s.Depth(ChecksumLevels, 0))
+    for _, levels := range [][]LevelSnapshot{s.Bids, s.Asks} {
+        for i := range levels {
+            for j := range levels[i].Orders {
+                order := &levels[i].Orders[j]
+                s.orders[order.ID] = order
+                if order.ClientOrderID != "" {
+                    s.clientOrders[ClientOrderKey{order.Owner, order.ClientOrderID}] = order
+                }
+            }
+        }
+    }
+    return s
+}
+
+// snapshotLevels copies the non-empty price levels of obs, best price first
+func (obs *OrderBookSide) snapshotLevels() []LevelSnapshot {
+    levels := make([]LevelSnapshot, 0, len(obs.PriceLevels))
+    for price, level := range obs.PriceLevels {
+        if level.Orders.Size == 0 {
+            continue
+        }
+        ls := LevelSnapshot{
+            Price:  price,
+            Orders: make([]Order, 0, level.Orders.Size),
+        }
+        for o := level.Orders.Head(); o != nil; o = o.Next() {
+            order := *o
+            order.next, order.prev = nil, nil
+            ls.Orders = append(ls.Orders, order)
+            ls.Quantity += order.Quantity
+        }
+        levels = append(levels, ls)
+    }
+    sort.Slice(levels, func(i, j int) bool {
+        if obs.Side == Buy {
+            return levels[i].Price > levels[j].Price
+        }
+        return levels[i].Price < levels[j].Price
+    })
+    return levels
+}
+
+// GetOrder returns a copy of a resting order, or false if it is not in the book
+func (s *BookSnapshot) GetOrder(orderID string) (Order, bool) {
+    order, ok := s.orders[orderID]
+    if !ok {
+        return Order{}, false
+    }
+    return *order, true
+}
+
+// GetOrderByClientID returns a copy of the resting order [owner] gave
+// [clientOrderID], or false if there is none
+func (s *BookSnapshot) GetOrderByClientID(owner crypto.PublicKey, clientOrderID string) (Order, bool) {
+    order, ok := s.clientOrders[ClientOrderKey{owner, clientOrderID}]
+    if !ok {
+        return Order{}, false
+    }
+    return *order, true
+}
+
+// NumOrders returns the number of resting orders in the snapshot
+func (s *BookSnapshot) NumOrders() int {
+    return len(s.orders)
+}
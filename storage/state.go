@@ -2,20 +2,56 @@
 package storage
 
 import (
-    "container/heap"
     "errors"
+    "sort"
+
+    "github.com/ava-labs/avalanchego/ids"
+    "github.com/ava-labs/hypersdk/crypto"
 )
 
 // Errors
 var (
     ErrOrderNotFound = errors.New("order not found")
+    ErrOrderExists   = errors.New("order already exists")
+    ErrNotOrderOwner = errors.New("order belongs to another account")
+    ErrUnknownMarket = errors.New("unknown market")
+
+    ErrClientOrderIDExists = errors.New("client order ID already used by a resting order")
 )
 
-// OrderBook represents the entire order book
+// ClientOrderKey identifies an order by the ID its owner chose for it. Client
+// order IDs are unique per owner among the resting orders of a market, and
+// may be reused once the order leaves the book.
+type ClientOrderKey struct {
+    Owner         crypto.PublicKey
+    ClientOrderID string
+}
+
+// MarketInfo identifies an order book and the assets it trades
+type MarketInfo struct {
+    ID         string
+    BaseAsset  ids.ID // Asset quantities are denominated in
+    QuoteAsset ids.ID // Asset prices are denominated in
+}
+
+// OrderBook represents the entire order book of a market
 type OrderBook struct {
     Bids     *OrderBookSide
     Asks     *OrderBookSide
     OrderMap map[string]*Order // Maps Order ID to Order
+
+    ClientOrders map[ClientOrderKey]*Order              // Resting orders with a client order ID
+    OwnerOrders  map[crypto.PublicKey]map[string]*Order // Resting orders of each owner, by ID
+
+    // Sequence counts the changes made to the book. Every order update
+    // increments it, so two books with the same sequence are identical.
+    Sequence uint64
+
+    Market     string // ID of the market this book belongs to
+    BaseAsset  ids.ID // Asset quantities are denominated in
+    QuoteAsset ids.ID // Asset prices are denominated in
+
+    events BookEvents // Recorded since the last TakeEvents, never cloned
 }
 
 // NewOrderBook creates a new OrderBook
@@ -24,9 +60,21 @@ func NewOrderBook() *OrderBook {
         Bids:     NewOrderBookSide(Buy),
         Asks:     NewOrderBookSide(Sell),
         OrderMap: make(map[string]*Order),
+
+        ClientOrders: make(map[ClientOrderKey]*Order),
+        OwnerOrders:  make(map[crypto.PublicKey]map[string]*Order),
     }
 }
 
+// NewMarketOrderBook creates a new, empty OrderBook for a market
+func NewMarketOrderBook(market MarketInfo) *OrderBook {
+    ob := NewOrderBook()
+    ob.Market = market.ID
+    ob.BaseAsset = market.BaseAsset
+    ob.QuoteAsset = market.QuoteAsset
+    return ob
+}
+
 // GetOppositeSide returns the opposite side of the given side
 func (ob *OrderBook) GetOppositeSide(side Side) *OrderBookSide {
     if side == Buy {
@@ -37,6 +85,18 @@ func (ob *OrderBook) GetOppositeSide(side Side) *OrderBookSide {
 
 // AddLimitOrder adds a limit order to the appropriate side
 func (ob *OrderBook) AddLimitOrder(order *Order) error {
+    order.Priority = ob.Sequence + 1 // Sequence of the update recorded below
+    ob.enqueue(order)
+    if order.OriginalQuantity == 0 {
+        order.OriginalQuantity = order.Quantity + order.FilledQuantity
+    }
+    ob.RecordUpdate(order, order.RestingStatus(), ReasonPlaced)
+    return nil
+}
+
+// enqueue appends an order to its price level and registers it, without
+// recording any update
+func (ob *OrderBook) enqueue(order *Order) {
     side := ob.GetSide(order.Side)
     priceLevel, exists := side.PriceLevels[order.Price]
     if !exists {
@@ -48,11 +108,31 @@ func (ob *OrderBook) AddLimitOrder(order *Order) error {
         side.AddPriceLevel(priceLevel)
     }
     priceLevel.Orders.Enqueue(order)
-    return nil
+    ob.track(order)
 }
 
 // CancelOrder removes an order from the order book
 func (ob *OrderBook) CancelOrder(order *Order) error {
+    if err := ob.DetachOrder(order); err != nil {
+        return err
+    }
+    ob.RecordUpdate(order, StatusCancelled, ReasonCancelled)
+    return nil
+}
+
+// ExpireOrder removes an order from the order book on behalf of the engine,
+// e.g. when its owner's dead-man's switch fires
+func (ob *OrderBook) ExpireOrder(order *Order, reason string) error {
+    if err := ob.DetachOrder(order); err != nil {
+        return err
+    }
+    ob.RecordUpdate(order, StatusExpired, reason)
+    return nil
+}
+
+// DetachOrder takes a resting order out of the book without recording any
+// update, so the caller can re-place it (e.g. when it is amended)
+func (ob *OrderBook) DetachOrder(order *Order) error {
     side := ob.GetSide(order.Side)
     priceLevel, exists := side.PriceLevels[order.Price]
     if !exists {
@@ -60,7 +140,7 @@ func (ob *OrderBook) CancelOrder(order *Order) error {
     }
     // Remove the order from the queue
     priceLevel.Orders.Remove(order)
-    delete(ob.OrderMap, order.ID)
+    ob.RemoveOrder(order)
 
     // If the price level is empty, remove it
     if priceLevel.Orders.Size == 0 {
@@ -70,6 +150,60 @@ func (ob *OrderBook) CancelOrder(order *Order) error {
     return nil
 }
 
+// track registers a resting order in the lookup maps
+func (ob *OrderBook) track(order *Order) {
+    ob.OrderMap[order.ID] = order
+    if order.ClientOrderID != "" {
+        ob.ClientOrders[ClientOrderKey{order.Owner, order.ClientOrderID}] = order
+    }
+    owned, ok := ob.OwnerOrders[order.Owner]
+    if !ok {
+        owned = make(map[string]*Order)
+        ob.OwnerOrders[order.Owner] = owned
+    }
+    owned[order.ID] = order
+}
+
+// RemoveOrder removes an order that left its queue from the lookup maps
+func (ob *OrderBook) RemoveOrder(order *Order) {
+    delete(ob.OrderMap, order.ID)
+    key := ClientOrderKey{order.Owner, order.ClientOrderID}
+    if order.ClientOrderID != "" && ob.ClientOrders[key] == order {
+        delete(ob.ClientOrders, key)
+    }
+    if owned, ok := ob.OwnerOrders[order.Owner]; ok {
+        delete(owned, order.ID)
+        if len(owned) == 0 {
+            delete(ob.OwnerOrders, order.Owner)
+        }
+    }
+}
+
+// OrdersOf returns the resting orders of [owner], sorted by ID so callers
+// iterate them deterministically
+func (ob *OrderBook) OrdersOf(owner crypto.PublicKey) []*Order {
+    owned := ob.OwnerOrders[owner]
+    orders := make([]*Order, 0, len(owned))
+    for _, order := range owned {
+        orders = append(orders, order)
+    }
+    sort.Slice(orders, func(i, j int) bool {
+        return orders[i].ID < orders[j].ID
+    })
+    return orders
+}
+
+// LookupOrder finds a resting order by its canonical ID or, if [orderID] is
+// empty, by the client order ID [owner] gave it
+func (ob *OrderBook) LookupOrder(owner crypto.PublicKey, orderID string, clientOrderID string) (*Order, bool) {
+    if orderID != "" {
+        order, ok := ob.OrderMap[orderID]
+        return order, ok
+    }
+    order, ok := ob.ClientOrders[ClientOrderKey{owner, clientOrderID}]
+    return order, ok
+}
+
 // GetSide returns the OrderBookSide for the given side
 func (ob *OrderBook) GetSide(side Side) *OrderBookSide {
     if side == Buy {
@@ -77,3 +211,50 @@ func (ob *OrderBook) GetSide(side Side) *OrderBookSide {
     }
     return ob.Asks
 }
+
+// Clone returns a deep copy of the order book. Orders keep their queue
+// position, and the copy shares no memory with the original, so it can be
+// mutated (e.g. by a block being verified) without affecting readers of the
+// original.
+func (ob *OrderBook) Clone() *OrderBook {
+    clone := NewOrderBook()
+    clone.Market = ob.Market
+    clone.BaseAsset = ob.BaseAsset
+    clone.QuoteAsset = ob.QuoteAsset
+    clone.Sequence = ob.Sequence
+    ob.Bids.cloneInto(clone.Bids, clone)
+    ob.Asks.cloneInto(clone.Asks, clone)
+    return clone
+}
+
+// Assign replaces the contents of the book with those of [other], typically
+// a Clone that was modified speculatively. Events recorded by both are kept,
+// those of the book first.
+func (ob *OrderBook) Assign(other *OrderBook) {
+    events := ob.TakeEvents()
+    events.Append(other.TakeEvents())
+    *ob = *other
+    ob.events = events
+}
+
+// cloneInto copies every non-empty price level of obs into dst, registering
+// the copied orders in [book]
+func (obs *OrderBookSide) cloneInto(dst *OrderBookSide, book *OrderBook) {
+    for price, level := range obs.PriceLevels {
+        if level.Orders.Size == 0 {
+            continue
+        }
+        copied := &PriceLevel{
+            Price:  price,
+            Orders: NewOrderQueue(),
+        }
+        for o := level.Orders.Head(); o != nil; o = o.Next() {
+            order := *o
+            order.next, order.prev = nil, nil
+            copied.Orders.Enqueue(&order)
+            book.track(&order)
+        }
+        dst.PriceLevels[price] = copied
+        dst.AddPriceLevel(copied)
+    }
+}
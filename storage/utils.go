@@ -1,6 +1,8 @@
 // CLOB/storage/utils.go
 package storage
 
+import "math"
+
 // Min returns the minimum of two float64 numbers
 func Min(a, b float64) float64 {
     if a < b {
@@ -9,11 +11,14 @@ func Min(a, b float64) float64 {
     return b
 }
 
-// SettleTrade simulates the settlement of a trade between two orders
-func SettleTrade(order1 *Order, order2 *Order, quantity float64) {
-    // In a real system, this would update balances, notify parties, etc.
-    // For now, we can log the trade
-    println("Trade executed between", order1.ID, "and", order2.ID, "for", quantity, "units at price", order2.Price)
+// IsOnTick reports whether price is a whole multiple of tickSize.
+// A tickSize of 0 accepts any price.
+func IsOnTick(price, tickSize float64) bool {
+    if tickSize <= 0 {
+        return true
+    }
+    ticks := price / tickSize
+    return math.Abs(ticks-math.Round(ticks)) < 1e-9
 }
 
 // GetPriceComparator returns a comparison function based on the side
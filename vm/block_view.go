@@ -0,0 +1,485 @@
+// CLOB/vm/block_view.go
+
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"CLOB/actions"
+	"CLOB/genesis"
+	"CLOB/storage"
+
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+var (
+	ErrUnknownParent = errors.New("parent block is neither accepted nor verified")
+	ErrUnknownBlock  = errors.New("block has not been verified")
+	ErrTooManyTxs    = errors.New("block exceeds max block txs")
+	ErrWrongMarket   = errors.New("action touched a market it is not scoped to")
+)
+
+// BlockInfo identifies a block for the matching engine
+type BlockInfo struct {
+	ID        ids.ID
+	Parent    ids.ID
+	Height    uint64
+	Timestamp int64
+}
+
+// BlockTx is an engine action tagged with the transaction that carried it
+type BlockTx struct {
+	TxID   ids.ID
+	Action actions.Action
+}
+
+// BlockView is the speculative state of the order books after executing a
+// verified block on top of its parent. It is committed by AcceptBlock and
+// discarded by RejectBlock.
+type BlockView struct {
+	BlockInfo
+
+	Books   map[string]*storage.OrderBook // Books after the block, by market
+	Timers  *storage.CancelTimers         // Dead-man's switches after the block
+	Touched map[string]struct{}           // Markets the block modified
+	Txs     []BlockTx
+	Results []error              // Outcome of each tx, nil on success
+	Events  []storage.BookEvents // Fills and order updates of each tx
+
+	// Latencies are the time the engine took to execute each tx. Unlike
+	// everything else in the view they vary between nodes, so they are only
+	// fit for metrics.
+	Latencies []time.Duration
+
+	// PreEvents are the order updates of the block not caused by any tx,
+	// i.e. orders expired by dead-man's switches before the txs executed
+	PreEvents storage.BookEvents
+
+	// Snapshots of the touched books after the block, set once accepted
+	Snapshots Snapshots
+}
+
+// State returns the engine state after the block, the same State returns
+// once the view is accepted, so it can be persisted before the view is
+// committed
+func (v *BlockView) State() *storage.EngineState {
+	return engineState(v.Books, v.Timers, v.ID, v.Height, v.Timestamp)
+}
+
+// Only the block context exposes the dead-man's switches: they are shared by
+// every market, so actions setting them are not market-scoped and fail with
+// actions.ErrNoCancelTimers in any other context
+var (
+	_ actions.TimerContext = (*blockContext)(nil)
+	_ actions.VMContext    = (*marketContext)(nil)
+)
+
+// blockContext is the VMContext actions execute against while a block is
+// verified. It exposes the block's view instead of the accepted books, and
+// copies a market's book the first time the block touches it, so untouched
+// markets are shared with the parent at no cost.
+type blockContext struct {
+	books  map[string]*storage.OrderBook
+	owned  map[string]struct{} // Markets already copied for this view
+	timers *storage.CancelTimers
+	copied bool // Whether timers was already copied for this view
+	rules  *genesis.Rules
+}
+
+func newBlockContext(parent map[string]*storage.OrderBook, timers *storage.CancelTimers, rules *genesis.Rules) *blockContext {
+	books := make(map[string]*storage.OrderBook, len(parent))
+	for market, book := range parent {
+		books[market] = book
+	}
+	return &blockContext{
+		books:  books,
+		owned:  make(map[string]struct{}),
+		timers: timers,
+		rules:  rules,
+	}
+}
+
+func (b *blockContext) GetOrderBook(market string) (*storage.OrderBook, error) {
+	book, ok := b.books[market]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrUnknownMarket, market)
+	}
+	if _, ok := b.owned[market]; !ok {
+		book = book.Clone()
+		b.books[market] = book
+		b.owned[market] = struct{}{}
+	}
+	return book, nil
+}
+
+func (b *blockContext) GetRules() *genesis.Rules { return b.rules }
+
+func (b *blockContext) GetCancelTimers() *storage.CancelTimers {
+	if !b.copied {
+		b.timers = b.timers.Clone()
+		b.copied = true
+	}
+	return b.timers
+}
+
+func (b *blockContext) Markets() []string {
+	markets := make([]string, 0, len(b.books))
+	for market := range b.books {
+		markets = append(markets, market)
+	}
+	sort.Strings(markets)
+	return markets
+}
+
+// touched returns the markets modified through this context
+func (b *blockContext) touched() map[string]struct{} {
+	return b.owned
+}
+
+// takeEvents drains the events recorded by every book modified through this
+// context, in market order so the result is deterministic
+func (b *blockContext) takeEvents() storage.BookEvents {
+	markets := make([]string, 0, len(b.owned))
+	for market := range b.owned {
+		markets = append(markets, market)
+	}
+	sort.Strings(markets)
+
+	var events storage.BookEvents
+	for _, market := range markets {
+		events.Append(b.books[market].TakeEvents())
+	}
+	return events
+}
+
+// marketContext is the VMContext of a market-scoped action executing
+// concurrently with other markets. It only exposes the book of its own
+// market, which was copied beforehand, so it never writes to shared maps.
+type marketContext struct {
+	market string
+	book   *storage.OrderBook
+	rules  *genesis.Rules
+}
+
+func (m *marketContext) GetOrderBook(market string) (*storage.OrderBook, error) {
+	if market != m.market {
+		return nil, fmt.Errorf("%w: scoped to %s, touched %s", ErrWrongMarket, m.market, market)
+	}
+	return m.book, nil
+}
+
+func (m *marketContext) GetRules() *genesis.Rules { return m.rules }
+
+func (m *marketContext) Markets() []string { return []string{m.market} }
+
+// scopedMarket returns the market [action] is confined to, or false if it
+// may touch any market
+func scopedMarket(action actions.Action) (string, bool) {
+	scoped, ok := action.(actions.MarketScoped)
+	if !ok || scoped.MarketID() == "" {
+		return "", false
+	}
+	return scoped.MarketID(), true
+}
+
+// VerifyBlock executes the order actions of a block against a copy of its
+// parent's books. The parent must be the last accepted block or a block that
+// was verified and is still pending. The accepted books are never modified,
+// so verifying blocks on competing forks is safe.
+func (vm *MatchingEngineVM) VerifyBlock(blk BlockInfo, txs []BlockTx) (*BlockView, error) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	if view, ok := vm.views[blk.ID]; ok {
+		// Already verified (e.g. verified again after a restart of consensus)
+		return view, nil
+	}
+
+	var (
+		parent map[string]*storage.OrderBook
+		timers *storage.CancelTimers
+	)
+	switch pview, ok := vm.views[blk.Parent]; {
+	case ok:
+		parent, timers = pview.Books, pview.Timers
+	case blk.Parent == vm.lastAccepted:
+		parent, timers = vm.Books, vm.Timers
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownParent, blk.Parent)
+	}
+
+	rules := vm.genesis.Rules(blk.Timestamp)
+	if len(txs) > rules.GetMaxBlockTxs() {
+		return nil, fmt.Errorf("%w: %d > %d", ErrTooManyTxs, len(txs), rules.GetMaxBlockTxs())
+	}
+
+	ctx := newBlockContext(parent, timers, rules)
+	preEvents, err := expireOrders(ctx, blk.Height, blk.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]error, len(txs))
+	events := make([]storage.BookEvents, len(txs))
+	latencies := make([]time.Duration, len(txs))
+	vm.executeTxs(ctx, txs, results, events, latencies)
+
+	view := &BlockView{
+		BlockInfo: blk,
+		Books:     ctx.books,
+		Timers:    ctx.timers,
+		Touched:   ctx.touched(),
+		Txs:       txs,
+		Results:   results,
+		Events:    events,
+		PreEvents: preEvents,
+		Latencies: latencies,
+	}
+	vm.views[blk.ID] = view
+	return view, nil
+}
+
+// expireOrders fires the dead-man's switches due at [height] and
+// [timestamp], before any tx of the block executes. Due timers are processed
+// in owner order and markets in market order, expiring at most
+// MaxExpiredOrdersPerBlock orders so every block does bounded work. A timer
+// is disarmed once all of its owner's orders are gone; if the budget runs out
+// first it stays due and the next block carries on where this one stopped.
+func expireOrders(ctx *blockContext, height uint64, timestamp int64) (storage.BookEvents, error) {
+	due := ctx.timers.Due(height, timestamp)
+	if len(due) == 0 {
+		return storage.BookEvents{}, nil
+	}
+
+	budget := ctx.rules.GetMaxExpiredOrdersPerBlock()
+	timers := ctx.GetCancelTimers()
+	for _, timer := range due {
+		done := true
+		for _, market := range ctx.Markets() {
+			// Only copy the books the owner actually has orders in
+			if len(ctx.books[market].OwnerOrders[timer.Owner]) == 0 {
+				continue
+			}
+			book, _ := ctx.GetOrderBook(market) // Known market, cannot fail
+			for _, order := range book.OrdersOf(timer.Owner) {
+				if budget == 0 {
+					done = false
+					break
+				}
+				if err := book.ExpireOrder(order, storage.ReasonCancelAfter); err != nil {
+					return storage.BookEvents{}, fmt.Errorf("failed to expire order %s: %w", order.ID, err)
+				}
+				budget--
+			}
+		}
+		if !done {
+			break
+		}
+		timers.Remove(timer.Owner)
+	}
+	return ctx.takeEvents(), nil
+}
+
+// executeTxs executes [txs] in block order. Runs of consecutive
+// market-scoped actions are executed concurrently across markets (in order
+// within each market); any other action is a barrier that runs alone. As
+// markets share no state this gives the same results as serial execution.
+// A failed action does not invalidate the block, its error is recorded in
+// [results]. The book events of each action are recorded in [events], and
+// the time it took in [latencies].
+func (vm *MatchingEngineVM) executeTxs(
+	ctx *blockContext,
+	txs []BlockTx,
+	results []error,
+	events []storage.BookEvents,
+	latencies []time.Duration,
+) {
+	for start := 0; start < len(txs); {
+		if _, ok := scopedMarket(txs[start].Action); !ok {
+			began := time.Now()
+			results[start] = txs[start].Action.Execute(ctx)
+			latencies[start] = time.Since(began)
+			events[start] = ctx.takeEvents()
+			start++
+			continue
+		}
+		end := start + 1
+		for end < len(txs) {
+			if _, ok := scopedMarket(txs[end].Action); !ok {
+				break
+			}
+			end++
+		}
+		vm.executeMarkets(ctx, txs[start:end], results[start:end], events[start:end], latencies[start:end])
+		start = end
+	}
+}
+
+// executeMarkets executes market-scoped [txs], running up to parallelism
+// markets at once
+func (vm *MatchingEngineVM) executeMarkets(
+	ctx *blockContext,
+	txs []BlockTx,
+	results []error,
+	events []storage.BookEvents,
+	latencies []time.Duration,
+) {
+	// Group txs by market, keeping block order within each market
+	var (
+		markets []string
+		groups  = make(map[string][]int)
+	)
+	for i, tx := range txs {
+		market, _ := scopedMarket(tx.Action)
+		if _, ok := groups[market]; !ok {
+			markets = append(markets, market)
+		}
+		groups[market] = append(groups[market], i)
+	}
+
+	// Copy every touched book up front so workers never write to ctx
+	contexts := make([]*marketContext, 0, len(markets))
+	for _, market := range markets {
+		book, err := ctx.GetOrderBook(market)
+		if err != nil {
+			for _, i := range groups[market] {
+				results[i] = err
+			}
+			continue
+		}
+		contexts = append(contexts, &marketContext{market: market, book: book, rules: ctx.rules})
+	}
+
+	run := func(mctx *marketContext) {
+		for _, i := range groups[mctx.market] {
+			began := time.Now()
+			results[i] = txs[i].Action.Execute(mctx)
+			latencies[i] = time.Since(began)
+			events[i] = mctx.book.TakeEvents()
+		}
+	}
+	if vm.parallelism <= 1 || len(contexts) <= 1 {
+		for _, mctx := range contexts {
+			run(mctx)
+		}
+		return
+	}
+
+	work := make(chan *marketContext)
+	var wg sync.WaitGroup
+	for w := 0; w < vm.parallelism && w < len(contexts); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for mctx := range work {
+				run(mctx)
+			}
+		}()
+	}
+	for _, mctx := range contexts {
+		work <- mctx
+	}
+	close(work)
+	wg.Wait()
+}
+
+// AcceptBlock commits the view of a verified block: its books become the
+// accepted books and new read snapshots are published. Views that can no
+// longer be accepted (those at or below the accepted height) are discarded.
+//
+// [persist], if not nil, is called with the view and the snapshots of every
+// book after the block before anything is committed. If it fails, the
+// accepted books and snapshots are left as they were, so whatever was
+// persisted before and the engine never disagree.
+func (vm *MatchingEngineVM) AcceptBlock(
+	blkID ids.ID,
+	persist func(view *BlockView, books Snapshots) error,
+) (*BlockView, error) {
+	vm.mu.Lock()
+	view, ok := vm.views[blkID]
+	vm.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBlock, blkID)
+	}
+
+	// Accepted books are never modified again, so the snapshots can be built
+	// without holding the lock and without delaying verification. Only the
+	// markets the block touched need to be copied.
+	vm.publishMu.Lock()
+	books := vm.buildSnapshots(view.Books, view.Touched, view.ID, view.Height, view.Timestamp)
+	vm.publishMu.Unlock()
+	view.Snapshots = make(Snapshots, len(view.Touched))
+	for market := range view.Touched {
+		view.Snapshots[market] = books[market]
+	}
+	if persist != nil {
+		if err := persist(view, books); err != nil {
+			view.Snapshots = nil
+			return nil, err
+		}
+	}
+	if _, err := vm.commitView(blkID); err != nil {
+		return nil, err
+	}
+
+	vm.publishMu.Lock()
+	vm.storeSnapshots(books, view.Height)
+	vm.publishMu.Unlock()
+	return view, nil
+}
+
+// commitView makes the view of [blkID] the accepted books
+func (vm *MatchingEngineVM) commitView(blkID ids.ID) (*BlockView, error) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	view, ok := vm.views[blkID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBlock, blkID)
+	}
+	vm.Books = view.Books
+	vm.Timers = view.Timers
+	vm.lastAccepted = blkID
+	vm.lastHeight = view.Height
+	vm.lastTimestamp = view.Timestamp
+	for id, v := range vm.views {
+		if v.Height <= view.Height {
+			delete(vm.views, id)
+		}
+	}
+	return view, nil
+}
+
+// RejectBlock discards the view of a verified block and of every pending
+// block built on top of it. The accepted books are left untouched.
+func (vm *MatchingEngineVM) RejectBlock(blkID ids.ID) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	rejected := map[ids.ID]struct{}{blkID: {}}
+	delete(vm.views, blkID)
+
+	// Descendants are always higher, so a few passes find them all
+	for found := true; found; {
+		found = false
+		for id, v := range vm.views {
+			if _, ok := rejected[v.Parent]; ok {
+				rejected[id] = struct{}{}
+				delete(vm.views, id)
+				found = true
+			}
+		}
+	}
+}
+
+// HasView reports whether a block has been verified and is still pending
+func (vm *MatchingEngineVM) HasView(blkID ids.ID) bool {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	_, ok := vm.views[blkID]
+	return ok
+}
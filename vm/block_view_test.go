@@ -0,0 +1,366 @@
+// CLOB/vm/block_view_test.go
+
+package vm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"CLOB/actions"
+	"CLOB/genesis"
+	"CLOB/storage"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/crypto"
+)
+
+var testStart = time.Unix(1_700_000_000, 0)
+
+// testMarket returns the ID of the [i]th market of newTestVM
+func testMarket(i int) string {
+	return fmt.Sprintf("market-%d", i)
+}
+
+// newTestVM creates an engine with [markets] empty markets
+func newTestVM(t testing.TB, markets int) *MatchingEngineVM {
+	t.Helper()
+	g := genesis.Default()
+	g.InitialOrders = nil
+	g.Markets = make([]genesis.CustomMarket, markets)
+	for i := range g.Markets {
+		g.Markets[i].ID = testMarket(i)
+	}
+	config, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := NewMatchingEngineVM(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vm
+}
+
+// testBlock returns a new block at [height] on top of [parent]
+func testBlock(parent ids.ID, height uint64) BlockInfo {
+	return BlockInfo{
+		ID:        ids.GenerateTestID(),
+		Parent:    parent,
+		Height:    height,
+		Timestamp: testStart.Unix() + int64(height),
+	}
+}
+
+// testOrder returns a limit order of [owner]
+func testOrder(id string, market string, owner byte, side storage.Side, price float64, quantity float64) *storage.Order {
+	return &storage.Order{
+		ID:        id,
+		Market:    market,
+		Side:      side,
+		Price:     price,
+		Quantity:  quantity,
+		Timestamp: testStart,
+		OrderType: storage.Limit,
+		Owner:     crypto.PublicKey{owner},
+	}
+}
+
+// testTx wraps [action] in a tx of its own
+func testTx(action actions.Action) BlockTx {
+	return BlockTx{TxID: ids.GenerateTestID(), Action: action}
+}
+
+// verify verifies a block of [txs] on top of [parent]
+func verify(t *testing.T, vm *MatchingEngineVM, parent ids.ID, height uint64, txs ...BlockTx) *BlockView {
+	t.Helper()
+	view, err := vm.VerifyBlock(testBlock(parent, height), txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return view
+}
+
+func TestAcceptBlockDiscardsSibling(t *testing.T) {
+	vm := newTestVM(t, 1)
+	market := testMarket(0)
+
+	accepted := verify(t, vm, ids.Empty, 1, testTx(&actions.AddOrderAction{Order: testOrder("accepted", market, 1, storage.Buy, 99, 1)}))
+	sibling := verify(t, vm, ids.Empty, 1, testTx(&actions.AddOrderAction{Order: testOrder("sibling", market, 2, storage.Buy, 98, 1)}))
+	child := verify(t, vm, sibling.ID, 2, testTx(&actions.AddOrderAction{Order: testOrder("child", market, 2, storage.Sell, 101, 1)}))
+
+	if _, err := vm.AcceptBlock(accepted.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	vm.RejectBlock(sibling.ID)
+
+	book, err := vm.GetOrderBook(market)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := vm.Snapshot(market)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := book.OrderMap["accepted"]; !ok {
+		t.Error("order of the accepted block is not in the book")
+	}
+	if _, ok := snapshot.GetOrder("accepted"); !ok {
+		t.Error("order of the accepted block is not in the snapshot")
+	}
+	for _, id := range []string{"sibling", "child"} {
+		if _, ok := book.OrderMap[id]; ok {
+			t.Errorf("order %q of a rejected fork reached the book", id)
+		}
+		if _, ok := snapshot.GetOrder(id); ok {
+			t.Errorf("order %q of a rejected fork reached the snapshot", id)
+		}
+	}
+	if vm.HasView(sibling.ID) || vm.HasView(child.ID) {
+		t.Error("rejected fork is still verified")
+	}
+	if _, err := vm.VerifyBlock(testBlock(child.ID, 3), nil); !errors.Is(err, ErrUnknownParent) {
+		t.Errorf("verified a block on a rejected fork: %v", err)
+	}
+}
+
+func TestAcceptBlockPersistFailureKeepsBooks(t *testing.T) {
+	vm := newTestVM(t, 1)
+	market := testMarket(0)
+	view := verify(t, vm, ids.Empty, 1, testTx(&actions.AddOrderAction{Order: testOrder("order", market, 1, storage.Buy, 99, 1)}))
+
+	errPersist := errors.New("persist failed")
+	_, err := vm.AcceptBlock(view.ID, func(v *BlockView, books Snapshots) error {
+		if _, ok := books[market].GetOrder("order"); !ok {
+			t.Error("persisted snapshots do not include the block")
+		}
+		return errPersist
+	})
+	if !errors.Is(err, errPersist) {
+		t.Fatalf("accept error %v, want %v", err, errPersist)
+	}
+
+	book, err := vm.GetOrderBook(market)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := book.OrderMap["order"]; ok {
+		t.Error("failed accept committed the book")
+	}
+	snapshot, err := vm.Snapshot(market)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := snapshot.GetOrder("order"); ok || snapshot.Height != 0 {
+		t.Error("failed accept published the snapshot")
+	}
+	if state := vm.State(); state.BlockID != ids.Empty || state.Height != 0 {
+		t.Errorf("failed accept moved the engine to height %d", state.Height)
+	}
+
+	// The block can still be accepted once persisting succeeds
+	if _, err := vm.AcceptBlock(view.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if state := vm.State(); !reflect.DeepEqual(state, view.State()) {
+		t.Errorf("accepted engine state %+v, view state %+v", state, view.State())
+	}
+}
+
+func TestRejectBlockDiscardsDescendants(t *testing.T) {
+	vm := newTestVM(t, 1)
+	market := testMarket(0)
+
+	rejected := verify(t, vm, ids.Empty, 1, testTx(&actions.AddOrderAction{Order: testOrder("rejected", market, 1, storage.Buy, 99, 1)}))
+	child := verify(t, vm, rejected.ID, 2)
+	grandchild := verify(t, vm, child.ID, 3)
+	sibling := verify(t, vm, ids.Empty, 1)
+
+	vm.RejectBlock(rejected.ID)
+
+	for _, view := range []*BlockView{rejected, child, grandchild} {
+		if vm.HasView(view.ID) {
+			t.Errorf("block at height %d is still verified after its ancestor was rejected", view.Height)
+		}
+	}
+	if !vm.HasView(sibling.ID) {
+		t.Error("sibling of the rejected block was discarded")
+	}
+	if _, err := vm.VerifyBlock(testBlock(grandchild.ID, 4), nil); !errors.Is(err, ErrUnknownParent) {
+		t.Errorf("verified a block on a rejected block: %v", err)
+	}
+	book, err := vm.GetOrderBook(market)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(book.OrderMap) != 0 {
+		t.Errorf("rejected block changed the accepted book: %d orders", len(book.OrderMap))
+	}
+}
+
+func TestAllOrNothingBatchRollsBack(t *testing.T) {
+	vm := newTestVM(t, 1)
+	market := testMarket(0)
+
+	resting := verify(t, vm, ids.Empty, 1, testTx(&actions.AddOrderAction{Order: testOrder("resting", market, 1, storage.Sell, 101, 1)}))
+	if _, err := vm.AcceptBlock(resting.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first instruction fills the resting order, the second fails
+	batch := &actions.BatchOrdersAction{
+		Market:       market,
+		Owner:        crypto.PublicKey{2},
+		AllOrNothing: true,
+		Actions: []actions.Action{
+			&actions.AddOrderAction{Order: testOrder("taker", market, 2, storage.Buy, 101, 1)},
+			&actions.CancelOrderAction{OrderID: "missing", Market: market, Owner: crypto.PublicKey{2}},
+		},
+	}
+	view := verify(t, vm, resting.ID, 2, testTx(batch))
+
+	if !errors.Is(view.Results[0], actions.ErrBatchAborted) {
+		t.Fatalf("batch result %v, want %v", view.Results[0], actions.ErrBatchAborted)
+	}
+	if !errors.Is(batch.Results[0], actions.ErrBatchAborted) {
+		t.Errorf("first instruction result %v, want %v", batch.Results[0], actions.ErrBatchAborted)
+	}
+	if !errors.Is(batch.Results[1], storage.ErrOrderNotFound) {
+		t.Errorf("second instruction result %v, want %v", batch.Results[1], storage.ErrOrderNotFound)
+	}
+	if n := len(view.Events[0].Fills) + len(view.Events[0].Updates); n != 0 {
+		t.Errorf("aborted batch recorded %d events", n)
+	}
+
+	book := view.Books[market]
+	order, ok := book.OrderMap["resting"]
+	if !ok || order.Quantity != 1 {
+		t.Error("aborted batch filled the resting order")
+	}
+	if _, ok := book.OrderMap["taker"]; ok {
+		t.Error("aborted batch placed its order")
+	}
+	if book.Sequence != vm.Books[market].Sequence {
+		t.Errorf("aborted batch moved the book from sequence %d to %d", vm.Books[market].Sequence, book.Sequence)
+	}
+}
+
+// multiMarketTxs returns [perMarket] txs for each of [markets] markets,
+// interleaved across markets, that rest, match and cancel orders. Halfway
+// through, a cancel-all across every market splits the block in two runs.
+// Tx IDs only depend on the position of the tx, so two calls return the
+// same block.
+func multiMarketTxs(markets int, perMarket int) []BlockTx {
+	txs := make([]BlockTx, 0, markets*perMarket+1)
+	add := func(action actions.Action) {
+		txs = append(txs, BlockTx{TxID: ids.Empty.Prefix(uint64(len(txs))), Action: action})
+	}
+	for i := 0; i < perMarket; i++ {
+		for m := 0; m < markets; m++ {
+			market := testMarket(m)
+			id := fmt.Sprintf("%s-%d", market, i)
+			owner := byte(i%4 + 1)
+			switch {
+			case i%5 == 4:
+				add(&actions.CancelOrderAction{OrderID: fmt.Sprintf("%s-%d", market, i-3), Market: market})
+			case i%2 == 0:
+				add(&actions.AddOrderAction{Order: testOrder(id, market, owner, storage.Buy, float64(100-i%3+m), float64(1+i%3))})
+			default:
+				add(&actions.AddOrderAction{Order: testOrder(id, market, owner, storage.Sell, float64(99+i%4+m), float64(1+i%2))})
+			}
+		}
+		if i == perMarket/2 {
+			add(&actions.CancelAllAction{Owner: crypto.PublicKey{1}})
+		}
+	}
+	return txs
+}
+
+func TestParallelExecutionMatchesSerial(t *testing.T) {
+	const markets, perMarket = 8, 100
+	serial := newTestVM(t, markets)
+	parallel := newTestVM(t, markets)
+	parallel.SetParallelism(4)
+
+	blk := testBlock(ids.Empty, 1)
+	want, err := serial.VerifyBlock(blk, multiMarketTxs(markets, perMarket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parallel.VerifyBlock(blk, multiMarketTxs(markets, perMarket))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fills := 0
+	for i := range want.Results {
+		if fmt.Sprint(got.Results[i]) != fmt.Sprint(want.Results[i]) {
+			t.Errorf("tx %d: parallel result %v, serial %v", i, got.Results[i], want.Results[i])
+		}
+		fills += len(want.Events[i].Fills)
+	}
+	if fills == 0 {
+		t.Fatal("block made no trades")
+	}
+	if !reflect.DeepEqual(got.Events, want.Events) {
+		t.Error("parallel events differ from serial events")
+	}
+	if !reflect.DeepEqual(got.Touched, want.Touched) {
+		t.Errorf("parallel touched %v, serial %v", got.Touched, want.Touched)
+	}
+	for m := 0; m < markets; m++ {
+		market := testMarket(m)
+		gotBook := got.Books[market].Snapshot(blk.ID, blk.Height, blk.Timestamp)
+		wantBook := want.Books[market].Snapshot(blk.ID, blk.Height, blk.Timestamp)
+		if !reflect.DeepEqual(gotBook, wantBook) {
+			t.Errorf("%s: parallel book differs from serial book", market)
+		}
+	}
+}
+
+func BenchmarkExecuteMarkets(b *testing.B) {
+	const markets, perMarket = 8, 120
+	for _, bc := range []struct {
+		name        string
+		parallelism int
+	}{
+		{"serial", 1},
+		{"parallel", runtime.GOMAXPROCS(0)},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			vm := newTestVM(b, markets)
+			vm.SetParallelism(bc.parallelism)
+			for i := 0; i < b.N; i++ {
+				// Orders are consumed by execution, so every block needs
+				// fresh ones
+				b.StopTimer()
+				blk := testBlock(ids.Empty, 1)
+				txs := multiMarketTxs(markets, perMarket)
+				b.StartTimer()
+
+				if _, err := vm.VerifyBlock(blk, txs); err != nil {
+					b.Fatal(err)
+				}
+
+				b.StopTimer()
+				vm.RejectBlock(blk.ID)
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+func TestCancelTimersOnlyInBlockContext(t *testing.T) {
+	action := &actions.SetCancelAfterAction{Owner: crypto.PublicKey{1}, Height: 10}
+	if _, ok := scopedMarket(action); ok {
+		t.Fatal("setting a cancel timer is market-scoped")
+	}
+	mctx := &marketContext{market: testMarket(0), book: storage.NewOrderBook()}
+	if err := action.Execute(mctx); !errors.Is(err, actions.ErrNoCancelTimers) {
+		t.Fatalf("executing in a market context returned %v, want %v", err, actions.ErrNoCancelTimers)
+	}
+}
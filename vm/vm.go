@@ -3,20 +3,46 @@
 package vm
 
 import (
-	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 
-	"CLOB/actions"
 	"CLOB/genesis"
 	"CLOB/storage"
 
-	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/crypto"
 	"github.com/ava-labs/hypersdk/vm"
 )
 
+// Snapshots maps each market to its read-only book snapshot
+type Snapshots map[string]*storage.BookSnapshot
+
+// SnapshotHistory is how many accepted heights keep their snapshots readable
+// through SnapshotAt, e.g. for paginated reads that must stay consistent
+const SnapshotHistory = 128
+
+var ErrSnapshotExpired = errors.New("snapshot is no longer retained")
+
 type MatchingEngineVM struct {
-	OrderBook *storage.OrderBook
-	Rules     *genesis.Rules
+	Books  map[string]*storage.OrderBook // Books as of the last accepted block, by market
+	Timers *storage.CancelTimers         // Dead-man's switches as of the last accepted block
+	Rules  *genesis.Rules
+
+	genesis     *genesis.Genesis
+	parallelism int // Max markets executed concurrently within a block
+
+	mu            sync.Mutex
+	lastAccepted  ids.ID                // Block Books reflect
+	lastHeight    uint64                // Height of lastAccepted
+	lastTimestamp int64                 // Timestamp of lastAccepted
+	views         map[ids.ID]*BlockView // Verified blocks awaiting a decision
+
+	publishMu sync.Mutex                // Serializes snapshot publication
+	snapshots atomic.Pointer[Snapshots] // Read-only copies of Books for RPC
+	history   map[uint64]Snapshots      // Snapshots of the last SnapshotHistory heights
+	heights   []uint64                  // Heights in history, oldest first
 }
 
 // NewMatchingEngineVM creates a new instance of the VM with genesis configuration
@@ -26,36 +52,229 @@ func NewMatchingEngineVM(genesisConfig []byte) (*MatchingEngineVM, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize genesis: %w", err)
 	}
+	return NewMatchingEngineVMFromGenesis(genesisInstance)
+}
 
+// NewMatchingEngineVMFromGenesis creates a new instance of the VM from an
+// already parsed genesis
+func NewMatchingEngineVMFromGenesis(genesisInstance *genesis.Genesis) (*MatchingEngineVM, error) {
 	// Initialize Rules
 	rules := genesisInstance.Rules(0) // Pass appropriate parameter if needed
 
-	// Initialize OrderBook
-	orderBook := storage.NewOrderBook()
+	// Load Genesis into the OrderBook of every market
+	books, err := genesisInstance.LoadOrderBooks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load genesis into order books: %w", err)
+	}
+	for _, book := range books {
+		book.TakeEvents() // Genesis orders are not the result of any tx
+	}
+
+	vm := &MatchingEngineVM{
+		Books:       books,
+		Timers:      storage.NewCancelTimers(),
+		Rules:       rules,
+		genesis:     genesisInstance,
+		parallelism: 1,
+		views:       make(map[ids.ID]*BlockView),
+		history:     make(map[uint64]Snapshots),
+	}
+	vm.publishSnapshots(books, nil, ids.Empty, 0, 0)
+	return vm, nil
+}
+
+// SetParallelism sets how many markets may be executed concurrently within a
+// block. Values below 1 execute serially.
+func (vm *MatchingEngineVM) SetParallelism(n int) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	if n < 1 {
+		n = 1
+	}
+	vm.parallelism = n
+}
+
+// Snapshots returns the read-only copies of every book as of the last
+// accepted block. It never blocks, and the returned snapshots never change,
+// so RPC handlers get a consistent view at a known height while blocks
+// execute.
+func (vm *MatchingEngineVM) Snapshots() Snapshots {
+	return *vm.snapshots.Load()
+}
+
+// Snapshot returns the read-only copy of a market's book as of the last
+// accepted block
+func (vm *MatchingEngineVM) Snapshot(market string) (*storage.BookSnapshot, error) {
+	s, ok := vm.Snapshots()[market]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrUnknownMarket, market)
+	}
+	return s, nil
+}
+
+// publishSnapshots publishes snapshots of [books] at the given block and
+// returns them
+func (vm *MatchingEngineVM) publishSnapshots(
+	books map[string]*storage.OrderBook,
+	touched map[string]struct{},
+	blkID ids.ID,
+	height uint64,
+	timestamp int64,
+) Snapshots {
+	vm.publishMu.Lock()
+	defer vm.publishMu.Unlock()
+
+	next := vm.buildSnapshots(books, touched, blkID, height, timestamp)
+	vm.storeSnapshots(next, height)
+	return next
+}
+
+// buildSnapshots returns snapshots of [books] at the given block without
+// publishing them. Snapshots of markets not in [touched] are unchanged since
+// the previous publication, so they are reused with only their block
+// updated. A nil [touched] rebuilds every market. The caller must hold
+// publishMu.
+func (vm *MatchingEngineVM) buildSnapshots(
+	books map[string]*storage.OrderBook,
+	touched map[string]struct{},
+	blkID ids.ID,
+	height uint64,
+	timestamp int64,
+) Snapshots {
+	var prev Snapshots
+	if p := vm.snapshots.Load(); p != nil {
+		prev = *p
+	}
+	next := make(Snapshots, len(books))
+	for market, book := range books {
+		old, ok := prev[market]
+		_, changed := touched[market]
+		if touched == nil || changed || !ok {
+			next[market] = book.Snapshot(blkID, height, timestamp)
+			continue
+		}
+		s := *old // Contents are immutable, only the block moves on
+		s.BlockID, s.Height, s.Timestamp = blkID, height, timestamp
+		next[market] = &s
+	}
+	return next
+}
 
-	// Load Genesis into OrderBook
-	if err := genesisInstance.Load(context.Background(), orderBook); err != nil {
-		return nil, fmt.Errorf("failed to load genesis into order book: %w", err)
+// storeSnapshots publishes [next] as the snapshots at [height]. The caller
+// must hold publishMu.
+func (vm *MatchingEngineVM) storeSnapshots(next Snapshots, height uint64) {
+	vm.snapshots.Store(&next)
+
+	if _, ok := vm.history[height]; !ok {
+		vm.heights = append(vm.heights, height)
+		if len(vm.heights) > SnapshotHistory {
+			delete(vm.history, vm.heights[0])
+			vm.heights = vm.heights[1:]
+		}
 	}
+	vm.history[height] = next
+}
 
-	return &MatchingEngineVM{
-		OrderBook: orderBook,
-		Rules:     rules,
-	}, nil
+// SnapshotAt returns the read-only copy of a market's book as of the
+// accepted block at [height], if it is among the last SnapshotHistory
+func (vm *MatchingEngineVM) SnapshotAt(market string, height uint64) (*storage.BookSnapshot, error) {
+	vm.publishMu.Lock()
+	snapshots, ok := vm.history[height]
+	vm.publishMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: height %d", ErrSnapshotExpired, height)
+	}
+	s, ok := snapshots[market]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrUnknownMarket, market)
+	}
+	return s, nil
 }
 
-// ExecuteAction executes a given action on the VM
-func (vm *MatchingEngineVM) ExecuteAction(action actions.Action) error {
-	if err := action.Execute(vm); err != nil {
-		// Wrap or handle the error as needed
-		return fmt.Errorf("failed to execute action: %w", err)
+// GetOrderBook returns the accepted order book of a market
+func (vm *MatchingEngineVM) GetOrderBook(market string) (*storage.OrderBook, error) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	book, ok := vm.Books[market]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrUnknownMarket, market)
 	}
-	return nil
+	return book, nil
+}
+
+// SetLastAccepted records the block the accepted books correspond to. It
+// must be called once at startup before any block is verified.
+func (vm *MatchingEngineVM) SetLastAccepted(blkID ids.ID, height uint64) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	vm.lastAccepted = blkID
+	vm.lastHeight = height
+	vm.publishSnapshots(vm.Books, nil, blkID, height, 0)
 }
 
-// GetOrderBook returns the VM's order book
-func (vm *MatchingEngineVM) GetOrderBook() *storage.OrderBook {
-	return vm.OrderBook
+// Restore replaces the accepted books and timers with those persisted at
+// block [blkID], e.g. when the VM restarts at a non-genesis tip
+func (vm *MatchingEngineVM) Restore(
+	books map[string]*storage.OrderBook,
+	timers *storage.CancelTimers,
+	blkID ids.ID,
+	height uint64,
+	timestamp int64,
+) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	vm.Books = books
+	vm.Timers = timers
+	vm.Rules = vm.genesis.Rules(timestamp)
+	vm.lastAccepted = blkID
+	vm.lastHeight = height
+	vm.lastTimestamp = timestamp
+	vm.views = make(map[ids.ID]*BlockView)
+	vm.publishSnapshots(books, nil, blkID, height, timestamp)
+}
+
+// State returns the engine state of the accepted books, to be persisted
+// with the metadata of the last accepted block
+func (vm *MatchingEngineVM) State() *storage.EngineState {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	return engineState(vm.Books, vm.Timers, vm.lastAccepted, vm.lastHeight, vm.lastTimestamp)
+}
+
+// engineState returns the engine state of [books] and [timers] at the given
+// block
+func engineState(
+	books map[string]*storage.OrderBook,
+	timers *storage.CancelTimers,
+	blkID ids.ID,
+	height uint64,
+	timestamp int64,
+) *storage.EngineState {
+	sequences := make(map[string]uint64, len(books))
+	for market, book := range books {
+		sequences[market] = book.Sequence
+	}
+	return &storage.EngineState{
+		BlockID:   blkID,
+		Height:    height,
+		Timestamp: timestamp,
+		Sequences: sequences,
+		Timers:    timers.All(),
+	}
+}
+
+// CancelTimer returns the accepted dead-man's switch of [owner], or false if
+// none is armed
+func (vm *MatchingEngineVM) CancelTimer(owner crypto.PublicKey) (storage.CancelTimer, bool) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	return vm.Timers.Get(owner)
 }
 
 // GetRules returns the VM's rules
@@ -63,7 +282,6 @@ func (vm *MatchingEngineVM) GetRules() *genesis.Rules {
 	return vm.Rules
 }
 
-
 func New(options ...vm.Option) (*vm.VM, error) {
 	options = append(options, With()) // Add MorpheusVM API
 	return defaultvm.New(
@@ -77,4 +295,4 @@ func New(options ...vm.Option) (*vm.VM, error) {
 		auth.Engines(),
 		options...,
 	)
-}
\ No newline at end of file
+}